@@ -21,12 +21,14 @@ package main
 import (
 	"archive/tar"
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -36,13 +38,14 @@ import (
 	"github.com/grammarly/rocker-compose/src/compose/config"
 	"github.com/grammarly/rocker-compose/src/compose/tarmaker"
 
-	log "github.com/Sirupsen/logrus"
 	"github.com/codegangsta/cli"
 	"github.com/fsouza/go-dockerclient"
 	"github.com/grammarly/rocker/src/dockerclient"
 	"github.com/grammarly/rocker/src/rocker/debugtrap"
 	"github.com/grammarly/rocker/src/rocker/textformatter"
 	"github.com/grammarly/rocker/src/template"
+	"github.com/moby/term"
+	log "github.com/sirupsen/logrus"
 )
 
 var (
@@ -75,10 +78,11 @@ func main() {
 		{"Stas Levental", "stas.levental@grammarly.com"},
 	}
 
-	fileArg := cli.StringFlag{
+	fileArg := cli.StringSliceFlag{
 		Name:  "file, f",
-		Value: "compose.yml",
-		Usage: "Path to configuration file which should be run, if `-` is given as a value, then STDIN will be used",
+		Value: &cli.StringSlice{},
+		Usage: "Path to configuration file which should be run, if `-` is given as a value, then STDIN will be used; " +
+			"can be given several times to merge files, by default compose.yml and compose.override.yml if it exists",
 	}
 
 	varsFlags := []cli.Flag{
@@ -90,7 +94,20 @@ func main() {
 		cli.StringSliceFlag{
 			Name:  "var-file",
 			Value: &cli.StringSlice{},
-			Usage: "Load variables form a file, either JSON or YAML. Can pass multiple of this.",
+			Usage: "Load variables form a file, either JSON, YAML or .env. Can pass multiple of this.",
+		},
+	}
+
+	filterFlags := []cli.Flag{
+		cli.StringSliceFlag{
+			Name:  "only",
+			Value: &cli.StringSlice{},
+			Usage: "Touch only containers matching given name patterns and their dependencies, e.g. \"api,worker\"",
+		},
+		cli.StringSliceFlag{
+			Name:  "exclude",
+			Value: &cli.StringSlice{},
+			Usage: "Do not touch containers matching given name patterns, e.g. \"batch_*\"",
 		},
 	}
 
@@ -103,6 +120,14 @@ func main() {
 			Name:  "print",
 			Usage: "just print the rendered compose config and exit",
 		},
+		cli.BoolFlag{
+			Name:  "print-resolved",
+			Usage: "print the manifest as it is deployed, after processing of extends, aliases and defaults, and exit",
+		},
+		cli.BoolFlag{
+			Name:  "print-lines",
+			Usage: "print the rendered compose config with line numbers and numbers of source lines in brackets, and exit",
+		},
 		cli.BoolFlag{
 			Name:  "demand-artifacts",
 			Usage: "fail if artifacts not found for {{ image }} helpers",
@@ -111,6 +136,10 @@ func main() {
 			Name:  "tar",
 			Usage: "the input compose file is a release tar archive (see 'tar' command)",
 		},
+		cli.BoolFlag{
+			Name:  "strict",
+			Usage: "fail on container properties that are not known by rocker-compose instead of keeping them in 'extra'",
+		},
 	})
 
 	app.Flags = append([]cli.Flag{
@@ -170,6 +199,115 @@ func main() {
 					Name:  "ansible",
 					Usage: "output json in ansible format for easy parsing",
 				},
+				cli.BoolFlag{
+					Name:  "adopt",
+					Usage: "take over existing containers not created by rocker-compose if they are equal to the manifest",
+				},
+			}, appendFlags(filterFlags, composeFlags)...),
+		},
+		{
+			Name:   "plan",
+			Usage:  "show what changes are going to be made by run and why",
+			Action: planCommand,
+			Flags: append([]cli.Flag{
+				cli.BoolFlag{
+					Name:  "pull",
+					Usage: "Do pull images before comparing",
+				},
+				cli.StringFlag{
+					Name:  "out, o",
+					Usage: "save the execution plan to a file, which can be then executed with 'apply'",
+				},
+			}, composeFlags...),
+		},
+		{
+			Name:   "apply",
+			Usage:  "execute the plan saved by 'plan -out'",
+			Action: applyCommand,
+			Flags: appendFlags(fileArg, varsFlags, []cli.Flag{
+				cli.BoolFlag{
+					Name:  "dry, d",
+					Usage: "Don't execute any run/stop operations on target docker",
+				},
+				cli.DurationFlag{
+					Name:  "wait",
+					Value: 1 * time.Second,
+					Usage: "Wait and check exit codes of launched containers",
+				},
+				cli.BoolFlag{
+					Name:  "ansible",
+					Usage: "output json in ansible format for easy parsing",
+				},
+			}),
+		},
+		{
+			Name:    "status",
+			Aliases: []string{"ps"},
+			Usage:   "show desired vs actual state of containers specified in the manifest",
+			Action:  statusCommand,
+			Flags: append([]cli.Flag{
+				cli.StringFlag{
+					Name:  "format, F",
+					Value: "table",
+					Usage: "output in specified format (table|json)",
+				},
+			}, composeFlags...),
+		},
+		{
+			Name:   "logs",
+			Usage:  "show logs of containers specified in the manifest",
+			Action: logsCommand,
+			Flags: append([]cli.Flag{
+				cli.BoolFlag{
+					Name:  "follow",
+					Usage: "Follow log output, also of containers that get recreated",
+				},
+				cli.StringFlag{
+					Name:  "tail",
+					Value: "all",
+					Usage: "Number of lines to show from the end of the logs",
+				},
+				cli.StringFlag{
+					Name:  "since",
+					Usage: "Show logs since timestamp (e.g. 2016-01-02T13:23:37Z) or relative (e.g. 42m for 42 minutes)",
+				},
+				cli.BoolFlag{
+					Name:  "timestamps, t",
+					Usage: "Show timestamps",
+				},
+			}, composeFlags...),
+		},
+		{
+			Name:   "graph",
+			Usage:  "print the graph of dependencies between containers specified in the manifest",
+			Action: graphCommand,
+			Flags: append([]cli.Flag{
+				cli.StringFlag{
+					Name:  "format, F",
+					Value: "dot",
+					Usage: "output in specified format (dot|mermaid|json)",
+				},
+			}, composeFlags...),
+		},
+		{
+			Name:   "validate",
+			Usage:  "check the manifest for errors without connecting to docker",
+			Action: validateCommand,
+			Flags:  composeFlags,
+		},
+		{
+			Name:   "exec",
+			Usage:  "run a command in a running container specified in the manifest",
+			Action: execCommand,
+			Flags: append([]cli.Flag{
+				cli.BoolFlag{
+					Name:  "no-tty, T",
+					Usage: "Disable pseudo-TTY allocation, by default a TTY is allocated when stdin is a terminal",
+				},
+				cli.StringFlag{
+					Name:  "user, u",
+					Usage: "Username or UID to run the command as",
+				},
 			}, composeFlags...),
 		},
 		{
@@ -181,14 +319,44 @@ func main() {
 					Name:  "ansible",
 					Usage: "output json in ansible format for easy parsing",
 				},
-			}, composeFlags...),
+			}, appendFlags(filterFlags, composeFlags)...),
 		},
 		{
 			Name:   "rm",
 			Usage:  "stop and remove any containers specified in the manifest",
 			Action: rmCommand,
+			Flags:  appendFlags(filterFlags, composeFlags),
+		},
+		{
+			Name:   "stop",
+			Usage:  "stop containers specified in the manifest without removing them",
+			Action: stopCommand,
 			Flags:  composeFlags,
 		},
+		{
+			Name:   "start",
+			Usage:  "start stopped containers specified in the manifest without recreating them",
+			Action: startCommand,
+			Flags: append([]cli.Flag{
+				cli.DurationFlag{
+					Name:  "wait",
+					Value: 1 * time.Second,
+					Usage: "Wait and check exit codes of started containers",
+				},
+			}, composeFlags...),
+		},
+		{
+			Name:   "restart",
+			Usage:  "restart containers specified in the manifest without recreating them",
+			Action: restartCommand,
+			Flags: append([]cli.Flag{
+				cli.DurationFlag{
+					Name:  "wait",
+					Value: 1 * time.Second,
+					Usage: "Wait and check exit codes of started containers",
+				},
+			}, composeFlags...),
+		},
 		{
 			Name:   "clean",
 			Usage:  "cleanup old tags for images specified in the manifest",
@@ -222,6 +390,85 @@ func main() {
 				},
 			}),
 		},
+		{
+			Name:   "pin",
+			Usage:  "pin versions of images specified in the manifest",
+			Action: pinCommand,
+			Flags: append([]cli.Flag{
+				cli.BoolTFlag{
+					Name:  "local, l",
+					Usage: "search across images available locally",
+				},
+				cli.BoolFlag{
+					Name:  "hub",
+					Usage: "search across images in the registry",
+				},
+				cli.StringFlag{
+					Name:  "type, t",
+					Value: "yaml",
+					Usage: "output in specified format (json|yaml)",
+				},
+				cli.StringFlag{
+					Name:  "output, O",
+					Value: "-",
+					Usage: "write result in a file or stdout if the value is `-`",
+				},
+			}, composeFlags...),
+		},
+		{
+			Name:   "export",
+			Usage:  "convert the manifest to docker-compose or kubernetes format",
+			Action: exportCommand,
+			Flags: append([]cli.Flag{
+				cli.StringFlag{
+					Name:  "format, F",
+					Value: "compose-v3",
+					Usage: "output in specified format (compose-v3|k8s)",
+				},
+				cli.StringFlag{
+					Name:  "output, O",
+					Value: "-",
+					Usage: "write result in a file or stdout if the value is `-`",
+				},
+			}, composeFlags...),
+		},
+		{
+			Name:   "import",
+			Usage:  "convert docker-compose.yml, or a file given as an argument, to a rocker-compose manifest",
+			Action: importCommand,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "namespace, n",
+					Usage: "namespace of the manifest, defaults to the name of the directory of docker-compose.yml",
+				},
+				cli.StringFlag{
+					Name:  "output, O",
+					Value: "-",
+					Usage: "write result in a file or stdout if the value is `-`",
+				},
+			},
+		},
+		{
+			Name:   "snapshot",
+			Usage:  "make a manifest of existing containers of a namespace or of given containers",
+			Action: snapshotCommand,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "namespace, n",
+					Usage: "take all containers of the namespace, which is also the namespace of the manifest",
+				},
+				cli.StringSliceFlag{
+					Name:  "containers, c",
+					Value: &cli.StringSlice{},
+					Usage: "take given containers, names are relative to the namespace if it is given, e.g. `web,.statsd`",
+				},
+				cli.StringFlag{
+					Name:  "output, O",
+					Value: "-",
+					Usage: "write result in a file or stdout if the value is `-`",
+				},
+			},
+		},
 		{
 			Name:   "recover",
 			Usage:  "recover containers from machine reboot or docker daemon restart",
@@ -279,6 +526,9 @@ func runCommand(ctx *cli.Context) {
 		Wait:     ctx.Duration("wait"),
 		Pull:     ctx.Bool("pull"),
 		Auth:     auth,
+		Only:     ctx.StringSlice("only"),
+		Exclude:  ctx.StringSlice("exclude"),
+		Adopt:    ctx.Bool("adopt"),
 	})
 
 	if err != nil {
@@ -292,14 +542,304 @@ func runCommand(ctx *cli.Context) {
 		}
 	}
 
-	if err := compose.RunAction(); err != nil {
-		fatalf(err)
+	if err := compose.RunAction(); err != nil {
+		fatalf(err)
+	}
+
+	if ansibleResp != nil {
+		// ansibleResp.Success("done hehe").WriteTo(os.Stdout)
+		compose.WritePlan(ansibleResp).WriteTo(os.Stdout)
+	}
+}
+
+func planCommand(ctx *cli.Context) {
+	initLogs(ctx)
+
+	dockerCli := initDockerClient(ctx)
+	config := initComposeConfig(ctx, dockerCli)
+	auth := initAuthConfig(ctx)
+
+	compose, err := compose.New(&compose.Config{
+		Manifest: config,
+		Docker:   dockerCli,
+		Pull:     ctx.Bool("pull"),
+		Auth:     auth,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	plan, err := compose.PlanAction()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if _, err := plan.WriteTo(os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+
+	if out := ctx.String("out"); out != "" {
+		savedPlan, err := compose.SavePlan()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		fd, err := os.Create(out)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer fd.Close()
+
+		if _, err := savedPlan.WriteTo(fd); err != nil {
+			log.Fatal(err)
+		}
+
+		log.Infof("Plan is saved to %s, run `rocker-compose apply %s` to execute it", out, out)
+	}
+}
+
+func applyCommand(ctx *cli.Context) {
+	ansibleResp := initAnsubleResp(ctx)
+
+	fatalf := func(err error) {
+		if ansibleResp != nil {
+			ansibleResp.Error(err).WriteTo(os.Stdout)
+		}
+		log.Fatal(err)
+	}
+
+	initLogs(ctx)
+
+	if len(ctx.Args()) != 1 {
+		fatalf(fmt.Errorf("Expected exactly one argument: path to the plan file"))
+	}
+
+	fd, err := os.Open(ctx.Args().First())
+	if err != nil {
+		fatalf(err)
+	}
+	defer fd.Close()
+
+	plan, err := compose.ReadSavedPlan(fd)
+	if err != nil {
+		fatalf(err)
+	}
+
+	dockerCli := initDockerClient(ctx)
+	auth := initAuthConfig(ctx)
+
+	// the plan is executed without the manifest, it is read only if it is given
+	// explicitly, to make sure the plan was made of it
+	var manifest *config.Config
+	if len(ctx.StringSlice("file")) > 0 {
+		manifest = initComposeConfig(ctx, dockerCli)
+	}
+
+	compose, err := compose.New(&compose.Config{
+		Manifest: manifest,
+		Docker:   dockerCli,
+		DryRun:   ctx.Bool("dry"),
+		Wait:     ctx.Duration("wait"),
+		Auth:     auth,
+	})
+	if err != nil {
+		fatalf(err)
+	}
+
+	if err := compose.ApplyAction(plan); err != nil {
+		fatalf(err)
+	}
+
+	if ansibleResp != nil {
+		compose.WritePlan(ansibleResp).WriteTo(os.Stdout)
+	}
+}
+
+func statusCommand(ctx *cli.Context) {
+	initLogs(ctx)
+
+	format := ctx.String("format")
+	if format != "table" && format != "json" {
+		log.Fatalf("Possible formats are `table` and `json`, unknown format `%s`", format)
+	}
+
+	dockerCli := initDockerClient(ctx)
+	config := initComposeConfig(ctx, dockerCli)
+	auth := initAuthConfig(ctx)
+
+	compose, err := compose.New(&compose.Config{
+		Manifest: config,
+		Docker:   dockerCli,
+		Auth:     auth,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	status, err := compose.StatusAction()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if format == "json" {
+		err = status.WriteJSON(os.Stdout)
+	} else {
+		err = status.WriteTable(os.Stdout)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func logsCommand(ctx *cli.Context) {
+	initLogs(ctx)
+
+	since, err := parseSince(ctx.String("since"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	options := compose.LogsOptions{
+		Follow:     ctx.Bool("follow"),
+		Tail:       ctx.String("tail"),
+		Since:      since,
+		Timestamps: ctx.Bool("timestamps"),
+	}
+
+	dockerCli := initDockerClient(ctx)
+	config := initComposeConfig(ctx, dockerCli)
+	auth := initAuthConfig(ctx)
+
+	compose, err := compose.New(&compose.Config{
+		Manifest: config,
+		Docker:   dockerCli,
+		Auth:     auth,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := compose.LogsAction(ctx.Args(), options); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func graphCommand(ctx *cli.Context) {
+	initLogs(ctx)
+
+	format := ctx.String("format")
+	if format != "dot" && format != "mermaid" && format != "json" {
+		log.Fatalf("Possible formats are `dot`, `mermaid` and `json`, unknown format `%s`", format)
+	}
+
+	// do not mix logs with the result written to stdout
+	if !ctx.GlobalIsSet("verbose") {
+		log.SetLevel(log.WarnLevel)
+	}
+
+	// the graph is made of the manifest only, so docker is not pinged
+	dockerCli := initDockerClient(ctx)
+	config := readComposeConfig(ctx, dockerCli)
+
+	compose, err := compose.New(&compose.Config{
+		Manifest: config,
+		Docker:   dockerCli,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	graph, err := compose.GraphAction()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	switch format {
+	case "dot":
+		err = graph.WriteDot(os.Stdout)
+	case "mermaid":
+		err = graph.WriteMermaid(os.Stdout)
+	case "json":
+		err = graph.WriteJSON(os.Stdout)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func validateCommand(ctx *cli.Context) {
+	initLogs(ctx)
+
+	// the manifest is checked offline, so docker is not pinged
+	dockerCli := initDockerClient(ctx)
+	manifest := readComposeConfig(ctx, dockerCli)
+
+	compose, err := compose.New(&compose.Config{
+		Manifest: manifest,
+		Docker:   dockerCli,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = compose.ValidateAction()
+	if errs, ok := err.(config.ValidationErrors); ok {
+		fmt.Fprintf(os.Stderr, "Manifest %s has %d error(s):\n", strings.Join(manifestFiles(ctx), " + "), len(errs))
+		for _, e := range errs {
+			fmt.Fprintf(os.Stderr, "  - %s\n", e)
+		}
+		os.Exit(1)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Infof("Manifest %s is valid", strings.Join(manifestFiles(ctx), " + "))
+}
+
+func execCommand(ctx *cli.Context) {
+	initLogs(ctx)
+
+	// do not mix logs with the output of the command
+	if !ctx.GlobalIsSet("verbose") {
+		log.SetLevel(log.WarnLevel)
+	}
+
+	args := ctx.Args()
+	if len(args) > 1 && args[1] == "--" {
+		args = append([]string{args[0]}, args[2:]...)
+	}
+	if len(args) < 2 {
+		log.Fatal("Expected container name and command, e.g. `rocker-compose exec main -- sh`")
+	}
+
+	_, isTerminal := term.GetFdInfo(os.Stdin)
+
+	options := compose.ExecOptions{
+		Cmd:  args[1:],
+		Tty:  isTerminal && !ctx.Bool("no-tty"),
+		User: ctx.String("user"),
+	}
+
+	dockerCli := initDockerClient(ctx)
+	config := initComposeConfig(ctx, dockerCli)
+	auth := initAuthConfig(ctx)
+
+	compose, err := compose.New(&compose.Config{
+		Manifest: config,
+		Docker:   dockerCli,
+		Auth:     auth,
+	})
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	if ansibleResp != nil {
-		// ansibleResp.Success("done hehe").WriteTo(os.Stdout)
-		compose.WritePlan(ansibleResp).WriteTo(os.Stdout)
+	exitCode, err := compose.ExecAction(args[0], options)
+	if err != nil {
+		log.Fatal(err)
 	}
+
+	os.Exit(exitCode)
 }
 
 func pullCommand(ctx *cli.Context) {
@@ -323,6 +863,8 @@ func pullCommand(ctx *cli.Context) {
 		Docker:   dockerCli,
 		DryRun:   ctx.Bool("dry"),
 		Auth:     auth,
+		Only:     ctx.StringSlice("only"),
+		Exclude:  ctx.StringSlice("exclude"),
 	})
 	if err != nil {
 		fatalf(err)
@@ -350,6 +892,36 @@ func rmCommand(ctx *cli.Context) {
 	}
 }
 
+func stopCommand(ctx *cli.Context) {
+	initLogs(ctx)
+
+	compose := initCompose(ctx)
+
+	if err := compose.StopAction(ctx.Args()); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func startCommand(ctx *cli.Context) {
+	initLogs(ctx)
+
+	compose := initCompose(ctx)
+
+	if err := compose.StartAction(ctx.Args()); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func restartCommand(ctx *cli.Context) {
+	initLogs(ctx)
+
+	compose := initCompose(ctx)
+
+	if err := compose.RestartAction(ctx.Args()); err != nil {
+		log.Fatal(err)
+	}
+}
+
 func cleanCommand(ctx *cli.Context) {
 	ansibleResp := initAnsubleResp(ctx)
 
@@ -392,11 +964,18 @@ func tarCommand(ctx *cli.Context) {
 	initLogs(ctx)
 
 	var (
-		file   = ctx.String("file")
+		files  = ctx.StringSlice("file")
+		file   = "compose.yml"
 		output = ctx.String("output")
 		prefix = ctx.String("prefix")
 	)
 
+	if len(files) > 1 {
+		log.Fatal("Tar release can be made of a single manifest file")
+	} else if len(files) == 1 {
+		file = files[0]
+	}
+
 	// TODO: test logs
 	if output == "-" && !ctx.GlobalIsSet("verbose") {
 		log.SetLevel(log.WarnLevel)
@@ -416,6 +995,268 @@ func tarCommand(ctx *cli.Context) {
 	}
 }
 
+func pinCommand(ctx *cli.Context) {
+	initLogs(ctx)
+
+	var (
+		vars   template.Vars
+		data   []byte
+		err    error
+		output = ctx.String("output")
+		format = ctx.String("type")
+		fd     = os.Stdout
+	)
+
+	// do not mix logs with the result written to stdout
+	if output == "-" && !ctx.GlobalIsSet("verbose") {
+		log.SetLevel(log.WarnLevel)
+	}
+
+	// detect format by extension, unless it is given explicitly
+	if output != "-" && !ctx.IsSet("type") && filepath.Ext(output) == ".json" {
+		format = "json"
+	}
+
+	dockerCli := initDockerClient(ctx)
+	config := initComposeConfig(ctx, dockerCli)
+	auth := initAuthConfig(ctx)
+
+	compose, err := compose.New(&compose.Config{
+		Manifest: config,
+		Docker:   dockerCli,
+		Auth:     auth,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if vars, err = compose.PinAction(ctx.BoolT("local"), ctx.Bool("hub")); err != nil {
+		log.Fatal(err)
+	}
+
+	switch format {
+	case "yaml":
+		data, err = yaml.Marshal(vars.ToMapOfInterface())
+	case "json":
+		data, err = json.MarshalIndent(vars.ToMapOfInterface(), "", "  ")
+		data = append(data, '\n')
+	default:
+		log.Fatalf("Possible types are `yaml` and `json`, unknown type `%s`", format)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if output != "-" {
+		if fd, err = os.Create(output); err != nil {
+			log.Fatal(err)
+		}
+		defer fd.Close()
+	}
+
+	if _, err := fd.Write(data); err != nil {
+		log.Fatal(err)
+	}
+
+	if output != "-" {
+		log.Infof("Pinned versions of %d containers written to %s", len(vars), output)
+	}
+}
+
+func exportCommand(ctx *cli.Context) {
+	initLogs(ctx)
+
+	var (
+		output = ctx.String("output")
+		format = ctx.String("format")
+		fd     = os.Stdout
+		data   []byte
+		errs   config.ValidationErrors
+	)
+
+	// do not mix logs with the result written to stdout, warnings are still shown
+	if output == "-" && !ctx.GlobalIsSet("verbose") {
+		log.SetLevel(log.WarnLevel)
+	}
+
+	// the manifest is converted offline, so docker is not pinged
+	dockerCli := initDockerClient(ctx)
+	manifest := readComposeConfig(ctx, dockerCli).Resolved()
+
+	switch format {
+	case "compose-v3":
+		result, unsupported := manifest.ExportDockerCompose()
+		out, err := yaml.Marshal(result)
+		if err != nil {
+			log.Fatal(err)
+		}
+		data, errs = out, unsupported
+	case "k8s":
+		docs, unsupported := manifest.ExportKubernetes()
+		for _, doc := range docs {
+			out, err := yaml.Marshal(doc)
+			if err != nil {
+				log.Fatal(err)
+			}
+			data = append(append(data, "---\n"...), out...)
+		}
+		errs = unsupported
+	default:
+		log.Fatalf("Possible formats are `compose-v3` and `k8s`, unknown format `%s`", format)
+	}
+
+	for _, e := range errs {
+		log.Warnf("Not exported: %s", e)
+	}
+
+	if output != "-" {
+		var err error
+		if fd, err = os.Create(output); err != nil {
+			log.Fatal(err)
+		}
+		defer fd.Close()
+	}
+
+	if _, err := fd.Write(data); err != nil {
+		log.Fatal(err)
+	}
+
+	if output != "-" {
+		log.Infof("Exported %s to %s in %s format, %d propertie(s) not exported",
+			strings.Join(manifestFiles(ctx), " + "), output, format, len(errs))
+	}
+}
+
+func importCommand(ctx *cli.Context) {
+	initLogs(ctx)
+
+	var (
+		file      = "docker-compose.yml"
+		output    = ctx.String("output")
+		namespace = ctx.String("namespace")
+		fd        = os.Stdout
+		reader    io.Reader
+	)
+
+	if ctx.Args().Present() {
+		file = ctx.Args().First()
+	}
+
+	// do not mix logs with the result written to stdout, warnings are still shown
+	if output == "-" && !ctx.GlobalIsSet("verbose") {
+		log.SetLevel(log.WarnLevel)
+	}
+
+	if file == "-" {
+		reader = os.Stdin
+	} else {
+		f, err := os.Open(file)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		reader = f
+	}
+
+	// docker-compose names projects by the directory, so do we
+	if namespace == "" {
+		dir, err := toAbsolutePath(filepath.Dir(file), true)
+		if err != nil {
+			log.Fatal(err)
+		}
+		namespace = importNamespace(filepath.Base(dir))
+	}
+
+	manifest, warnings, err := config.ImportDockerCompose(reader, namespace)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, warning := range warnings {
+		log.Warn(warning)
+	}
+
+	data, err := yaml.Marshal(manifest)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if output != "-" {
+		if fd, err = os.Create(output); err != nil {
+			log.Fatal(err)
+		}
+		defer fd.Close()
+	}
+
+	if _, err := fd.Write(data); err != nil {
+		log.Fatal(err)
+	}
+
+	if output != "-" {
+		log.Infof("Imported %s to %s with namespace %s, %d warning(s)", file, output, namespace, len(warnings))
+	}
+}
+
+// importNamespace makes a namespace of a directory name the way docker-compose
+// makes project names: lowercased, with all characters but letters and digits removed
+func importNamespace(dir string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return -1
+	}, strings.ToLower(dir))
+}
+
+func snapshotCommand(ctx *cli.Context) {
+	initLogs(ctx)
+
+	var (
+		output = ctx.String("output")
+		names  = []string{}
+		fd     = os.Stdout
+	)
+
+	for _, value := range ctx.StringSlice("containers") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+
+	// do not mix logs with the result written to stdout
+	if output == "-" && !ctx.GlobalIsSet("verbose") {
+		log.SetLevel(log.WarnLevel)
+	}
+
+	dockerCli := initDockerClient(ctx)
+
+	manifest, err := compose.Snapshot(dockerCli, ctx.String("namespace"), names)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	data, err := yaml.Marshal(manifest)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if output != "-" {
+		if fd, err = os.Create(output); err != nil {
+			log.Fatal(err)
+		}
+		defer fd.Close()
+	}
+
+	if _, err := fd.Write(data); err != nil {
+		log.Fatal(err)
+	}
+
+	if output != "-" {
+		log.Infof("Saved manifest of %d container(s) of namespace %s to %s", len(manifest.Containers), manifest.Namespace, output)
+	}
+}
+
 func recoverCommand(ctx *cli.Context) {
 	initLogs(ctx)
 
@@ -444,13 +1285,13 @@ func initLogs(ctx *cli.Context) {
 
 	if ctx.GlobalBool("verbose") {
 		logger.Level = log.DebugLevel
-	} else if ctx.Bool("print") && ctx.GlobalString("log") == "" {
+	} else if (ctx.Bool("print") || ctx.Bool("print-lines") || ctx.Bool("print-resolved")) && ctx.GlobalString("log") == "" {
 		logger.Level = log.ErrorLevel
 	}
 
 	var (
 		err       error
-		isTerm    = log.IsTerminal()
+		isTerm    = term.IsTerminal(os.Stdout.Fd())
 		logFile   = ctx.GlobalString("log")
 		logExt    = path.Ext(logFile)
 		json      = ctx.GlobalBool("json") || logExt == ".json"
@@ -480,21 +1321,17 @@ func initLogs(ctx *cli.Context) {
 	}
 }
 
-func initComposeConfig(ctx *cli.Context, dockerCli *docker.Client) *config.Config {
-	file := ctx.String("file")
-
-	if file == "" {
-		log.Fatalf("Manifest file is empty")
-		os.Exit(1)
-	}
-
+// readComposeConfig reads and renders the manifest, it does not connect to docker
+func readComposeConfig(ctx *cli.Context, dockerCli *docker.Client) *config.Config {
 	var (
 		manifest *config.Config
 		err      error
 		bridgeIP *string
-		fd       io.Reader = os.Stdin
-		isTar              = ctx.Bool("tar")
-		print              = ctx.Bool("print")
+		fd       io.Reader
+		files    = manifestFiles(ctx)
+		fds      = []io.Reader{}
+		isTar    = ctx.Bool("tar")
+		print    = ctx.Bool("print") || ctx.Bool("print-lines") || ctx.Bool("print-resolved")
 	)
 
 	vars := initVars(ctx)
@@ -518,7 +1355,19 @@ func initComposeConfig(ctx *cli.Context, dockerCli *docker.Client) *config.Confi
 		},
 	}
 
-	if file != "-" {
+	for i, file := range files {
+		if file == "" {
+			log.Fatalf("Manifest file is empty")
+		}
+
+		if file == "-" {
+			if !print {
+				log.Infof("Reading manifest from STDIN")
+			}
+			fds = append(fds, os.Stdin)
+			continue
+		}
+
 		if !print {
 			log.Infof("Reading manifest: %s", file)
 		}
@@ -529,6 +1378,7 @@ func initComposeConfig(ctx *cli.Context, dockerCli *docker.Client) *config.Confi
 				log.Fatalf("Cannot get absolute path to %s due to error %s", file, err)
 			}
 			file = path.Join(wd, file)
+			files[i] = file
 		}
 
 		// Also detect tar input by extension
@@ -540,11 +1390,13 @@ func initComposeConfig(ctx *cli.Context, dockerCli *docker.Client) *config.Confi
 			log.Fatal(err)
 		}
 		defer fd.(io.ReadCloser).Close()
-	} else {
-		if !print {
-			log.Infof("Reading manifest from STDIN")
-		}
+		fds = append(fds, fd)
+	}
+
+	if isTar && len(files) > 1 {
+		log.Fatal("Tar archive cannot be merged with other manifest files")
 	}
+	fd = fds[0]
 
 	if isTar {
 		tr := tar.NewReader(fd)
@@ -600,13 +1452,63 @@ func initComposeConfig(ctx *cli.Context, dockerCli *docker.Client) *config.Confi
 		if prefixVars, ok := varsByPrefix[*composePrefix]; ok {
 			vars = template.Vars{}.Merge(prefixVars, vars)
 		}
+
+		fds[0] = fd
+	}
+
+	if ctx.Bool("print-lines") {
+		for i, file := range files {
+			if len(files) > 1 {
+				fmt.Printf("# %s\n", file)
+			}
+			if err := config.PrintRendered(os.Stdout, file, fds[i], vars, funcs); err != nil {
+				log.Fatal(err)
+			}
+		}
+		os.Exit(0)
 	}
 
-	manifest, err = config.ReadConfig(file, fd, vars, funcs, print)
+	manifest, err = config.ReadConfigs(files, fds, vars, funcs, ctx.Bool("print"))
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	// the manifest itself may turn on strict mode, then it is already checked by ReadConfig
+	if ctx.Bool("strict") && !manifest.Strict {
+		if err := manifest.CheckUnknownKeys(); err != nil {
+			log.Fatalf("Unknown properties in %s (strict mode):\n%s", strings.Join(files, " + "), err)
+		}
+	}
+
+	if ctx.Bool("print-resolved") {
+		data, err := yaml.Marshal(manifest.Resolved())
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Print(string(data))
+		os.Exit(0)
+	}
+
+	return manifest
+}
+
+// manifestFiles returns manifest files given by -f options. By default it is compose.yml,
+// merged with compose.override.yml in case it exists, same as docker-compose does.
+func manifestFiles(ctx *cli.Context) []string {
+	if files := ctx.StringSlice("file"); len(files) > 0 {
+		return append([]string{}, files...)
+	}
+	files := []string{"compose.yml"}
+	if _, err := os.Stat("compose.override.yml"); err == nil {
+		files = append(files, "compose.override.yml")
+	}
+	return files
+}
+
+// initComposeConfig reads the manifest and makes sure the docker daemon is reachable
+func initComposeConfig(ctx *cli.Context, dockerCli *docker.Client) *config.Config {
+	manifest := readComposeConfig(ctx, dockerCli)
+
 	// Timeout for docker daemon to respond after accepting connection
 	dockerCli.SetTimeout(ctx.GlobalDuration("docker-ping-timeout"))
 	defer dockerCli.SetTimeout(0 * time.Second)
@@ -629,8 +1531,28 @@ func initComposeConfig(ctx *cli.Context, dockerCli *docker.Client) *config.Confi
 	return manifest
 }
 
+// initCompose makes a Compose object for commands that manage existing containers of the manifest
+func initCompose(ctx *cli.Context) *compose.Compose {
+	dockerCli := initDockerClient(ctx)
+	config := initComposeConfig(ctx, dockerCli)
+	auth := initAuthConfig(ctx)
+
+	compose, err := compose.New(&compose.Config{
+		Manifest: config,
+		Docker:   dockerCli,
+		DryRun:   ctx.Bool("dry"),
+		Wait:     ctx.Duration("wait"),
+		Auth:     auth,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return compose
+}
+
 func initVars(c *cli.Context) template.Vars {
-	vars, err := template.VarsFromFileMulti(c.StringSlice("var-file"))
+	vars, err := config.ReadVarFiles(c.StringSlice("var-file"))
 	if err != nil {
 		log.Fatal(err)
 		os.Exit(1)
@@ -699,6 +1621,8 @@ func doRemove(ctx *cli.Context, config *config.Config, dockerCli *docker.Client,
 		DryRun:   ctx.Bool("dry"),
 		Remove:   true,
 		Auth:     auth,
+		Only:     ctx.StringSlice("only"),
+		Exclude:  ctx.StringSlice("exclude"),
 	})
 	if err != nil {
 		return err
@@ -727,6 +1651,24 @@ func toAbsolutePath(filePath string, shouldExist bool) (string, error) {
 	return filePath, nil
 }
 
+// parseSince parses the value of --since option, which is either an RFC3339 timestamp,
+// a unix timestamp or a duration relative to the current time
+func parseSince(str string) (time.Time, error) {
+	if str == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(str); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, str); err == nil {
+		return t, nil
+	}
+	if sec, err := strconv.ParseInt(str, 10, 64); err == nil {
+		return time.Unix(sec, 0), nil
+	}
+	return time.Time{}, fmt.Errorf("Cannot parse --since value `%s`, expected timestamp or duration", str)
+}
+
 // globalString fixes string arguments enclosed with double quotes
 // 'docker-machine config' gives such arguments
 func globalString(c *cli.Context, name string) string {
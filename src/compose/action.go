@@ -35,6 +35,8 @@ type ensureContainerExist action
 type ensureContainerState action
 type runContainer action
 type removeContainer action
+type stopContainer action
+type startContainer action
 type noAction action
 type waitContainerAction action
 
@@ -98,6 +100,16 @@ func NewRemoveContainerAction(c *Container) Action {
 	return &removeContainer{container: c}
 }
 
+// NewStopContainerAction makes action that stops a container without removing it
+func NewStopContainerAction(c *Container) Action {
+	return &stopContainer{container: c}
+}
+
+// NewStartContainerAction makes action that starts an existing container
+func NewStartContainerAction(c *Container) Action {
+	return &startContainer{container: c}
+}
+
 // Execute runs the step
 func (a *stepAction) Execute(client Client) (err error) {
 	if a.async {
@@ -170,6 +182,28 @@ func (a *removeContainer) String() string {
 	return fmt.Sprintf("Removing container '%s'", a.container.Name)
 }
 
+// Execute stops a container
+func (a *stopContainer) Execute(client Client) (err error) {
+	err = client.StopContainer(a.container)
+	return
+}
+
+// String returns the printable string representation of the stopContainer action.
+func (a *stopContainer) String() string {
+	return fmt.Sprintf("Stopping container '%s'", a.container.Name)
+}
+
+// Execute starts a container
+func (a *startContainer) Execute(client Client) (err error) {
+	err = client.StartContainer(a.container)
+	return
+}
+
+// String returns the printable string representation of the startContainer action.
+func (a *startContainer) String() string {
+	return fmt.Sprintf("Starting container '%s'", a.container.Name)
+}
+
 // Execute waits for a container
 func (a *waitContainerAction) Execute(client Client) (err error) {
 	return client.WaitForContainer(a.container)
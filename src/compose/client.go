@@ -30,8 +30,8 @@ import (
 	"github.com/grammarly/rocker/src/template"
 	"github.com/kr/pretty"
 
-	log "github.com/Sirupsen/logrus"
 	"github.com/fsouza/go-dockerclient"
+	log "github.com/sirupsen/logrus"
 )
 
 // Client interface describes a rocker-compose client that can do various operations
@@ -40,6 +40,8 @@ type Client interface {
 	GetContainers(global bool) ([]*Container, error)
 	RemoveContainer(container *Container) error
 	RunContainer(container *Container) error
+	StopContainer(container *Container) error
+	StartContainer(container *Container) error
 	EnsureContainerExist(name *Container) error
 	EnsureContainerState(name *Container) error
 	PullAll(containers []*Container, vars template.Vars) error
@@ -47,10 +49,15 @@ type Client interface {
 	AttachToContainers(container []*Container) error
 	AttachToContainer(container *Container) error
 	FetchImages(containers []*Container, vars template.Vars) error
+	InspectImages(containers []*Container, vars template.Vars) error
 	WaitForContainer(container *Container) error
 	GetPulledImages() []*imagename.ImageName
 	GetRemovedImages() []*imagename.ImageName
 	Pin(local, hub bool, vars template.Vars, containers []*Container) error
+	Logs(containers []*Container, options LogsOptions) error
+	Exec(container *Container, options ExecOptions) (int, error)
+	GetUnmanagedContainers(expected []*Container) ([]*Container, error)
+	AdoptContainers(containers []*Container) error
 }
 
 // DockerClient is an implementation of Client interface that do operations to a given docker client
@@ -62,8 +69,18 @@ type DockerClient struct {
 	KeepImages int
 	Recover    bool
 
+	// State is the store of adopted containers, unless it is given it is read
+	// from StateFile (~/.rocker-compose/state.json by default) on the first use.
+	// Failures to read it are errors only if StateRequired is set, otherwise
+	// they are logged and adopted containers are not recognized.
+	State         *StateStore
+	StateFile     string
+	StateRequired bool
+
 	pulledImages  []*imagename.ImageName
 	removedImages []*imagename.ImageName
+	stateLoaded   bool
+	stateErr      error
 }
 
 // ErrContainerBadState is an error that describes state inconsistency
@@ -97,6 +114,10 @@ func NewClient(initialClient *DockerClient) (*DockerClient, error) {
 		Auth:       initialClient.Auth,
 		KeepImages: initialClient.KeepImages,
 		Recover:    initialClient.Recover,
+
+		State:         initialClient.State,
+		StateFile:     initialClient.StateFile,
+		StateRequired: initialClient.StateRequired,
 	}
 	return client, nil
 }
@@ -104,6 +125,7 @@ func NewClient(initialClient *DockerClient) (*DockerClient, error) {
 // GetContainers implements the retrieval of existing containers from the docker daemon.
 // It fetches the list and then inspects every container in parallel (pmap).
 // Timeouts after 30 seconds if some inspect operations hanged.
+// Containers adopted by `run --adopt` get their specs from the state store.
 func (client *DockerClient) GetContainers(global bool) ([]*Container, error) {
 	filters := map[string][]string{}
 	if !global {
@@ -118,6 +140,16 @@ func (client *DockerClient) GetContainers(global bool) ([]*Container, error) {
 		return nil, err
 	}
 
+	state, err := client.state()
+	if err != nil {
+		return nil, err
+	}
+	if state != nil {
+		if apiContainers, err = client.listAdoptedContainers(state, global, apiContainers); err != nil {
+			return nil, err
+		}
+	}
+
 	containers := []*Container{}
 
 	if len(apiContainers) == 0 {
@@ -154,6 +186,9 @@ func (client *DockerClient) GetContainers(global bool) ([]*Container, error) {
 			if err != nil {
 				return nil, fmt.Errorf("Failed to initialize config container instance from docker api, error: %s", err)
 			}
+			if container.Config == nil && state != nil {
+				container.Config = state.Get(container.ID)
+			}
 			containers = append(containers, container)
 
 		case <-timeout:
@@ -169,8 +204,10 @@ func (client *DockerClient) GetContainers(global bool) ([]*Container, error) {
 func (client *DockerClient) RemoveContainer(container *Container) error {
 	log.Infof("Removing container %s id:%.12s", container.Name, container.ID)
 
-	if container.Config.KillTimeout != nil && *container.Config.KillTimeout > 0 {
-		if err := client.Docker.StopContainer(container.ID, *container.Config.KillTimeout); err != nil {
+	// docker sends the stop signal the container was created with and kills
+	// the container if it does not exit within the grace period
+	if err := client.Docker.StopContainer(container.ID, container.Config.StopTimeout()); err != nil {
+		if _, ok := err.(*docker.ContainerNotRunning); !ok {
 			return fmt.Errorf("Failed to stop container, error: %s", err)
 		}
 	}
@@ -184,6 +221,31 @@ func (client *DockerClient) RemoveContainer(container *Container) error {
 		return fmt.Errorf("Failed to remove container, error: %s", err)
 	}
 
+	state, err := client.state()
+	if err != nil {
+		return err
+	}
+	if state != nil && state.Remove(container.ID) {
+		if err := state.Save(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// StopContainer implements stopping a container without removing it. The container is given
+// stop_grace_period or kill_timeout (10 seconds by default) to exit before it is killed.
+func (client *DockerClient) StopContainer(container *Container) error {
+	log.Infof("Stopping container %s id:%.12s", container.Name, container.ID)
+
+	if err := client.Docker.StopContainer(container.ID, container.Config.StopTimeout()); err != nil {
+		if _, ok := err.(*docker.ContainerNotRunning); ok {
+			return nil
+		}
+		return fmt.Errorf("Failed to stop container, error: %s", err)
+	}
+
 	return nil
 }
 
@@ -408,6 +470,7 @@ func (client *DockerClient) AttachToContainer(container *Container) error {
 			Stdout:       true,
 			Stderr:       true,
 			Stream:       true,
+			RawTerminal:  container.isTty(),
 			Success:      success,
 		})
 	}()
@@ -481,6 +544,32 @@ func (client *DockerClient) FetchImages(containers []*Container, vars template.V
 	return client.pullImageForContainers(false, vars, containers...)
 }
 
+// InspectImages resolves image versions and ids of given containers by local images,
+// unlike FetchImages it never pulls; containers whose images are missing are left without ids
+func (client *DockerClient) InspectImages(containers []*Container, vars template.Vars) error {
+	if err := client.resolveVersions(true, false, vars, containers); err != nil {
+		return err
+	}
+
+	inspected := map[string]*docker.Image{}
+
+	for _, container := range containers {
+		img, ok := inspected[container.Image.String()]
+		if !ok {
+			var err error
+			if img, err = client.Docker.InspectImage(container.Image.String()); err == docker.ErrNoSuchImage {
+				continue
+			} else if err != nil {
+				return fmt.Errorf("Failed to inspect image %s for container %s, error: %s", container.Image, container.Name, err)
+			}
+			inspected[container.Image.String()] = img
+		}
+		container.ImageID = img.ID
+	}
+
+	return nil
+}
+
 // GetPulledImages returns the list of images pulled by a recent run
 func (client *DockerClient) GetPulledImages() []*imagename.ImageName {
 	return client.pulledImages
@@ -496,8 +585,157 @@ func (client *DockerClient) Pin(local, hub bool, vars template.Vars, containers
 	return client.resolveVersions(local, hub, vars, containers)
 }
 
+// GetUnmanagedContainers finds existing containers that have names of the given ones, but
+// were not created by rocker-compose nor adopted yet. Found containers have no spec, they
+// keep `docker inspect` data of the container and the config of its image to compare
+// with the expected spec by DiffAPIContainer.
+func (client *DockerClient) GetUnmanagedContainers(expected []*Container) ([]*Container, error) {
+	state, err := client.state()
+	if err != nil {
+		return nil, err
+	}
+
+	containers := []*Container{}
+
+	for _, container := range expected {
+		apiContainer, err := client.Docker.InspectContainer(container.Name.String())
+		if err != nil {
+			if _, ok := err.(*docker.NoSuchContainer); ok {
+				continue
+			}
+			return nil, fmt.Errorf("Failed to inspect container %s, error: %s", container.Name, err)
+		}
+
+		if _, ok := apiContainer.Config.Labels["rocker-compose-config"]; ok {
+			continue
+		}
+		if state != nil && state.Get(apiContainer.ID) != nil {
+			continue
+		}
+
+		unmanaged, err := NewContainerFromDocker(apiContainer)
+		if err != nil {
+			return nil, err
+		}
+
+		image, err := client.Docker.InspectImage(apiContainer.Image)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to inspect image %.12s of container %s, error: %s", apiContainer.Image, container.Name, err)
+		}
+		unmanaged.imageConfig = image.Config
+		unmanaged.unmanaged = true
+
+		containers = append(containers, unmanaged)
+	}
+
+	return containers, nil
+}
+
+// AdoptContainers records specs of the given containers in the state store,
+// so they are treated as if they were created by rocker-compose
+func (client *DockerClient) AdoptContainers(containers []*Container) error {
+	state, err := client.state()
+	if err != nil {
+		return err
+	}
+	if state == nil {
+		return fmt.Errorf("Cannot adopt containers, the state store is not initialized")
+	}
+	for _, container := range containers {
+		log.Infof("Adopting container %s id:%.12s", container.Name, container.ID)
+		if err := state.Add(container.ID, container.Name, container.Config); err != nil {
+			return fmt.Errorf("Failed to adopt container %s, error: %s", container.Name, err)
+		}
+	}
+	return state.Save()
+}
+
 // Internal
 
+// state returns the state store of adopted containers, reading it on the first call
+func (client *DockerClient) state() (*StateStore, error) {
+	if client.State == nil && !client.stateLoaded {
+		client.stateLoaded = true
+		client.State, client.stateErr = client.readState()
+		if client.stateErr != nil && !client.StateRequired {
+			log.Warnf("Adopted containers are not recognized, %s", client.stateErr)
+			client.stateErr = nil
+		}
+	}
+	return client.State, client.stateErr
+}
+
+// readState reads the state store of the daemon the client is connected to
+func (client *DockerClient) readState() (*StateStore, error) {
+	path := client.StateFile
+	if path == "" {
+		var err error
+		if path, err = DefaultStateFile(); err != nil {
+			return nil, err
+		}
+	}
+
+	info, err := client.Docker.Info()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get docker daemon info, error: %s", err)
+	}
+	daemon := info.ID
+	if daemon == "" {
+		daemon = client.Docker.Endpoint()
+	}
+
+	return NewStateStore(path, daemon)
+}
+
+// listAdoptedContainers adds adopted containers to the list, which has only containers
+// labeled by rocker-compose unless it is global. Records of containers that do not
+// exist anymore on the daemon are removed from the state store.
+func (client *DockerClient) listAdoptedContainers(state *StateStore, global bool, apiContainers []docker.APIContainers) ([]docker.APIContainers, error) {
+	ids := state.IDs()
+	if len(ids) == 0 {
+		return apiContainers, nil
+	}
+
+	listed := apiContainers
+	if !global {
+		var err error
+		listed, err = client.Docker.ListContainers(docker.ListContainersOptions{
+			All:     true,
+			Filters: map[string][]string{"id": ids},
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	existing := map[string]bool{}
+	for _, apiContainer := range apiContainers {
+		existing[apiContainer.ID] = true
+	}
+	found := map[string]bool{}
+	for _, apiContainer := range listed {
+		found[apiContainer.ID] = true
+		if !existing[apiContainer.ID] {
+			apiContainers = append(apiContainers, apiContainer)
+			existing[apiContainer.ID] = true
+		}
+	}
+
+	stale := []string{}
+	for _, id := range ids {
+		if !found[id] {
+			stale = append(stale, id)
+		}
+	}
+	if state.Remove(stale...) {
+		if err := state.Save(); err != nil {
+			return nil, err
+		}
+	}
+
+	return apiContainers, nil
+}
+
 func (client *DockerClient) listenReAttach(containers []*Container) {
 	// The code is partially borrowed from https://github.com/jwilder/docker-gen
 	eventChan := make(chan *docker.APIEvents, 100)
@@ -600,6 +838,7 @@ func (client *DockerClient) flushContainerLogs(container *Container) {
 		ErrorStream:  container.Io.Stderr,
 		Stdout:       true,
 		Stderr:       true,
+		RawTerminal:  container.isTty(),
 	})
 	if err2 != nil {
 		log.Errorf("Failed to read logs of container %s, error: %s", container.Name, err2)
@@ -23,13 +23,13 @@ import (
 	"testing"
 	"time"
 
-	log "github.com/Sirupsen/logrus"
 	"github.com/fsouza/go-dockerclient"
 	"github.com/grammarly/rocker/src/dockerclient"
 	"github.com/grammarly/rocker/src/imagename"
 	"github.com/grammarly/rocker/src/rocker/test"
 	"github.com/grammarly/rocker/src/template"
 	"github.com/kr/pretty"
+	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 )
 
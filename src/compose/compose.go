@@ -27,10 +27,10 @@ import (
 	"strings"
 	"time"
 
-	log "github.com/Sirupsen/logrus"
 	"github.com/fsouza/go-dockerclient"
 	"github.com/grammarly/rocker/src/template"
 	"github.com/kr/pretty"
+	log "github.com/sirupsen/logrus"
 )
 
 // Config is a configuration object which is passed to compose.New()
@@ -47,6 +47,9 @@ type Config struct {
 	Wait       time.Duration
 	Auth       *docker.AuthConfigurations
 	KeepImages int
+	Only       []string
+	Exclude    []string
+	Adopt      bool
 }
 
 // Compose is the main object that executes actions and holds runtime information.
@@ -57,11 +60,16 @@ type Compose struct {
 	Pull     bool
 	Remove   bool
 	Wait     time.Duration
+	Only     []string
+	Exclude  []string
+	Adopt    bool
 
 	client             Client
 	chErrors           chan error
 	attachedContainers map[string]struct{}
 	executionPlan      []Action
+	actual             []*Container
+	adopted            []*Container
 }
 
 // New makes a new Compose object
@@ -73,6 +81,9 @@ func New(config *Config) (*Compose, error) {
 		Pull:     config.Pull,
 		Wait:     config.Wait,
 		Remove:   config.Remove,
+		Only:     config.Only,
+		Exclude:  config.Exclude,
+		Adopt:    config.Adopt,
 	}
 
 	cliConf := &DockerClient{
@@ -82,6 +93,10 @@ func New(config *Config) (*Compose, error) {
 		Auth:       config.Auth,
 		KeepImages: config.KeepImages,
 		Recover:    config.Recover,
+
+		// adopted containers are recorded by `run --adopt` and their records
+		// are removed by `rm`, other commands can do without the state store
+		StateRequired: config.Adopt || config.Remove,
 	}
 
 	cli, err := NewClient(cliConf)
@@ -97,40 +112,9 @@ func New(config *Config) (*Compose, error) {
 
 // RunAction implements 'rocker-compose run'
 func (compose *Compose) RunAction() error {
-	// get the actual list of existing containers from docker client
-	actual, err := compose.client.GetContainers(compose.Manifest.HasExternalRefs())
+	expected, _, executionPlan, err := compose.diff()
 	if err != nil {
-		return fmt.Errorf("GetContainers failed with error, error: %s", err)
-	}
-
-	expected := []*Container{}
-
-	// if --remove was specified, pretend we expect to have an empty list of containers
-	if !compose.Remove {
-		expected = GetContainersFromConfig(compose.Manifest)
-	}
-
-	// if --pull is specified PullAll, otherwise Fetch required
-	if compose.Pull {
-		if err := compose.client.PullAll(expected, compose.Manifest.Vars); err != nil {
-			return err
-		}
-	} else if err := compose.client.FetchImages(expected, compose.Manifest.Vars); err != nil {
-		return fmt.Errorf("Failed to fetch images of given containers, error: %s", err)
-	}
-
-	// Assign IDs of existing containers
-	for _, actualC := range actual {
-		for _, expectedC := range expected {
-			if expectedC.IsSameKind(actualC) {
-				expectedC.ID = actualC.ID
-			}
-		}
-	}
-
-	executionPlan, err := NewDiff(compose.Manifest.Namespace).Diff(expected, actual)
-	if err != nil {
-		return fmt.Errorf("Diff of configuration failed, error: %s", err)
+		return err
 	}
 	compose.executionPlan = executionPlan
 
@@ -145,6 +129,12 @@ func (compose *Compose) RunAction() error {
 		return fmt.Errorf("Execution failed with, error: %s", err)
 	}
 
+	if len(compose.adopted) > 0 && !compose.DryRun {
+		if err := compose.client.AdoptContainers(compose.adopted); err != nil {
+			return err
+		}
+	}
+
 	strContainers := []string{}
 	for _, container := range expected {
 		// TODO: map ids for already existing containers
@@ -169,6 +159,253 @@ func (compose *Compose) RunAction() error {
 	return nil
 }
 
+// PlanAction implements 'rocker-compose plan'
+// It makes the same comparison as RunAction does, but instead of executing the
+// actions it explains what is going to be changed for every container and why.
+func (compose *Compose) PlanAction() (Plan, error) {
+	expected, actual, executionPlan, err := compose.diff()
+	if err != nil {
+		return nil, err
+	}
+	compose.executionPlan = executionPlan
+	compose.actual = actual
+
+	return NewPlan(compose.Manifest.Namespace, expected, actual, executionPlan), nil
+}
+
+// SavePlan returns the serializable form of the execution plan made by PlanAction,
+// which can be executed later by ApplyAction.
+func (compose *Compose) SavePlan() (*SavedPlan, error) {
+	return NewSavedPlan(compose.Manifest, compose.actual, compose.executionPlan)
+}
+
+// ApplyAction implements 'rocker-compose apply'
+// It executes exactly the actions of a plan saved by 'rocker-compose plan -out',
+// but refuses to do so if the state of containers has changed since the plan was made,
+// or if the manifest is given and it is not the one the plan was made of.
+func (compose *Compose) ApplyAction(plan *SavedPlan) error {
+	log.Infof("Applying plan for namespace %s, manifest hash %.12s", plan.Namespace, plan.ManifestHash)
+
+	if compose.Manifest != nil {
+		if err := plan.CheckManifest(compose.Manifest); err != nil {
+			return err
+		}
+	} else {
+		log.Warnf("Manifest is not given, the plan is not checked against it")
+	}
+
+	actual, err := compose.client.GetContainers(plan.Global)
+	if err != nil {
+		return fmt.Errorf("GetContainers failed with error, error: %s", err)
+	}
+
+	if err := plan.CheckDrift(actual); err != nil {
+		return err
+	}
+
+	executionPlan, err := plan.GetActions()
+	if err != nil {
+		return err
+	}
+	compose.executionPlan = executionPlan
+
+	// fetch images of containers that are going to be created and make sure
+	// tags still point to the same images they pointed when the plan was made
+	toCreate := []*Container{}
+	WalkActions(executionPlan, func(action Action) {
+		if a, ok := action.(*runContainer); ok {
+			toCreate = append(toCreate, a.container)
+		}
+	})
+
+	planned := map[*Container]string{}
+	for _, c := range toCreate {
+		planned[c] = c.ImageID
+	}
+
+	if err := compose.client.FetchImages(toCreate, template.Vars{}); err != nil {
+		return fmt.Errorf("Failed to fetch images of given containers, error: %s", err)
+	}
+
+	for _, c := range toCreate {
+		if planned[c] != "" && planned[c] != c.ImageID {
+			return ErrPlanDrifted{[]string{fmt.Sprintf("image %s of %s has changed (%.12s -> %.12s)",
+				c.Image, c.Name, planned[c], c.ImageID)}}
+		}
+	}
+
+	var runner Runner
+	if compose.DryRun {
+		runner = NewDryRunner()
+	} else {
+		runner = NewDockerClientRunner(compose.client)
+	}
+
+	if err := runner.Run(executionPlan); err != nil {
+		return fmt.Errorf("Execution failed with, error: %s", err)
+	}
+
+	log.Infof("OK, plan is applied")
+
+	return nil
+}
+
+// StatusAction implements 'rocker-compose status'
+// It returns desired vs actual state of every container of the manifest
+// and containers of the namespace that are not in the manifest anymore.
+func (compose *Compose) StatusAction() (Status, error) {
+	actual, err := compose.client.GetContainers(false)
+	if err != nil {
+		return nil, fmt.Errorf("GetContainers failed with error, error: %s", err)
+	}
+
+	expected := GetContainersFromConfig(compose.Manifest)
+
+	// status is informational, so do not fail if some images cannot be resolved;
+	// images are looked up locally only, ids are needed to find out updated images
+	if err := compose.client.InspectImages(expected, compose.Manifest.Vars); err != nil {
+		log.Warnf("Failed to resolve images, error: %s", err)
+	}
+
+	return NewStatus(compose.Manifest.Namespace, expected, actual), nil
+}
+
+// LogsAction implements 'rocker-compose logs'
+// It reads logs of the given containers of the manifest, or all of them if no names are given.
+func (compose *Compose) LogsAction(names []string, options LogsOptions) error {
+	containers, err := selectContainers(compose.Manifest.Namespace, GetContainersFromConfig(compose.Manifest), names)
+	if err != nil {
+		return err
+	}
+
+	actual, err := compose.client.GetContainers(false)
+	if err != nil {
+		return fmt.Errorf("GetContainers failed with error, error: %s", err)
+	}
+
+	// Assign IDs of existing containers
+	for _, actualC := range actual {
+		for _, c := range containers {
+			if c.IsSameKind(actualC) {
+				c.ID = actualC.ID
+			}
+		}
+	}
+
+	return compose.client.Logs(containers, options)
+}
+
+// StopAction implements 'rocker-compose stop'
+// It stops running containers of the manifest, or only the given ones, in the reverse
+// dependency order. Containers are not removed, so they can be started again.
+func (compose *Compose) StopAction(names []string) error {
+	steps, err := compose.dependencySteps(names)
+	if err != nil {
+		return err
+	}
+
+	return compose.runActions(stopActions(steps))
+}
+
+// StartAction implements 'rocker-compose start'
+// It starts stopped containers of the manifest, or only the given ones, in the dependency
+// order. Only containers that are declared as running are started, nothing is recreated.
+func (compose *Compose) StartAction(names []string) error {
+	steps, err := compose.dependencySteps(names)
+	if err != nil {
+		return err
+	}
+
+	expected := GetContainersFromConfig(compose.Manifest)
+
+	return compose.runActions(startActions(steps, expected, false))
+}
+
+// RestartAction implements 'rocker-compose restart'
+// It stops containers in the reverse dependency order and then starts them in the dependency order.
+func (compose *Compose) RestartAction(names []string) error {
+	steps, err := compose.dependencySteps(names)
+	if err != nil {
+		return err
+	}
+
+	expected := GetContainersFromConfig(compose.Manifest)
+
+	return compose.runActions(append(stopActions(steps), startActions(steps, expected, true)...))
+}
+
+// GraphAction implements 'rocker-compose graph'
+// It returns the graph of dependencies between containers of the manifest,
+// docker connection is not needed for it.
+func (compose *Compose) GraphAction() (*DependencyGraph, error) {
+	containers := GetContainersFromConfig(compose.Manifest)
+
+	// the graph can be drawn anyway, cycles are what one may want to see on it
+	if err := CheckDependencyCycles(compose.Manifest.Namespace, containers); err != nil {
+		log.Warn(err)
+	}
+
+	return NewDependencyGraph(compose.Manifest.Namespace, containers)
+}
+
+// ValidateAction implements 'rocker-compose validate'
+// It checks the manifest and dependencies between its containers, docker connection is not needed for it.
+// Returns config.ValidationErrors in case any problems are found.
+func (compose *Compose) ValidateAction() error {
+	var (
+		ns         = compose.Manifest.Namespace
+		errs       = compose.Manifest.Validate()
+		containers = GetContainersFromConfig(compose.Manifest)
+	)
+
+	if err := CheckDependencyCycles(ns, containers); err != nil {
+		if cyclesErr, ok := err.(ErrDependencyCycles); ok {
+			for _, cycle := range cyclesErr.Cycles {
+				first := strings.SplitN(cycle, " ", 2)[0]
+				errs = append(errs, &config.ValidationError{
+					Container: strings.TrimPrefix(first, ns+"."),
+					Field:     "dependencies",
+					Message:   "dependency cycle " + cycle,
+				})
+			}
+		} else if len(errs) == 0 {
+			// missing dependencies are already reported by the manifest validation
+			return err
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// ExecAction implements 'rocker-compose exec'
+// It runs a command in a running container that is referred by its name in the manifest,
+// or by its full name "namespace.name" in case of a container from another namespace.
+// Returns the exit code of the command.
+func (compose *Compose) ExecAction(name string, options ExecOptions) (int, error) {
+	containerName, err := resolveContainerName(compose.Manifest, name)
+	if err != nil {
+		return -1, err
+	}
+
+	actual, err := compose.client.GetContainers(containerName.Namespace != compose.Manifest.Namespace)
+	if err != nil {
+		return -1, fmt.Errorf("GetContainers failed with error, error: %s", err)
+	}
+
+	container := find(actual, containerName)
+	if container == nil {
+		return -1, fmt.Errorf("Container %s does not exist", containerName)
+	}
+	if !container.State.Running {
+		return -1, fmt.Errorf("Container %s (%.12s) is not running", containerName, container.ID)
+	}
+
+	return compose.client.Exec(container, options)
+}
+
 // RecoverAction implements 'rocker-compose recover'
 //
 // TODO: It duplicates the code of RunAction a bit. Also, do we need this function at all?
@@ -225,7 +462,11 @@ func (compose *Compose) RecoverAction() error {
 
 // PullAction implements 'rocker-compose pull'
 func (compose *Compose) PullAction() error {
-	containers := GetContainersFromConfig(compose.Manifest)
+	containers, err := filterContainers(compose.Manifest.Namespace, GetContainersFromConfig(compose.Manifest),
+		compose.Only, compose.Exclude, true)
+	if err != nil {
+		return err
+	}
 	if err := compose.client.PullAll(containers, compose.Manifest.Vars); err != nil {
 		return fmt.Errorf("Failed to pull all images, error: %s", err)
 	}
@@ -243,6 +484,8 @@ func (compose *Compose) CleanAction() error {
 }
 
 // PinAction implements 'rocker-compose pin'
+// It returns only the resolved versions as `v_container_<name>` variables, so they can be
+// written to a file and given back to 'rocker-compose run --var-file'.
 func (compose *Compose) PinAction(local, hub bool) (template.Vars, error) {
 	containers := GetContainersFromConfig(compose.Manifest)
 	if err := compose.client.Pin(local, hub, compose.Manifest.Vars, containers); err != nil {
@@ -250,12 +493,17 @@ func (compose *Compose) PinAction(local, hub bool) (template.Vars, error) {
 	}
 
 	// Populate versions to the variables
-	vars := compose.Manifest.Vars
+	pinned := template.Vars{}
 	for _, c := range containers {
-		vars[fmt.Sprintf("v_container_%s", c.Name.Name)] = c.Image.GetTag()
+		pinned[fmt.Sprintf("v_container_%s", c.Name.Name)] = c.Image.GetTag()
 	}
 
-	return vars, nil
+	if compose.Manifest.Vars == nil {
+		compose.Manifest.Vars = template.Vars{}
+	}
+	compose.Manifest.Vars.Merge(pinned)
+
+	return pinned, nil
 }
 
 // WritePlan saves various rocker-compose change information to the ansible.Response object
@@ -294,3 +542,271 @@ func (compose *Compose) WritePlan(resp *ansible.Response) *ansible.Response {
 	resp.Changed = len(resp.Removed)+len(resp.Created)+len(resp.Pulled) > 0
 	return resp
 }
+
+// diff fetches the actual list of containers and images of the expected ones and
+// compares them, returning the list of actions needed to get to the expected state.
+func (compose *Compose) diff() (expected, actual []*Container, executionPlan []Action, err error) {
+	// get the actual list of existing containers from docker client
+	actual, err = compose.client.GetContainers(compose.Manifest.HasExternalRefs())
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("GetContainers failed with error, error: %s", err)
+	}
+
+	expected = []*Container{}
+
+	// if --only or --exclude was specified, select containers to touch; dependencies
+	// are selected as well unless we are removing containers
+	selected, err := filterContainers(compose.Manifest.Namespace, GetContainersFromConfig(compose.Manifest),
+		compose.Only, compose.Exclude, !compose.Remove)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// containers of the namespace out of the selection must not be touched,
+	// so they are not seen as obsolete ones
+	if len(compose.Only) > 0 || len(compose.Exclude) > 0 {
+		actual = keepSelected(compose.Manifest.Namespace, actual, selected)
+	}
+
+	// if --remove was specified, pretend we expect to have an empty list of containers
+	if !compose.Remove {
+		expected = selected
+	}
+
+	// if --pull is specified PullAll, otherwise Fetch required
+	if compose.Pull {
+		if err = compose.client.PullAll(expected, compose.Manifest.Vars); err != nil {
+			return nil, nil, nil, err
+		}
+	} else if err = compose.client.FetchImages(expected, compose.Manifest.Vars); err != nil {
+		return nil, nil, nil, fmt.Errorf("Failed to fetch images of given containers, error: %s", err)
+	}
+
+	// if --adopt was specified, compare containers created by other tools with the manifest
+	if compose.Adopt {
+		if actual, err = compose.adoptContainers(expected, actual); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+
+	// Assign IDs of existing containers
+	for _, actualC := range actual {
+		for _, expectedC := range expected {
+			if expectedC.IsSameKind(actualC) {
+				expectedC.ID = actualC.ID
+			}
+		}
+	}
+
+	executionPlan, err = NewDiff(compose.Manifest.Namespace).Diff(expected, actual)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("Diff of configuration failed, error: %s", err)
+	}
+
+	return expected, actual, executionPlan, nil
+}
+
+// adoptContainers finds containers that have names of expected ones but were not created
+// by rocker-compose, and adds them to the list of actual containers. Containers that are
+// equal to the manifest are taken over as they are, their specs are recorded in the state
+// store after the run. Others get an empty spec, so they are recreated.
+func (compose *Compose) adoptContainers(expected, actual []*Container) ([]*Container, error) {
+	unmanaged, err := compose.client.GetUnmanagedContainers(expected)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get containers to adopt, error: %s", err)
+	}
+
+	compose.adopted = []*Container{}
+
+	for _, u := range unmanaged {
+		for _, e := range expected {
+			if !e.IsSameKind(u) {
+				continue
+			}
+
+			diffs := e.Config.DiffAPIContainer(u.container, u.imageConfig)
+			if len(diffs) == 0 {
+				u.Config, u.unmanaged = e.Config, false
+				for _, diff := range e.Diff(u) {
+					diffs = append(diffs, diff.Field)
+				}
+			}
+
+			if len(diffs) == 0 {
+				log.Infof("Container %s id:%.12s is equal to the manifest, adopting it", u.Name, u.ID)
+				compose.adopted = append(compose.adopted, u)
+			} else {
+				log.Infof("Container %s id:%.12s differs from the manifest by %s, it will be recreated",
+					u.Name, u.ID, strings.Join(diffs, ", "))
+				// it is removed the same way as containers of the manifest
+				u.Config = &config.Container{
+					KillTimeout:     e.Config.KillTimeout,
+					StopGracePeriod: e.Config.StopGracePeriod,
+					KeepVolumes:     e.Config.KeepVolumes,
+				}
+				u.unmanaged = true
+			}
+		}
+
+		// global listing has the container already, but without a spec
+		replaced := false
+		for i, a := range actual {
+			if a.ID == u.ID {
+				actual[i], replaced = u, true
+			}
+		}
+		if !replaced {
+			actual = append(actual, u)
+		}
+	}
+
+	return actual, nil
+}
+
+// dependencySteps returns existing containers of the manifest, or only the given ones,
+// grouped by steps in the dependency order of the manifest
+func (compose *Compose) dependencySteps(names []string) ([][]*Container, error) {
+	ns := compose.Manifest.Namespace
+
+	actual, err := compose.client.GetContainers(compose.Manifest.HasExternalRefs())
+	if err != nil {
+		return nil, fmt.Errorf("GetContainers failed with error, error: %s", err)
+	}
+
+	expected := GetContainersFromConfig(compose.Manifest)
+
+	selected, err := selectContainers(ns, expected, names)
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := dependencyOrder(ns, expected, actual)
+	if err != nil {
+		return nil, err
+	}
+
+	steps := [][]*Container{}
+	for _, step := range order {
+		containers := []*Container{}
+		for _, e := range step {
+			if find(selected, e.Name) == nil {
+				continue
+			}
+			a := find(actual, e.Name)
+			if a == nil {
+				log.Warnf("Container %s does not exist, use `rocker-compose run` to create it", e.Name)
+				continue
+			}
+			if a.Config == nil {
+				log.Warnf("Container %s was not created by rocker-compose, use `rocker-compose run -adopt` to take it over", e.Name)
+				continue
+			}
+			containers = append(containers, a)
+		}
+		if len(containers) > 0 {
+			steps = append(steps, containers)
+		}
+	}
+
+	return steps, nil
+}
+
+// runActions executes given actions with either dry or docker runner
+func (compose *Compose) runActions(actions []Action) error {
+	compose.executionPlan = actions
+
+	var runner Runner
+	if compose.DryRun {
+		runner = NewDryRunner()
+	} else {
+		runner = NewDockerClientRunner(compose.client)
+	}
+
+	if err := runner.Run(actions); err != nil {
+		return fmt.Errorf("Execution failed with, error: %s", err)
+	}
+
+	return nil
+}
+
+// stopActions makes actions that stop running containers step by step in the reverse order
+func stopActions(steps [][]*Container) []Action {
+	actions := []Action{}
+	for i := len(steps) - 1; i >= 0; i-- {
+		step := []Action{}
+		for _, c := range steps[i] {
+			if c.State.Running {
+				step = append(step, NewStopContainerAction(c))
+			}
+		}
+		actions = append(actions, NewStepAction(true, step...))
+	}
+	return actions
+}
+
+// startActions makes actions that start containers declared as running by the expected
+// containers step by step; actual containers may have no spec if they were created by other
+// tools. Containers that are running already are skipped unless 'stopped' is true,
+// which means that they are stopped by previous actions
+func startActions(steps [][]*Container, expected []*Container, stopped bool) []Action {
+	actions := []Action{}
+	for _, containers := range steps {
+		step := []Action{}
+		for _, c := range containers {
+			e := find(expected, c.Name)
+			if e == nil || e.Config == nil {
+				log.Debugf("Skip starting container %s that is not in the manifest", c.Name)
+				continue
+			}
+			if !e.Config.State.Bool() {
+				log.Debugf("Skip starting container %s of state %s", c.Name, *e.Config.State)
+				continue
+			}
+			if c.State.Running && !stopped {
+				continue
+			}
+			step = append(step, NewStartContainerAction(c))
+		}
+		actions = append(actions, NewStepAction(true, step...))
+	}
+	return actions
+}
+
+// selectContainers picks containers by given names, which can be either short names
+// of the containers in the namespace or full names like "namespace.name".
+// Returns all containers if no names are given.
+func selectContainers(ns string, containers []*Container, names []string) ([]*Container, error) {
+	if len(names) == 0 {
+		return containers, nil
+	}
+
+	selected := []*Container{}
+	for _, str := range names {
+		name := config.NewContainerNameFromString(str)
+		name.DefaultNamespace(ns)
+
+		c := find(containers, name)
+		if c == nil {
+			return nil, fmt.Errorf("Container %s is not found in the manifest", name)
+		}
+		selected = append(selected, c)
+	}
+
+	return selected, nil
+}
+
+// resolveContainerName makes a full container name of a short name of the container
+// in the manifest. Names of other namespaces are given as "namespace.name" and are not
+// checked against the manifest.
+func resolveContainerName(manifest *config.Config, str string) (*config.ContainerName, error) {
+	name := config.NewContainerNameFromString(str)
+	name.DefaultNamespace(manifest.Namespace)
+
+	if name.Namespace == manifest.Namespace {
+		if _, ok := manifest.Containers[name.Name]; !ok {
+			return nil, fmt.Errorf("Container %s is not found in the manifest", name)
+		}
+	}
+
+	return name, nil
+}
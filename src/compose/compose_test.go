@@ -0,0 +1,286 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compose
+
+import (
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/grammarly/rocker-compose/src/compose/config"
+	"github.com/grammarly/rocker/src/template"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestComposePinAction(t *testing.T) {
+	image := "redis:2.8.19"
+	manifest := &config.Config{
+		Namespace: "test",
+		Containers: map[string]*config.Container{
+			"redis": &config.Container{Image: &image},
+		},
+		Vars: template.Vars{"foo": "bar"},
+	}
+
+	client := &clientMock{}
+	client.On("Pin", true, false, manifest.Vars, mock.Anything).Return(nil)
+
+	compose := &Compose{Manifest: manifest, client: client}
+
+	pinned, err := compose.PinAction(true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// only pinned versions should be returned, not the whole set of variables
+	assert.Equal(t, template.Vars{"v_container_redis": "2.8.19"}, pinned)
+	assert.Equal(t, "2.8.19", manifest.Vars["v_container_redis"])
+	client.AssertExpectations(t)
+}
+
+func TestComposeStatusActionImageID(t *testing.T) {
+	image := "redis:2.8.19"
+	manifest := &config.Config{
+		Namespace: "test",
+		Containers: map[string]*config.Container{
+			"redis": &config.Container{Image: &image},
+		},
+	}
+
+	actual := GetContainersFromConfig(manifest)
+	actual[0].ID = "a1"
+	actual[0].ImageID = "sha256:1111111111111111"
+
+	client := &clientMock{}
+	client.On("GetContainers").Return(actual, nil)
+	client.On("InspectImages", mock.Anything, manifest.Vars).Return(nil).Run(func(args mock.Arguments) {
+		for _, c := range args.Get(0).([]*Container) {
+			c.ImageID = "sha256:2222222222222222"
+		}
+	})
+
+	compose := &Compose{Manifest: manifest, client: client}
+
+	status, err := compose.StatusAction()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the tag now points to another image, so the container is to be recreated
+	assert.Len(t, status, 1)
+	assert.Equal(t, StatusDrifted, status[0].Sync)
+	assert.Equal(t, []string{"image_id"}, status[0].Changes)
+	client.AssertExpectations(t)
+}
+
+func TestSelectContainers(t *testing.T) {
+	containers := []*Container{
+		newContainer("test", "main"),
+		newContainer("test", "db"),
+		newContainer("other", "main"),
+	}
+
+	selected, err := selectContainers("test", containers, []string{})
+	assert.Nil(t, err)
+	assert.Equal(t, containers, selected)
+
+	selected, err = selectContainers("test", containers, []string{"db", "other.main"})
+	assert.Nil(t, err)
+	assert.Equal(t, []*Container{containers[1], containers[2]}, selected)
+
+	_, err = selectContainers("test", containers, []string{"web"})
+	assert.EqualError(t, err, "Container test.web is not found in the manifest")
+}
+
+func TestResolveContainerName(t *testing.T) {
+	manifest := &config.Config{
+		Namespace: "test",
+		Containers: map[string]*config.Container{
+			"api": &config.Container{},
+		},
+	}
+
+	name, err := resolveContainerName(manifest, "api")
+	assert.Nil(t, err)
+	assert.Equal(t, "test.api", name.String())
+
+	name, err = resolveContainerName(manifest, "other.api")
+	assert.Nil(t, err)
+	assert.Equal(t, "other.api", name.String())
+
+	_, err = resolveContainerName(manifest, "web")
+	assert.EqualError(t, err, "Container test.web is not found in the manifest")
+}
+
+func TestStopStartActions(t *testing.T) {
+	var created config.State = "created"
+
+	c1 := newContainer("test", "1")
+	c2 := newContainer("test", "2")
+	c3 := newContainer("test", "3")
+	c3.State.Running = false
+	c4 := newContainer("test", "4")
+	c4.Config.State = &created
+	c4.State.Running = false
+
+	expected := []*Container{c1, c2, c3, c4}
+
+	// the desired state is taken from the manifest, actual containers created
+	// by other tools have no spec
+	a3 := newContainer("test", "3")
+	a3.Config = nil
+	a3.State.Running = false
+	a4 := newContainer("test", "4")
+	a4.Config = nil
+	a4.State.Running = false
+
+	steps := [][]*Container{{c1, a4}, {c2, a3}}
+
+	client := &clientMock{}
+	runner := NewDockerClientRunner(client)
+
+	var (
+		order []string
+		mu    sync.Mutex
+	)
+	record := func(args mock.Arguments) {
+		mu.Lock()
+		defer mu.Unlock()
+		order = append(order, args.Get(0).(*Container).Name.Name)
+	}
+
+	// stop goes in the reverse order and skips containers that are not running
+	client.On("StopContainer", mock.Anything).Return(nil).Run(record)
+	assert.Nil(t, runner.Run(stopActions(steps)))
+	assert.Equal(t, []string{"2", "1"}, order)
+
+	// start skips running containers and containers that are not declared as running
+	order = []string{}
+	client.On("StartContainer", mock.Anything).Return(nil).Run(record)
+	assert.Nil(t, runner.Run(startActions(steps, expected, false)))
+	assert.Equal(t, []string{"3"}, order)
+
+	// restart starts containers that were running before
+	order = []string{}
+	assert.Nil(t, runner.Run(startActions(steps, expected, true)))
+	sort.Strings(order[1:]) // containers of the same step are started concurrently
+	assert.Equal(t, []string{"1", "2", "3"}, order)
+}
+
+func TestComposeStartActionUnmanaged(t *testing.T) {
+	var ran config.State = "ran"
+	image := "nginx:1.9"
+	manifest := &config.Config{
+		Namespace: "test",
+		Containers: map[string]*config.Container{
+			"web":   &config.Container{Image: &image},
+			"setup": &config.Container{Image: &image, State: &ran},
+			"db":    &config.Container{Image: &image},
+		},
+	}
+
+	actual := GetContainersFromConfig(manifest)
+	for _, c := range actual {
+		c.ID = c.Name.Name
+		c.State.Running = false
+	}
+	// a container of the same name created by another tool has no spec
+	find(actual, config.NewContainerName("test", "db")).Config = nil
+
+	client := &clientMock{}
+	client.On("GetContainers").Return(actual, nil)
+	client.On("StartContainer", mock.Anything).Return(nil)
+
+	compose := &Compose{Manifest: manifest, client: client}
+	assert.Nil(t, compose.StartAction(nil))
+
+	client.AssertNumberOfCalls(t, "StartContainer", 1)
+	assert.Equal(t, "web", client.Calls[1].Arguments.Get(0).(*Container).Name.Name)
+}
+
+func TestComposeValidateAction(t *testing.T) {
+	image := "busybox:latest"
+	manifest := &config.Config{
+		Namespace: "test",
+		Containers: map[string]*config.Container{
+			"a": &config.Container{Image: &image, Links: config.Links{
+				config.Link{ContainerName: config.ContainerName{Namespace: "test", Name: "b"}},
+			}},
+			"b": &config.Container{Image: &image, WaitFor: config.ContainerNames{
+				config.ContainerName{Namespace: "test", Name: "a"},
+			}},
+		},
+	}
+
+	compose := &Compose{Manifest: manifest}
+
+	err := compose.ValidateAction()
+	assert.EqualError(t, err, "container `a`, field `dependencies`: dependency cycle test.a -links-> test.b -wait_for-> test.a")
+}
+
+func TestComposeAdoptContainers(t *testing.T) {
+	image := "nginx:1.9"
+	web := NewContainerFromConfig(config.NewContainerName("test", "web"), &config.Container{Image: &image})
+	db := NewContainerFromConfig(config.NewContainerName("test", "db"), &config.Container{Image: &image})
+	expected := []*Container{web, db}
+
+	unmanaged := func(name string, restart string) *Container {
+		container, err := NewContainerFromDocker(&docker.Container{
+			ID:    name + "-id",
+			Name:  "/test." + name,
+			State: docker.State{Running: true},
+			Config: &docker.Config{
+				Image: image,
+				Cmd:   []string{"nginx"},
+			},
+			HostConfig: &docker.HostConfig{
+				RestartPolicy: docker.RestartPolicy{Name: restart},
+				LogConfig: docker.LogConfig{Type: "json-file", Config: map[string]string{
+					"max-file": "5",
+					"max-size": "100m",
+				}},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		container.unmanaged = true
+		container.imageConfig = &docker.Config{Cmd: []string{"nginx"}}
+		return container
+	}
+	uWeb, uDb := unmanaged("web", "always"), unmanaged("db", "no")
+
+	client := &clientMock{}
+	client.On("GetUnmanagedContainers", expected).Return([]*Container{uWeb, uDb}, nil)
+
+	compose := &Compose{Manifest: &config.Config{Namespace: "test"}, client: client}
+
+	actual, err := compose.adoptContainers(expected, []*Container{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []*Container{uWeb, uDb}, actual)
+	assert.Equal(t, []*Container{uWeb}, compose.adopted)
+
+	// equal container is taken over, the other one is recreated
+	assert.True(t, web.IsEqualTo(uWeb))
+	assert.False(t, db.IsEqualTo(uDb))
+	client.AssertExpectations(t)
+}
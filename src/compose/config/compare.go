@@ -17,8 +17,10 @@
 package config
 
 import (
+	"fmt"
 	"reflect"
 	"sort"
+	"strings"
 
 	"github.com/go-yaml/yaml"
 )
@@ -42,6 +44,31 @@ func (a *Container) IsEqualTo(b *Container) bool {
 	return true
 }
 
+// FieldDiff describes a single property that differs between two container specs.
+// Old and New values are YAML representations, empty string means the property is not set.
+type FieldDiff struct {
+	Field string
+	Old   string
+	New   string
+}
+
+// Diff compares the container spec against an older one by every comparable property
+// and returns the list of differences. Unlike IsEqualTo, it does not stop on the first
+// unequal property, so it can be used to explain why the container is going to change.
+func (a *Container) Diff(old *Container) []FieldDiff {
+	diffs := []FieldDiff{}
+	for _, field := range getComparableFields() {
+		if equal, _ := compareYaml(field, a, old); !equal {
+			diffs = append(diffs, FieldDiff{
+				Field: getYamlFieldName(field),
+				Old:   yamlFieldString(field, old),
+				New:   yamlFieldString(field, a),
+			})
+		}
+	}
+	return diffs
+}
+
 // IsEqualTo compares the ContainerName against another one.
 // namespace and name should be same.
 func (a *ContainerName) IsEqualTo(b *ContainerName) bool {
@@ -95,6 +122,29 @@ func compareYaml(name string, a, b *Container) (bool, error) {
 	return string(yml1) == string(yml2), nil
 }
 
+// yamlFieldString returns YAML representation of the container spec property
+// or an empty string if it is not set
+func yamlFieldString(name string, c *Container) string {
+	v := reflect.Indirect(reflect.ValueOf(c)).FieldByName(name)
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return ""
+		}
+	case reflect.Slice, reflect.Map:
+		if v.Len() == 0 {
+			return ""
+		}
+	}
+
+	yml, err := yaml.Marshal(v.Interface())
+	if err != nil {
+		return fmt.Sprintf("<%s>", err)
+	}
+	return strings.TrimSpace(string(yml))
+}
+
 type yamlSortable []interface{}
 
 func newYamlSortable(slice reflect.Value) yamlSortable {
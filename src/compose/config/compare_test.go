@@ -79,7 +79,7 @@ func TestConfigIsEqualTo(t *testing.T) {
 	cases := tests{
 		// type: string
 		fieldSpec{
-			[]string{"Pid", "Uts", "CpusetCpus", "Hostname", "Domainname", "User", "Workdir", "LogDriver"},
+			[]string{"Pid", "Uts", "CpusetCpus", "Hostname", "Domainname", "User", "Workdir", "LogDriver", "UsernsMode", "StopSignal"},
 			[]check{
 				check{shouldEqual, "KEY: foo", "KEY: foo"},
 				check{shouldEqual, "", ""},
@@ -92,7 +92,7 @@ func TestConfigIsEqualTo(t *testing.T) {
 		},
 		// type: numbers
 		fieldSpec{
-			[]string{"CPUShares"},
+			[]string{"CPUShares", "CPUQuota", "CPUPeriod", "PidsLimit", "OomScoreAdj", "BlkioWeight"},
 			[]check{
 				check{shouldEqual, "KEY: 20", "KEY: 20"},
 				check{shouldEqual, "", ""},
@@ -103,9 +103,21 @@ func TestConfigIsEqualTo(t *testing.T) {
 				check{shouldNotEqual, "", "KEY: 30"},
 			},
 		},
+		// type: float
+		fieldSpec{
+			[]string{"CPUs"},
+			[]check{
+				check{shouldEqual, "KEY: 1.5", "KEY: 1.5"},
+				check{shouldEqual, "KEY: 2", "KEY: 2.0"},
+				check{shouldEqual, "", ""},
+				check{shouldNotEqual, "KEY: 1.5", ""},
+				check{shouldNotEqual, "", "KEY: 0.5"},
+				check{shouldNotEqual, "KEY: 1.5", "KEY: 0.5"},
+			},
+		},
 		// type: booleans
 		fieldSpec{
-			[]string{"OomKillDisable", "Privileged", "PublishAllPorts"},
+			[]string{"OomKillDisable", "Privileged", "PublishAllPorts", "ReadOnly", "Tty", "StdinOpen", "Init"},
 			[]check{
 				check{shouldEqual, "KEY: true", "KEY: true"},
 				check{shouldEqual, "", ""},
@@ -128,9 +140,22 @@ func TestConfigIsEqualTo(t *testing.T) {
 				check{shouldNotEqual, "", "KEY: bridge"},
 			},
 		},
+		// type: Ipc
+		fieldSpec{
+			[]string{"Ipc"},
+			[]check{
+				check{shouldEqual, "KEY: host", "KEY: host"},
+				check{shouldEqual, "KEY: container:db", "KEY: container:db"},
+				check{shouldEqual, "", ""},
+				check{shouldNotEqual, "KEY: host", ""},
+				check{shouldNotEqual, "", "KEY: shareable"},
+				check{shouldNotEqual, "KEY: container:db", "KEY: container:web"},
+				check{shouldNotEqual, "KEY: container:db", "KEY: host"},
+			},
+		},
 		// type: ConfigMemory
 		fieldSpec{
-			[]string{"Memory", "MemorySwap"},
+			[]string{"Memory", "MemorySwap", "MemoryReservation", "KernelMemory", "ShmSize"},
 			[]check{
 				check{shouldEqual, "KEY: 64m", "KEY: 64m"},
 				check{shouldEqual, "KEY: 1024m", "KEY: 1g"},
@@ -142,7 +167,7 @@ func TestConfigIsEqualTo(t *testing.T) {
 		},
 		// type: []string
 		fieldSpec{
-			[]string{"DNS", "AddHost", "Expose", "Volumes", "VolumesFrom", "Links", "WaitFor", "Ports"},
+			[]string{"DNS", "AddHost", "Expose", "Volumes", "VolumesFrom", "Links", "WaitFor", "Ports", "CapAdd", "CapDrop", "Devices", "SecurityOpt", "GroupAdd", "DeviceReadBps", "DeviceWriteBps", "Tmpfs"},
 			[]check{
 				check{shouldEqual, "", ""},
 				check{shouldEqual, "KEY:\n  - foo", "KEY:\n  - foo"},
@@ -196,7 +221,7 @@ func TestConfigIsEqualTo(t *testing.T) {
 		},
 		// type: map[string]string
 		fieldSpec{
-			[]string{"Labels", "Env", "Extra", "LogOpt"},
+			[]string{"Labels", "Env", "Extra", "LogOpt", "Sysctls"},
 			[]check{
 				check{shouldEqual, "", ""},
 				check{shouldEqual, "KEY:\n  foo: bar", "KEY:\n  foo: bar"},
@@ -263,3 +288,24 @@ func TestConfigIsEqualTo(t *testing.T) {
 		assert.True(t, found, fmt.Sprintf("missing compare check for field: %s", fieldName))
 	}
 }
+
+func TestConfigDiff(t *testing.T) {
+	c1 := &Container{}
+	c2 := &Container{}
+
+	if err := yaml.Unmarshal([]byte("image: redis:2.8\ncpu_shares: 512\nenv:\n  FOO: bar\ndns: 8.8.8.8"), c1); err != nil {
+		t.Fatal(err)
+	}
+	if err := yaml.Unmarshal([]byte("image: redis:3.0\ncpu_shares: 512\nenv:\n  FOO: baz\nhostname: redis"), c2); err != nil {
+		t.Fatal(err)
+	}
+
+	// image is not a comparable property, so it should not show up here
+	assert.Equal(t, []FieldDiff{
+		{Field: "dns", Old: "", New: "- 8.8.8.8"},
+		{Field: "env", Old: "FOO: baz", New: "FOO: bar"},
+		{Field: "hostname", Old: "redis", New: ""},
+	}, c1.Diff(c2))
+
+	assert.Empty(t, c1.Diff(c1))
+}
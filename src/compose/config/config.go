@@ -24,14 +24,20 @@
 package config
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"math"
 	"os"
 	"path"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/grammarly/rocker/src/imagename"
 	"github.com/grammarly/rocker/src/template"
@@ -45,47 +51,78 @@ import (
 type Config struct {
 	Namespace  string // All containers names under current compose.yml will be prefixed with this namespace
 	Containers map[string]*Container
-	Vars       template.Vars
+	Vars       template.Vars `yaml:"vars,omitempty"`
+	Strict     bool          `yaml:"strict,omitempty"` // Reject container properties that are not known by rocker-compose
+
+	// raw values of container properties as they are given in the manifest, used by Validate
+	raw map[string]map[string]interface{}
 }
 
 // Container represents a single container spec from compose.yml
 type Container struct {
-	Extends         string         `yaml:"extends,omitempty"`           // can extend from other container spec referring by name
-	Image           *string        `yaml:"image,omitempty"`             //
-	Net             *Net           `yaml:"net,omitempty"`               //
-	Pid             *string        `yaml:"pid,omitempty"`               //
-	Uts             *string        `yaml:"uts,omitempty"`               //
-	State           *State         `yaml:"state,omitempty"`             // "running" or "created" or "ran"
-	DNS             Strings        `yaml:"dns,omitempty"`               //
-	AddHost         Strings        `yaml:"add_host,omitempty"`          //
-	Restart         *RestartPolicy `yaml:"restart,omitempty"`           //
-	Memory          *Memory        `yaml:"memory,omitempty"`            //
-	MemorySwap      *Memory        `yaml:"memory_swap,omitempty"`       //
-	CPUShares       *int64         `yaml:"cpu_shares,omitempty"`        //
-	CpusetCpus      *string        `yaml:"cpuset_cpus,omitempty"`       //
-	OomKillDisable  *bool          `yaml:"oom_kill_disable,omitempty"`  // e.g. docker run --oom-kill-disable TODO: pull request to go-dockerclient
-	Ulimits         []Ulimit       `yaml:"ulimits,omitempty"`           // search by "Ulimits" here https://goo.gl/IxbZck
-	Privileged      *bool          `yaml:"privileged,omitempty"`        //
-	Cmd             Cmd            `yaml:"cmd,omitempty"`               //
-	Entrypoint      Strings        `yaml:"entrypoint,omitempty"`        //
-	Expose          Strings        `yaml:"expose,omitempty"`            //
-	Ports           Ports          `yaml:"ports,omitempty"`             //
-	LogDriver       *string        `yaml:"log_driver,omitempty"`        //
-	LogOpt          StringMap      `yaml:"log_opt,omitempty"`           //
-	PublishAllPorts *bool          `yaml:"publish_all_ports,omitempty"` //
-	Labels          StringMap      `yaml:"labels,omitempty"`            //
-	Env             StringMap      `yaml:"env,omitempty"`               //
-	VolumesFrom     ContainerNames `yaml:"volumes_from,omitempty"`      //
-	Volumes         Strings        `yaml:"volumes,omitempty"`           //
-	Links           Links          `yaml:"links,omitempty"`             //
-	WaitFor         ContainerNames `yaml:"wait_for,omitempty"`          //
-	KillTimeout     *uint          `yaml:"kill_timeout,omitempty"`      //
-	Hostname        *string        `yaml:"hostname,omitempty"`          //
-	Domainname      *string        `yaml:"domainname,omitempty"`        //
-	User            *string        `yaml:"user,omitempty"`              //
-	Workdir         *string        `yaml:"workdir,omitempty"`           //
-	NetworkDisabled *bool          `yaml:"network_disabled,omitempty"`  // TODO: do we need this?
-	KeepVolumes     *bool          `yaml:"keep_volumes,omitempty"`      //
+	Extends           *Extends       `yaml:"extends,omitempty"`            // can extend from other container spec referring by name
+	Image             *string        `yaml:"image,omitempty"`              //
+	Net               *Net           `yaml:"net,omitempty"`                //
+	Pid               *string        `yaml:"pid,omitempty"`                //
+	Uts               *string        `yaml:"uts,omitempty"`                //
+	Ipc               *Ipc           `yaml:"ipc,omitempty"`                // e.g. host, shareable or container:name
+	State             *State         `yaml:"state,omitempty"`              // "running" or "created" or "ran"
+	DNS               Strings        `yaml:"dns,omitempty"`                //
+	AddHost           Strings        `yaml:"add_host,omitempty"`           //
+	Restart           *RestartPolicy `yaml:"restart,omitempty"`            //
+	Memory            *Memory        `yaml:"memory,omitempty"`             //
+	MemorySwap        *Memory        `yaml:"memory_swap,omitempty"`        //
+	CPUShares         *int64         `yaml:"cpu_shares,omitempty"`         //
+	CpusetCpus        *string        `yaml:"cpuset_cpus,omitempty"`        //
+	OomKillDisable    *bool          `yaml:"oom_kill_disable,omitempty"`   // e.g. docker run --oom-kill-disable
+	CPUQuota          *int64         `yaml:"cpu_quota,omitempty"`          // microseconds of CPU time per cpu_period
+	CPUPeriod         *int64         `yaml:"cpu_period,omitempty"`         // CFS period in microseconds, docker's default is 100000
+	CPUs              *float64       `yaml:"cpus,omitempty"`               // e.g. 1.5, a shortcut for cpu_quota
+	MemoryReservation *Memory        `yaml:"memory_reservation,omitempty"` // soft memory limit
+	KernelMemory      *Memory        `yaml:"kernel_memory,omitempty"`      //
+	ShmSize           *Memory        `yaml:"shm_size,omitempty"`           // size of /dev/shm, docker's default is 64m
+	PidsLimit         *int64         `yaml:"pids_limit,omitempty"`         //
+	OomScoreAdj       *int           `yaml:"oom_score_adj,omitempty"`      // -1000 to 1000
+	BlkioWeight       *int64         `yaml:"blkio_weight,omitempty"`       // 10 to 1000
+	DeviceReadBps     Strings        `yaml:"device_read_bps,omitempty"`    // path:rate, e.g. /dev/sda:10m
+	DeviceWriteBps    Strings        `yaml:"device_write_bps,omitempty"`   //
+	Ulimits           []Ulimit       `yaml:"ulimits,omitempty"`            // search by "Ulimits" here https://goo.gl/IxbZck
+	Privileged        *bool          `yaml:"privileged,omitempty"`         //
+	CapAdd            Strings        `yaml:"cap_add,omitempty"`            // e.g. NET_ADMIN, SYS_ADMIN
+	CapDrop           Strings        `yaml:"cap_drop,omitempty"`           //
+	Devices           Strings        `yaml:"devices,omitempty"`            // host_path[:container_path[:permissions]]
+	ReadOnly          *bool          `yaml:"read_only,omitempty"`          // mount the root filesystem as read only
+	SecurityOpt       Strings        `yaml:"security_opt,omitempty"`       // e.g. apparmor=unconfined, seccomp=profile.json (file contents are sent)
+	UsernsMode        *string        `yaml:"userns_mode,omitempty"`        // e.g. host
+	GroupAdd          Strings        `yaml:"group_add,omitempty"`          // additional groups of the container process
+	Sysctls           StringMap      `yaml:"sysctls,omitempty"`            // e.g. net.core.somaxconn: 1024
+	Cmd               Cmd            `yaml:"cmd,omitempty"`                //
+	Entrypoint        Strings        `yaml:"entrypoint,omitempty"`         //
+	Expose            Strings        `yaml:"expose,omitempty"`             //
+	Ports             Ports          `yaml:"ports,omitempty"`              //
+	LogDriver         *string        `yaml:"log_driver,omitempty"`         //
+	LogOpt            StringMap      `yaml:"log_opt,omitempty"`            //
+	PublishAllPorts   *bool          `yaml:"publish_all_ports,omitempty"`  //
+	Labels            StringMap      `yaml:"labels,omitempty"`             //
+	Env               StringMap      `yaml:"env,omitempty"`                //
+	EnvFile           Strings        `yaml:"env_file,omitempty"`           // merged into env when the manifest is read
+	VolumesFrom       ContainerNames `yaml:"volumes_from,omitempty"`       //
+	Volumes           Strings        `yaml:"volumes,omitempty"`            //
+	Tmpfs             Strings        `yaml:"tmpfs,omitempty"`              // path[:options], e.g. /run:rw,size=64m
+	Links             Links          `yaml:"links,omitempty"`              //
+	WaitFor           ContainerNames `yaml:"wait_for,omitempty"`           //
+	KillTimeout       *uint          `yaml:"kill_timeout,omitempty"`       //
+	StopSignal        *string        `yaml:"stop_signal,omitempty"`        // e.g. SIGINT, docker's default is SIGTERM
+	StopGracePeriod   *Duration      `yaml:"stop_grace_period,omitempty"`  // e.g. 1m30s, takes precedence over kill_timeout
+	Tty               *bool          `yaml:"tty,omitempty"`                //
+	StdinOpen         *bool          `yaml:"stdin_open,omitempty"`         //
+	Init              *bool          `yaml:"init,omitempty"`               // run an init process inside the container
+	Hostname          *string        `yaml:"hostname,omitempty"`           //
+	Domainname        *string        `yaml:"domainname,omitempty"`         //
+	User              *string        `yaml:"user,omitempty"`               //
+	Workdir           *string        `yaml:"workdir,omitempty"`            //
+	NetworkDisabled   *bool          `yaml:"network_disabled,omitempty"`   // TODO: do we need this?
+	KeepVolumes       *bool          `yaml:"keep_volumes,omitempty"`       //
 
 	// Aliases, for compatibility with docker-compose and `docker run`
 
@@ -118,6 +155,14 @@ type Link struct {
 	Alias         string
 }
 
+// Extends represents "extends" property of the container spec. It refers either to a container
+// of the same manifest by name, or to a container of another file, e.g.
+// {file: base.yml, container: _java}, relative paths are resolved from the extending file.
+type Extends struct {
+	File      string
+	Container string
+}
+
 // Ulimit describes ulimit specification for the manifest file
 type Ulimit struct {
 	Name string
@@ -125,11 +170,15 @@ type Ulimit struct {
 	Hard int64
 }
 
-// Memory is memory in bytes that is used for Memory and MemorySwap
-// properties of the container spec. It is parsed from string (e.g. "64M")
+// Memory is memory in bytes that is used for Memory, MemorySwap and other
+// memory and rate properties of the container spec. It is parsed from string (e.g. "64M")
 // to int64 bytes as a uniform representation.
 type Memory int64
 
+// Duration is a duration that is used for StopGracePeriod property of the container spec.
+// It is parsed either from a string of Go format (e.g. "1m30s") or from a number of seconds.
+type Duration time.Duration
+
 // RestartPolicy represents "restart" property of the container spec. Possible
 // values are: no | always | on-failure,N (where N is number of times it is allowed to fail)
 // Default value is "always". Despite Docker's default value is "no", we found that more often
@@ -157,6 +206,12 @@ type Net struct {
 	Container ContainerName
 }
 
+// Ipc is "ipc" property, which can also refer to some container
+type Ipc struct {
+	Type      string // none|private|shareable|container|host
+	Container ContainerName
+}
+
 // StringMap implements yaml [un]serializable map[string]string
 // is used for "labels" and "env" properties. See yaml.go for more info.
 type StringMap map[string]string
@@ -211,32 +266,81 @@ func NewFromFile(filename string, vars template.Vars, funcs map[string]interface
 // ReadConfig reads and parses the config from io.Reader stream.
 // Before parsing it processes config through a template engine implemented in template.go.
 func ReadConfig(configName string, reader io.Reader, vars template.Vars, funcs map[string]interface{}, print bool) (*Config, error) {
+	return ReadConfigs([]string{configName}, []io.Reader{reader}, vars, funcs, print)
+}
+
+// ReadConfigs reads several configs, processes each of them through the template engine
+// and deep-merges them in the given order, see merge.go for the rules. The merged config
+// is parsed the same way as a single one, paths are relative to the first config.
+func ReadConfigs(configNames []string, readers []io.Reader, vars template.Vars, funcs map[string]interface{}, print bool) (*Config, error) {
 	config := &Config{}
 
+	if len(configNames) == 0 || len(configNames) != len(readers) {
+		return nil, fmt.Errorf("Expected a config name for every config reader, got %d names and %d readers",
+			len(configNames), len(readers))
+	}
+
 	basedir, err := os.Getwd()
 	if err != nil {
 		return nil, fmt.Errorf("Failed to get working dir, error: %s", err)
 	}
 
-	if configName == "-" {
-		configName = "<STDIN>"
-	} else {
+	configNames = append([]string{}, configNames...)
+	for i, name := range configNames {
+		if name == "-" {
+			configNames[i] = "<STDIN>"
+		}
+	}
+	configName := configNames[0]
+
+	if configName != "<STDIN>" {
 		// if file given, process volume paths relative to the manifest file
 		basedir = filepath.Dir(configName)
 	}
 
-	data, err := template.Process(configName, reader, vars, funcs)
-	if err != nil {
-		return nil, fmt.Errorf("Failed to process config template, error: %s", err)
+	var (
+		data     []byte
+		rendered *renderedConfig
+		docs     = []map[interface{}]interface{}{}
+	)
+
+	for i, name := range configNames {
+		source, err := ioutil.ReadAll(readers[i])
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read config %s, error: %s", name, err)
+		}
+
+		buf, err := template.Process(name, bytes.NewReader(source), vars, funcs)
+		if err != nil {
+			return nil, templateError(name, source, err)
+		}
+		data = buf.Bytes()
+		rendered = newRenderedConfig(name, source, data)
+
+		if len(configNames) > 1 {
+			doc := map[interface{}]interface{}{}
+			if err := yaml.Unmarshal(data, &doc); err != nil {
+				return nil, rendered.yamlError("Failed to parse YAML config", err)
+			}
+			docs = append(docs, doc)
+		}
+	}
+
+	if len(configNames) > 1 {
+		if data, err = yaml.Marshal(mergeConfigs(docs)); err != nil {
+			return nil, fmt.Errorf("Failed to serialize merged configs, error: %s", err)
+		}
+		configName = strings.Join(configNames, " + ")
+		rendered = newRenderedConfig(configName, nil, data)
 	}
 
 	if print {
-		fmt.Print(data.String())
+		fmt.Print(string(data))
 		os.Exit(0)
 	}
 
-	if err := yaml.Unmarshal(data.Bytes(), config); err != nil {
-		return nil, fmt.Errorf("Failed to parse YAML config, error: %s", err)
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, rendered.yamlError("Failed to parse YAML config", err)
 	}
 
 	// empty namespace is a backward compatible docker-compose format
@@ -254,8 +358,15 @@ func ReadConfig(configName string, reader io.Reader, vars template.Vars, funcs m
 		Containers map[string]map[string]interface{}
 	}
 	extra := &ConfigExtra{}
-	if err := yaml.Unmarshal(data.Bytes(), extra); err != nil {
-		return nil, fmt.Errorf("Failed to parse YAML config extra properties, error: %s", err)
+	if err := yaml.Unmarshal(data, extra); err != nil {
+		return nil, rendered.yamlError("Failed to parse YAML config extra properties", err)
+	}
+	config.raw = extra.Containers
+
+	if config.Strict {
+		if err := config.CheckUnknownKeys(); err != nil {
+			return nil, fmt.Errorf("Unknown properties in %s (strict mode):\n%s", configName, err)
+		}
 	}
 
 	// Initialize YAML keys
@@ -283,48 +394,10 @@ func ReadConfig(configName string, reader io.Reader, vars template.Vars, funcs m
 		if container == nil {
 			return nil, fmt.Errorf("Invalid specification for container `%s` in %s", name, configName)
 		}
-		// Handle aliases
-		if container.Command != nil {
-			if container.Cmd == nil {
-				container.Cmd = container.Command
-			}
-			container.Command = nil
-		}
-		if container.Link != nil {
-			if container.Links == nil {
-				container.Links = container.Link
-			}
-			container.Link = nil
-		}
-		if container.Label != nil {
-			if container.Labels == nil {
-				container.Labels = container.Label
-			}
-			container.Label = nil
-		}
-		if container.Hosts != nil {
-			if container.AddHost == nil {
-				container.AddHost = container.Hosts
-			}
-			container.Hosts = nil
-		}
-		if container.ExtraHosts != nil {
-			if container.AddHost == nil {
-				container.AddHost = container.ExtraHosts
-			}
-			container.ExtraHosts = nil
-		}
-		if container.WorkingDir != nil {
-			if container.Workdir == nil {
-				container.Workdir = container.WorkingDir
-			}
-			container.WorkingDir = nil
-		}
-		if container.Environment != nil {
-			if container.Env == nil {
-				container.Env = container.Environment
-			}
-			container.Environment = nil
+		container.processAliases()
+
+		if err := container.resolveEnvFiles(basedir, getHome); err != nil {
+			return nil, fmt.Errorf("Container `%s` in %s: %s", name, configName, err)
 		}
 
 		// Process extra data
@@ -341,23 +414,22 @@ func ReadConfig(configName string, reader io.Reader, vars template.Vars, funcs m
 		// pretty.Println(name, container.Extra)
 	}
 
-	// Process extending containers configuration
-	for name, container := range config.Containers {
-		if container.Extends != "" {
-			if container.Extends == name {
-				return nil, fmt.Errorf("Container %s: cannot extend from itself", name)
-			}
-			if _, ok := config.Containers[container.Extends]; !ok {
-				return nil, fmt.Errorf("Container %s: cannot find container %s to extend from", name, container.Extends)
-			}
-			// TODO: build dependency graph by extends hierarchy to allow multiple inheritance
-			if config.Containers[container.Extends].Extends != "" {
-				return nil, fmt.Errorf("Container %s: cannot extend from %s: multiple inheritance is not allowed yet",
-					name, container.Extends)
-			}
-			container.ExtendFrom(config.Containers[container.Extends])
+	// Process extending containers configuration in topological order of extends,
+	// names are sorted to make errors stable
+	names := []string{}
+	for name := range config.Containers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	extends := newExtendsResolver(configName, basedir, config.Containers, vars, funcs, getHome)
+	for _, name := range names {
+		if err := extends.resolve(configName, name, config.Containers[name], nil); err != nil {
+			return nil, err
 		}
+	}
 
+	for name, container := range config.Containers {
 		// Validate image
 		if container.Image == nil {
 			return nil, fmt.Errorf("Image should be specified for container: %s", name)
@@ -383,6 +455,9 @@ func ReadConfig(configName string, reader io.Reader, vars template.Vars, funcs m
 		if container.Net != nil && container.Net.Type == "container" {
 			container.Net.Container.DefaultNamespace(config.Namespace)
 		}
+		if container.Ipc != nil && container.Ipc.Type == "container" {
+			container.Ipc.Container.DefaultNamespace(config.Namespace)
+		}
 
 		// Fix exposed ports
 		for k, port := range container.Expose {
@@ -392,28 +467,126 @@ func ReadConfig(configName string, reader io.Reader, vars template.Vars, funcs m
 		}
 
 		// Process relative paths in volumes
-		for i, volume := range container.Volumes {
-			split := strings.SplitN(volume, ":", 2)
-			if len(split) == 1 {
-				continue
-			}
-			if strings.HasPrefix(split[0], "~") {
-				home, err := getHome()
-				if err != nil {
-					return nil, fmt.Errorf("Failed to get HOME path, error: %s", err)
-				}
-				split[0] = strings.Replace(split[0], "~", home, 1)
-			}
-			if !path.IsAbs(split[0]) {
-				split[0] = path.Join(basedir, split[0])
-			}
-			container.Volumes[i] = strings.Join(split, ":")
+		if err := resolveVolumePaths(container.Volumes, basedir, getHome); err != nil {
+			return nil, err
+		}
+
+		if err := resolveSeccompProfiles(container.SecurityOpt, basedir, getHome); err != nil {
+			return nil, fmt.Errorf("Container `%s` in %s: %s", name, configName, err)
 		}
 	}
 
 	return config, nil
 }
 
+// processAliases moves values of alias properties, that are supported for compatibility
+// with docker-compose and `docker run`, to the properties they stand for
+func (container *Container) processAliases() {
+	if container.Command != nil {
+		if container.Cmd == nil {
+			container.Cmd = container.Command
+		}
+		container.Command = nil
+	}
+	if container.Link != nil {
+		if container.Links == nil {
+			container.Links = container.Link
+		}
+		container.Link = nil
+	}
+	if container.Label != nil {
+		if container.Labels == nil {
+			container.Labels = container.Label
+		}
+		container.Label = nil
+	}
+	if container.Hosts != nil {
+		if container.AddHost == nil {
+			container.AddHost = container.Hosts
+		}
+		container.Hosts = nil
+	}
+	if container.ExtraHosts != nil {
+		if container.AddHost == nil {
+			container.AddHost = container.ExtraHosts
+		}
+		container.ExtraHosts = nil
+	}
+	if container.WorkingDir != nil {
+		if container.Workdir == nil {
+			container.Workdir = container.WorkingDir
+		}
+		container.WorkingDir = nil
+	}
+	if container.Environment != nil {
+		if container.Env == nil {
+			container.Env = container.Environment
+		}
+		container.Environment = nil
+	}
+}
+
+// resolveVolumePaths makes paths of host directories mounted to volumes absolute,
+// relative paths are resolved from 'basedir' and "~" is replaced by the HOME path
+func resolveVolumePaths(volumes Strings, basedir string, getHome func() (string, error)) error {
+	for i, volume := range volumes {
+		split := strings.SplitN(volume, ":", 2)
+		if len(split) == 1 {
+			continue
+		}
+		if strings.HasPrefix(split[0], "~") {
+			home, err := getHome()
+			if err != nil {
+				return fmt.Errorf("Failed to get HOME path, error: %s", err)
+			}
+			split[0] = strings.Replace(split[0], "~", home, 1)
+		}
+		if !path.IsAbs(split[0]) {
+			split[0] = path.Join(basedir, split[0])
+		}
+		volumes[i] = strings.Join(split, ":")
+	}
+	return nil
+}
+
+// resolveSeccompProfiles replaces seccomp profiles given by files with their contents,
+// the same way `docker run --security-opt` does, since the docker api takes the profile
+// itself; relative paths are resolved from 'basedir' and "~" is replaced by the HOME path
+func resolveSeccompProfiles(opts Strings, basedir string, getHome func() (string, error)) error {
+	for i, opt := range opts {
+		split := strings.SplitN(opt, "=", 2)
+		if len(split) == 1 {
+			split = strings.SplitN(opt, ":", 2)
+		}
+		if len(split) == 1 || split[0] != "seccomp" {
+			continue
+		}
+		// the profile is either given inline already or disabled
+		if split[1] == "unconfined" || strings.HasPrefix(strings.TrimSpace(split[1]), "{") {
+			continue
+		}
+
+		file, err := expandHome(split[1], getHome)
+		if err != nil {
+			return err
+		}
+		if !path.IsAbs(file) {
+			file = path.Join(basedir, file)
+		}
+
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("Failed to read seccomp profile, error: %s", err)
+		}
+		profile := &bytes.Buffer{}
+		if err := json.Compact(profile, data); err != nil {
+			return fmt.Errorf("Failed to parse seccomp profile %s, error: %s", file, err)
+		}
+		opts[i] = "seccomp=" + profile.String()
+	}
+	return nil
+}
+
 // HasExternalRefs returns true if there is at least one reference to the external namespace
 func (c *Config) HasExternalRefs() bool {
 	for _, container := range c.Containers {
@@ -437,10 +610,50 @@ func (c *Config) HasExternalRefs() bool {
 				return true
 			}
 		}
+		if container.Ipc != nil && container.Ipc.Type == "container" {
+			if container.Ipc.Container.GetNamespace() != c.Namespace {
+				return true
+			}
+		}
 	}
 	return false
 }
 
+// StopTimeout returns seconds that the container is given to exit after the stop signal
+// before it is killed: stop_grace_period or kill_timeout, 10 seconds by default
+func (container *Container) StopTimeout() uint {
+	if container.StopGracePeriod != nil {
+		return container.StopGracePeriod.Seconds()
+	}
+	if container.KillTimeout != nil {
+		return *container.KillTimeout
+	}
+	return DefaultStopTimeout
+}
+
+// Hash returns sha256 checksum of the manifest, which is calculated by namespace and
+// container specs after all processing is done, so it does not depend on formatting
+func (c *Config) Hash() (string, error) {
+	data, err := yaml.Marshal(struct {
+		Namespace  string
+		Containers map[string]*Container
+	}{c.Namespace, c.Containers})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(data)), nil
+}
+
+// Hash returns sha256 checksum of the container spec as it is stored
+// in the `rocker-compose-config` label of containers
+func (container *Container) Hash() (string, error) {
+	data, err := yaml.Marshal(container)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(data)), nil
+}
+
 // Other minor types functions
 
 // Constructors
@@ -548,6 +761,22 @@ func NewNetFromString(str string) (*Net, error) {
 	return n, nil
 }
 
+// NewIpcFromString parses ipc mode from a string, e.g. "host" or "container:name"
+func NewIpcFromString(str string) (*Ipc, error) {
+	ipc := &Ipc{}
+	split := strings.SplitN(str, ":", 2)
+	ipc.Type = split[0]
+	if ipc.Type == "container" {
+		if len(split) < 2 {
+			return nil, fmt.Errorf("Missing container id or name for ipc param: %s", str)
+		}
+		ipc.Container = *NewContainerNameFromString(split[1])
+	} else if ipc.Type != "none" && ipc.Type != "private" && ipc.Type != "shareable" && ipc.Type != "host" {
+		return nil, fmt.Errorf("Unknown ipc mode: %s", str)
+	}
+	return ipc, nil
+}
+
 // Methods
 
 // String gives a string representation of the container name
@@ -609,6 +838,24 @@ func (link *Link) IsGlobalNs() bool {
 	return link.ContainerName.IsGlobalNs()
 }
 
+// Seconds returns the duration in whole seconds rounded up,
+// so a fraction of a second does not turn into no time at all
+func (d *Duration) Seconds() uint {
+	if d == nil || *d < 0 {
+		return 0
+	}
+	seconds := time.Duration(*d) / time.Second
+	if time.Duration(*d)%time.Second != 0 {
+		seconds++
+	}
+	return uint(seconds)
+}
+
+// String returns string representation of the duration
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
 // Int64 returns int64 value of the ConfigMemory object
 func (m *Memory) Int64() int64 {
 	if m == nil {
@@ -652,3 +899,14 @@ func (net *Net) String() string {
 	}
 	return net.Type
 }
+
+// String returns string representation of Ipc object.
+func (ipc *Ipc) String() string {
+	if ipc == nil {
+		return ""
+	}
+	if ipc.Type == "container" {
+		return ipc.Type + ":" + ipc.Container.String()
+	}
+	return ipc.Type
+}
@@ -17,6 +17,9 @@
 package config
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -65,6 +68,92 @@ func TestConfigMemoryInt64(t *testing.T) {
 	}
 }
 
+func TestConfigSeccompProfile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rocker-compose-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(name, content string) {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("profile.json", "{\n  \"defaultAction\": \"SCMP_ACT_ERRNO\"\n}\n")
+	write("compose.yml", `namespace: test
+containers:
+  main:
+    image: busybox:latest
+    security_opt:
+      - apparmor=unconfined
+      - seccomp=profile.json
+  unconfined:
+    image: busybox:latest
+    security_opt: seccomp:unconfined
+  inline:
+    image: busybox:latest
+    security_opt: 'seccomp={"defaultAction":"SCMP_ACT_ALLOW"}'`)
+
+	config, err := NewFromFile(filepath.Join(dir, "compose.yml"), configTestVars, map[string]interface{}{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the docker api takes the profile itself, so it is read relative to the manifest
+	assert.Equal(t, Strings{"apparmor=unconfined", `seccomp={"defaultAction":"SCMP_ACT_ERRNO"}`}, config.Containers["main"].SecurityOpt)
+	assert.Equal(t, Strings{"seccomp:unconfined"}, config.Containers["unconfined"].SecurityOpt)
+	assert.Equal(t, Strings{`seccomp={"defaultAction":"SCMP_ACT_ALLOW"}`}, config.Containers["inline"].SecurityOpt)
+
+	write("compose.yml", `namespace: test
+containers:
+  main:
+    image: busybox:latest
+    security_opt: seccomp=missing.json`)
+	_, err = NewFromFile(filepath.Join(dir, "compose.yml"), configTestVars, map[string]interface{}{}, false)
+	assert.Contains(t, err.Error(), "Container `main`")
+}
+
+func TestConfigStopTimeout(t *testing.T) {
+	configStr := `namespace: test
+containers:
+  default:
+    image: busybox
+  kill_timeout:
+    image: busybox
+    kill_timeout: 30
+  seconds:
+    image: busybox
+    kill_timeout: 30
+    stop_grace_period: 60
+  duration:
+    image: busybox
+    stop_grace_period: 1m30s
+  fraction:
+    image: busybox
+    stop_grace_period: 500ms
+  immediate:
+    image: busybox
+    stop_grace_period: 0s`
+
+	config, err := ReadConfig("test", strings.NewReader(configStr), configTestVars, map[string]interface{}{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.EqualValues(t, 10, config.Containers["default"].StopTimeout())
+	assert.EqualValues(t, 30, config.Containers["kill_timeout"].StopTimeout())
+	assert.EqualValues(t, 60, config.Containers["seconds"].StopTimeout())
+	assert.EqualValues(t, 90, config.Containers["duration"].StopTimeout())
+	assert.Equal(t, "1m30s", config.Containers["duration"].StopGracePeriod.String())
+	assert.EqualValues(t, 1, config.Containers["fraction"].StopTimeout())
+	assert.EqualValues(t, 0, config.Containers["immediate"].StopTimeout())
+
+	_, err = ReadConfig("test", strings.NewReader("containers:\n  main:\n    image: busybox\n    stop_grace_period: forever"), configTestVars, map[string]interface{}{}, false)
+	assert.Error(t, err)
+}
+
 func TestConfigCmdString(t *testing.T) {
 	configStr := `namespace: test
 containers:
@@ -18,12 +18,20 @@ package config
 
 import (
 	"fmt"
+	"path"
 	"strings"
 
 	docker "github.com/fsouza/go-dockerclient"
 	"github.com/go-yaml/yaml"
 )
 
+// DefaultStopTimeout is seconds to wait for a container to stop before killing it,
+// if neither stop_grace_period nor kill_timeout is given
+const DefaultStopTimeout = 10
+
+// DefaultCPUPeriod is the CFS period in microseconds that docker uses if cpu_period is not given
+const DefaultCPUPeriod = 100000
+
 // ErrNotRockerCompose error describing that given container was not likely
 // to beinitialized by rocker-compose
 type ErrNotRockerCompose struct {
@@ -100,6 +108,15 @@ func (config *Container) GetAPIConfig() *docker.Config {
 	if config.NetworkDisabled != nil {
 		apiConfig.NetworkDisabled = *config.NetworkDisabled
 	}
+	if config.StopSignal != nil {
+		apiConfig.StopSignal = *config.StopSignal
+	}
+	if config.Tty != nil {
+		apiConfig.Tty = *config.Tty
+	}
+	if config.StdinOpen != nil {
+		apiConfig.OpenStdin = *config.StdinOpen
+	}
 
 	// expose
 	if len(config.Expose) > 0 || len(config.Ports) > 0 {
@@ -144,16 +161,19 @@ func (config *Container) GetAPIConfig() *docker.Config {
 // GetAPIHostConfig as an opposite from NewFromDocker - it returns docker.HostConfig that can be used
 // to run containers through the docker api.
 func (config *Container) GetAPIHostConfig() *docker.HostConfig {
-	// TODO: CapAdd, CapDrop, LxcConf, Devices, LogConfig, ReadonlyRootfs,
-	//       SecurityOpt, CgroupParent, CPUQuota, CPUPeriod
+	// TODO: LxcConf, CgroupParent
 	// TODO: where Memory and MemorySwap should go?
 	hostConfig := &docker.HostConfig{
-		DNS:           config.DNS,
-		ExtraHosts:    config.AddHost,
-		RestartPolicy: config.Restart.ToDockerAPI(),
-		Memory:        config.Memory.Int64(),
-		MemorySwap:    config.MemorySwap.Int64(),
-		NetworkMode:   config.Net.String(),
+		DNS:               config.DNS,
+		ExtraHosts:        config.AddHost,
+		RestartPolicy:     config.Restart.ToDockerAPI(),
+		Memory:            config.Memory.Int64(),
+		MemorySwap:        config.MemorySwap.Int64(),
+		MemoryReservation: config.MemoryReservation.Int64(),
+		KernelMemory:      config.KernelMemory.Int64(),
+		ShmSize:           config.ShmSize.Int64(),
+		NetworkMode:       config.Net.String(),
+		IpcMode:           config.Ipc.String(),
 	}
 
 	// if state is "running", then restart policy sould be "always" by default
@@ -171,6 +191,43 @@ func (config *Container) GetAPIHostConfig() *docker.HostConfig {
 		hostConfig.CPUSet = *config.CpusetCpus
 	}
 
+	// Cgroup limits
+	hostConfig.OOMKillDisable = config.OomKillDisable
+	if config.CPUPeriod != nil {
+		hostConfig.CPUPeriod = *config.CPUPeriod
+	}
+	if config.CPUQuota != nil {
+		hostConfig.CPUQuota = *config.CPUQuota
+	}
+	// NanoCPUs needs docker api 1.25, so cpus are converted to the quota
+	// of the period the same way as `docker run --cpus` does on older daemons
+	if config.CPUs != nil && config.CPUQuota == nil {
+		period := hostConfig.CPUPeriod
+		if period == 0 {
+			period = DefaultCPUPeriod
+		}
+		hostConfig.CPUQuota = int64(*config.CPUs * float64(period))
+	}
+	hostConfig.PidsLimit = config.PidsLimit
+	if config.OomScoreAdj != nil {
+		hostConfig.OomScoreAdj = *config.OomScoreAdj
+	}
+	if config.BlkioWeight != nil {
+		hostConfig.BlkioWeight = *config.BlkioWeight
+	}
+
+	// Device rates, invalid ones are reported by Validate
+	for _, str := range config.DeviceReadBps {
+		if limit, err := ParseDeviceRate(str); err == nil {
+			hostConfig.BlkioDeviceReadBps = append(hostConfig.BlkioDeviceReadBps, limit)
+		}
+	}
+	for _, str := range config.DeviceWriteBps {
+		if limit, err := ParseDeviceRate(str); err == nil {
+			hostConfig.BlkioDeviceWriteBps = append(hostConfig.BlkioDeviceWriteBps, limit)
+		}
+	}
+
 	// Binds
 	binds := []string{}
 	for _, volume := range config.Volumes {
@@ -182,11 +239,48 @@ func (config *Container) GetAPIHostConfig() *docker.HostConfig {
 		hostConfig.Binds = binds
 	}
 
+	// Tmpfs mounts, invalid ones are reported by Validate
+	for _, str := range config.Tmpfs {
+		if dest, options, err := ParseTmpfs(str); err == nil {
+			if hostConfig.Tmpfs == nil {
+				hostConfig.Tmpfs = map[string]string{}
+			}
+			hostConfig.Tmpfs[dest] = options
+		}
+	}
+
 	// Privileged
 	if config.Privileged != nil {
 		hostConfig.Privileged = *config.Privileged
 	}
 
+	// Init
+	if config.Init != nil {
+		hostConfig.Init = *config.Init
+	}
+
+	// Capabilities, security options and user namespaces
+	hostConfig.CapAdd = config.CapAdd
+	hostConfig.CapDrop = config.CapDrop
+	hostConfig.SecurityOpt = config.SecurityOpt
+	hostConfig.GroupAdd = config.GroupAdd
+	if config.ReadOnly != nil {
+		hostConfig.ReadonlyRootfs = *config.ReadOnly
+	}
+	if config.UsernsMode != nil {
+		hostConfig.UsernsMode = *config.UsernsMode
+	}
+	if len(config.Sysctls) > 0 {
+		hostConfig.Sysctls = config.Sysctls
+	}
+
+	// Devices, invalid ones are reported by Validate
+	for _, str := range config.Devices {
+		if device, err := ParseDevice(str); err == nil {
+			hostConfig.Devices = append(hostConfig.Devices, device)
+		}
+	}
+
 	// PublishAllPorts
 	if config.PublishAllPorts != nil {
 		hostConfig.PublishAllPorts = *config.PublishAllPorts
@@ -258,3 +352,101 @@ func (config *Container) GetAPIHostConfig() *docker.HostConfig {
 
 	return hostConfig
 }
+
+// ParseDevice parses a device given as "host_path[:container_path[:permissions]]", the path
+// in the container is the same as on the host and permissions are "rwm" by default
+func ParseDevice(str string) (docker.Device, error) {
+	split := strings.Split(str, ":")
+	device := docker.Device{
+		PathOnHost:        split[0],
+		PathInContainer:   split[0],
+		CgroupPermissions: "rwm",
+	}
+	if len(split) > 3 || split[0] == "" {
+		return device, fmt.Errorf("invalid device `%s`, expected host_path[:container_path[:permissions]]", str)
+	}
+	if len(split) > 1 && split[1] != "" {
+		device.PathInContainer = split[1]
+	}
+	if len(split) > 2 {
+		if strings.Trim(split[2], "rwm") != "" || split[2] == "" {
+			return device, fmt.Errorf("invalid permissions `%s` of device `%s`, expected a combination of r, w and m", split[2], str)
+		}
+		device.CgroupPermissions = split[2]
+	}
+	return device, nil
+}
+
+// ParseDeviceRate parses a rate limit of a device given as "path:rate", where
+// rate is bytes per second with an optional unit the same as for memory, e.g. "/dev/sda:10m"
+func ParseDeviceRate(str string) (docker.BlockLimit, error) {
+	split := strings.SplitN(str, ":", 2)
+	if len(split) != 2 || split[0] == "" || !memoryRegexp.MatchString(split[1]) {
+		return docker.BlockLimit{}, fmt.Errorf("invalid device rate `%s`, expected path:rate with an optional unit b, k, m or g, e.g. /dev/sda:10m", str)
+	}
+	rate, err := NewConfigMemoryFromString(split[1])
+	if err != nil {
+		return docker.BlockLimit{}, fmt.Errorf("invalid device rate `%s`, error: %s", str, err)
+	}
+	return docker.BlockLimit{Path: split[0], Rate: rate.Int64()}, nil
+}
+
+// ParseTmpfs parses a tmpfs mount given as "path[:options]", where path is absolute and
+// options are the same as of `mount -t tmpfs`, e.g. "/run:rw,noexec,size=64m"
+func ParseTmpfs(str string) (string, string, error) {
+	split := strings.SplitN(str, ":", 2)
+	if !path.IsAbs(split[0]) {
+		return "", "", fmt.Errorf("invalid tmpfs `%s`, expected an absolute path[:options]", str)
+	}
+	if len(split) == 1 {
+		return split[0], "", nil
+	}
+	return split[0], split[1], nil
+}
+
+// WithDefaults returns a copy of the container spec with implicit defaults that
+// GetAPIHostConfig applies when the container is created: restart policy "always"
+// for running containers and "json-file" logging with rotation.
+func (config *Container) WithDefaults() *Container {
+	var (
+		container  = *config
+		hostConfig = config.GetAPIHostConfig()
+	)
+
+	if container.Restart == nil && hostConfig.RestartPolicy.Name != "" {
+		container.Restart = &RestartPolicy{
+			Name:              hostConfig.RestartPolicy.Name,
+			MaximumRetryCount: hostConfig.RestartPolicy.MaximumRetryCount,
+		}
+	}
+	if container.LogDriver == nil && hostConfig.LogConfig.Type != "" {
+		logDriver := hostConfig.LogConfig.Type
+		container.LogDriver = &logDriver
+	}
+	if container.LogOpt == nil && hostConfig.LogConfig.Config != nil {
+		container.LogOpt = hostConfig.LogConfig.Config
+	}
+
+	return &container
+}
+
+// Resolved returns the manifest as it is deployed: containers that are only used
+// to extend from are left out, and the rest have implicit defaults applied (see WithDefaults).
+// Aliases, extends, namespaces of references and volume paths are already processed by ReadConfig.
+func (config *Config) Resolved() *Config {
+	resolved := &Config{
+		Namespace:  config.Namespace,
+		Containers: map[string]*Container{},
+	}
+
+	for name, container := range config.Containers {
+		if strings.HasPrefix(name, "_") {
+			continue
+		}
+		c := container.WithDefaults()
+		c.Extends = nil
+		resolved.Containers[name] = c
+	}
+
+	return resolved
+}
@@ -22,6 +22,8 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/fsouza/go-dockerclient"
+	"github.com/go-yaml/yaml"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -70,3 +72,106 @@ func TestConfigGetApiHostConfig(t *testing.T) {
 
 	assert.Equal(t, strings.TrimSpace(string(expected)), string(actual))
 }
+
+func TestParseDevice(t *testing.T) {
+	device, err := ParseDevice("/dev/fuse")
+	assert.NoError(t, err)
+	assert.Equal(t, docker.Device{PathOnHost: "/dev/fuse", PathInContainer: "/dev/fuse", CgroupPermissions: "rwm"}, device)
+
+	device, err = ParseDevice("/dev/sda:/dev/xvda:r")
+	assert.NoError(t, err)
+	assert.Equal(t, docker.Device{PathOnHost: "/dev/sda", PathInContainer: "/dev/xvda", CgroupPermissions: "r"}, device)
+
+	_, err = ParseDevice(":/dev/xvda")
+	assert.Error(t, err)
+	_, err = ParseDevice("/dev/sda:/dev/xvda:rw:m")
+	assert.Error(t, err)
+}
+
+func TestParseDeviceRate(t *testing.T) {
+	limit, err := ParseDeviceRate("/dev/sda:10m")
+	assert.NoError(t, err)
+	assert.Equal(t, docker.BlockLimit{Path: "/dev/sda", Rate: 10 * 1024 * 1024}, limit)
+
+	limit, err = ParseDeviceRate("/dev/sda:512")
+	assert.NoError(t, err)
+	assert.Equal(t, docker.BlockLimit{Path: "/dev/sda", Rate: 512}, limit)
+
+	_, err = ParseDeviceRate("/dev/sda")
+	assert.Error(t, err)
+	_, err = ParseDeviceRate("/dev/sda:10x")
+	assert.Error(t, err)
+}
+
+func TestParseTmpfs(t *testing.T) {
+	path, options, err := ParseTmpfs("/run:rw,noexec,size=64m")
+	assert.NoError(t, err)
+	assert.Equal(t, "/run", path)
+	assert.Equal(t, "rw,noexec,size=64m", options)
+
+	path, options, err = ParseTmpfs("/tmp")
+	assert.NoError(t, err)
+	assert.Equal(t, "/tmp", path)
+	assert.Equal(t, "", options)
+
+	_, _, err = ParseTmpfs("tmp:size=64m")
+	assert.Error(t, err)
+}
+
+func TestConfigResolved(t *testing.T) {
+	configStr := `namespace: test
+containers:
+  _base:
+    image: busybox:latest
+    environment:
+      A: b
+  main:
+    extends: _base
+    command: ["echo", "hi"]
+    links: db
+  db:
+    image: busybox:latest
+    state: created
+    restart: no
+    log_opt:
+      max-size: 1m`
+
+	config, err := ReadConfig("test", strings.NewReader(configStr), configTestVars, map[string]interface{}{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual, err := yaml.Marshal(config.Resolved())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, `namespace: test
+containers:
+  db:
+    image: busybox:latest
+    state: created
+    restart: "no"
+    log_driver: json-file
+    log_opt:
+      max-size: 1m
+  main:
+    image: busybox:latest
+    restart: always
+    cmd:
+    - echo
+    - hi
+    log_driver: json-file
+    log_opt:
+      max-file: "5"
+      max-size: 100m
+    env:
+      A: b
+    links:
+    - test.db:db
+`, string(actual))
+
+	// the manifest itself is not changed
+	assert.Nil(t, config.Containers["main"].Restart)
+	assert.Equal(t, "_base", config.Containers["main"].Extends.Container)
+}
@@ -0,0 +1,166 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/grammarly/rocker/src/template"
+	"github.com/mitchellh/go-homedir"
+)
+
+// ReadEnvFile reads variables from a file of docker's env-file format: a `KEY=VALUE` per line,
+// values are taken as is without unquoting, a `KEY` without value takes the value from the
+// environment of rocker-compose (or is skipped if it is not set), empty lines and lines
+// starting with `#` are ignored.
+func ReadEnvFile(filename string) (map[string]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return parseEnvFile(f, filename)
+}
+
+func parseEnvFile(reader io.Reader, filename string) (map[string]string, error) {
+	var (
+		env     = map[string]string{}
+		scanner = bufio.NewScanner(reader)
+		lineNum = 0
+	)
+
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimLeft(scanner.Text(), " \t")
+		if lineNum == 1 {
+			line = strings.TrimPrefix(line, "\xEF\xBB\xBF")
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		split := strings.SplitN(line, "=", 2)
+		if strings.ContainsAny(split[0], " \t") {
+			return nil, fmt.Errorf("%s:%d: variable `%s` has white spaces", filename, lineNum, split[0])
+		}
+		if split[0] == "" {
+			return nil, fmt.Errorf("%s:%d: variable without name", filename, lineNum)
+		}
+
+		if len(split) == 2 {
+			env[split[0]] = split[1]
+		} else if value, ok := os.LookupEnv(split[0]); ok {
+			env[split[0]] = value
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Failed to read %s, error: %s", filename, err)
+	}
+
+	return env, nil
+}
+
+// resolveEnvFiles makes paths of env_file absolute the same way as paths of volumes and merges
+// variables of the files into env. Later files override earlier ones, and variables given by env
+// take precedence over the files, so the container is compared and created with the merged env.
+func (container *Container) resolveEnvFiles(basedir string, getHome func() (string, error)) error {
+	if len(container.EnvFile) == 0 {
+		return nil
+	}
+
+	env := StringMap{}
+	for i, file := range container.EnvFile {
+		file, err := expandHome(file, getHome)
+		if err != nil {
+			return err
+		}
+		if !path.IsAbs(file) {
+			file = path.Join(basedir, file)
+		}
+		container.EnvFile[i] = file
+
+		fileEnv, err := ReadEnvFile(file)
+		if err != nil {
+			return fmt.Errorf("Failed to read env_file, error: %s", err)
+		}
+		for key, value := range fileEnv {
+			env[key] = value
+		}
+	}
+
+	for key, value := range container.Env {
+		env[key] = value
+	}
+	container.Env = env
+
+	return nil
+}
+
+// ReadVarFiles reads variables given by `-var-file`. Env files (`.env` or `*.env`) are of the
+// same format as env_file of containers, other files are read by template.VarsFromFileMulti,
+// so they are either YAML or JSON and can be given by wildcards. Files given later override
+// variables of earlier ones.
+func ReadVarFiles(files []string) (template.Vars, error) {
+	varsList := []template.Vars{}
+
+	for _, file := range files {
+		if filepath.Ext(file) != ".env" {
+			vars, err := template.VarsFromFileMulti([]string{file})
+			if err != nil {
+				return nil, err
+			}
+			varsList = append(varsList, vars)
+			continue
+		}
+
+		file, err := expandHome(file, homedir.Dir)
+		if err != nil {
+			return nil, err
+		}
+		env, err := ReadEnvFile(file)
+		if err != nil {
+			return nil, err
+		}
+		vars := template.Vars{}
+		for key, value := range env {
+			vars[key] = value
+		}
+		varsList = append(varsList, vars)
+	}
+
+	return template.Vars{}.Merge(varsList...), nil
+}
+
+// expandHome replaces the leading `~` of the path by the home directory
+func expandHome(file string, getHome func() (string, error)) (string, error) {
+	if !strings.HasPrefix(file, "~") {
+		return file, nil
+	}
+	home, err := getHome()
+	if err != nil {
+		return "", fmt.Errorf("Failed to get HOME path, error: %s", err)
+	}
+	return strings.Replace(file, "~", home, 1), nil
+}
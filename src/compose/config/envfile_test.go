@@ -0,0 +1,150 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/grammarly/rocker/src/template"
+	"github.com/mitchellh/go-homedir"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseEnvFile(t *testing.T) {
+	os.Setenv("ROCKER_COMPOSE_ENV_FILE_TEST", "from-env")
+	defer os.Unsetenv("ROCKER_COMPOSE_ENV_FILE_TEST")
+
+	env, err := parseEnvFile(strings.NewReader(`# comment
+MODE=production
+  QUOTED="a b"
+EMPTY=
+EQUALS=a=b
+
+ROCKER_COMPOSE_ENV_FILE_TEST
+ROCKER_COMPOSE_ENV_FILE_MISSING
+`), "test.env")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, map[string]string{
+		"MODE":                         "production",
+		"QUOTED":                       `"a b"`,
+		"EMPTY":                        "",
+		"EQUALS":                       "a=b",
+		"ROCKER_COMPOSE_ENV_FILE_TEST": "from-env",
+	}, env)
+
+	_, err = parseEnvFile(strings.NewReader("MODE=production\nBAD KEY=1"), "test.env")
+	assert.EqualError(t, err, "test.env:2: variable `BAD KEY` has white spaces")
+}
+
+func TestConfigEnvFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rocker-compose-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(name, content string) {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("common.env", "MODE=development\nLOG_LEVEL=info\n")
+	write("secret.env", "MODE=production\nPASSWORD=secret\n")
+	write("compose.yml", `namespace: test
+containers:
+  main:
+    image: busybox:latest
+    env_file:
+      - common.env
+      - ./secret.env
+    env:
+      LOG_LEVEL: debug`)
+
+	read := func() *Container {
+		config, err := NewFromFile(filepath.Join(dir, "compose.yml"), configTestVars, map[string]interface{}{}, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return config.Containers["main"]
+	}
+
+	main := read()
+	assert.Equal(t, StringMap{"MODE": "production", "LOG_LEVEL": "debug", "PASSWORD": "secret"}, main.Env)
+	assert.Equal(t, Strings{filepath.Join(dir, "common.env"), filepath.Join(dir, "secret.env")}, main.EnvFile)
+
+	// editing an env file changes the container spec
+	write("secret.env", "MODE=production\nPASSWORD=changed\n")
+	assert.False(t, main.IsEqualTo(read()))
+
+	write("compose.yml", `namespace: test
+containers:
+  main:
+    image: busybox:latest
+    env_file: missing.env`)
+	_, err = NewFromFile(filepath.Join(dir, "compose.yml"), configTestVars, map[string]interface{}{}, false)
+	assert.Contains(t, err.Error(), "Container `main`")
+}
+
+func TestReadVarFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rocker-compose-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "vars.yml"), []byte("version: 1.0\nenv: dev"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, ".env"), []byte("env=prod\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	vars, err := ReadVarFiles([]string{filepath.Join(dir, "vars.yml"), filepath.Join(dir, ".env")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, template.Vars{"version": 1.0, "env": "prod"}, vars)
+
+	// env files in the home directory can be given by ~
+	home, err := homedir.Dir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := ioutil.TempFile(home, "rocker-compose-test-*.env")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString("env=home\n"); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	vars, err = ReadVarFiles([]string{filepath.Join(dir, "vars.yml"), "~/" + filepath.Base(f.Name())})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, template.Vars{"version": 1.0, "env": "home"}, vars)
+}
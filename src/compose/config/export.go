@@ -0,0 +1,389 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/go-yaml/yaml"
+)
+
+// exporter collects properties that cannot be represented in the target format
+type exporter struct {
+	namespace string
+	errs      ValidationErrors
+}
+
+func (ex *exporter) unsupported(container, field, format string, args ...interface{}) {
+	ex.errs = append(ex.errs, &ValidationError{
+		Container: container,
+		Field:     field,
+		Message:   fmt.Sprintf(format, args...),
+	})
+}
+
+func (ex *exporter) result() ValidationErrors {
+	if len(ex.errs) == 0 {
+		return nil
+	}
+	sort.Sort(ex.errs)
+	return ex.errs
+}
+
+// ExportDockerCompose converts the manifest to the docker-compose v3 format, containers
+// become services named after them. Properties are taken from docker API configs made by
+// GetAPIConfig and GetAPIHostConfig, so containers get the same settings as they get
+// from rocker-compose, including implicit defaults. Properties that cannot be represented
+// in docker-compose (volumes_from, cpu_shares, etc.) are skipped and returned as errors.
+func (config *Config) ExportDockerCompose() (yaml.MapSlice, ValidationErrors) {
+	var (
+		ex       = &exporter{namespace: config.Namespace}
+		services = yaml.MapSlice{}
+	)
+
+	for _, name := range config.sortedNames() {
+		services = append(services, yaml.MapItem{
+			Key:   name,
+			Value: ex.composeService(name, config.Containers[name]),
+		})
+	}
+
+	result := yaml.MapSlice{
+		{Key: "version", Value: "3"},
+		{Key: "services", Value: services},
+	}
+	return result, ex.result()
+}
+
+func (ex *exporter) composeService(name string, container *Container) yaml.MapSlice {
+	var (
+		service    = yaml.MapSlice{}
+		apiConfig  = container.GetAPIConfig()
+		hostConfig = container.GetAPIHostConfig()
+	)
+
+	set := func(key string, value interface{}) {
+		service = append(service, yaml.MapItem{Key: key, Value: composeEscape(value)})
+	}
+	setString := func(key, value string) {
+		if value != "" {
+			set(key, value)
+		}
+	}
+
+	setString("image", apiConfig.Image)
+	set("container_name", NewContainerName(ex.namespace, name).String())
+	if len(apiConfig.Entrypoint) > 0 {
+		set("entrypoint", apiConfig.Entrypoint)
+	}
+	if len(apiConfig.Cmd) > 0 {
+		set("command", apiConfig.Cmd)
+	}
+	setString("hostname", apiConfig.Hostname)
+	setString("domainname", apiConfig.Domainname)
+	setString("user", apiConfig.User)
+	setString("working_dir", apiConfig.WorkingDir)
+	if len(container.Env) > 0 {
+		set("environment", container.Env)
+	}
+	if len(container.Labels) > 0 {
+		set("labels", container.Labels)
+	}
+
+	// links and dependencies within the namespace refer to services, others refer to containers
+	links, externalLinks, dependsOn := []string{}, []string{}, []string{}
+	for _, link := range container.Links {
+		if link.ContainerName.Namespace == ex.namespace {
+			links = append(links, link.ContainerName.Name+":"+link.Alias)
+		} else {
+			externalLinks = append(externalLinks, link.String())
+		}
+	}
+	for _, dep := range container.WaitFor {
+		if dep.Namespace == ex.namespace {
+			dependsOn = append(dependsOn, dep.Name)
+		} else {
+			ex.unsupported(name, "wait_for", "container `%s` is not a service of the manifest, docker-compose cannot wait for it", dep.String())
+		}
+	}
+	if len(links) > 0 {
+		set("links", links)
+	}
+	if len(externalLinks) > 0 {
+		set("external_links", externalLinks)
+	}
+	if len(dependsOn) > 0 {
+		set("depends_on", dependsOn)
+	}
+
+	switch {
+	case container.Net != nil && container.Net.Type == "container":
+		if container.Net.Container.Namespace == ex.namespace {
+			set("network_mode", "service:"+container.Net.Container.Name)
+		} else {
+			set("network_mode", hostConfig.NetworkMode)
+		}
+	case apiConfig.NetworkDisabled:
+		set("network_mode", "none")
+	default:
+		setString("network_mode", hostConfig.NetworkMode)
+	}
+	setString("pid", hostConfig.PidMode)
+	if container.Ipc != nil && container.Ipc.Type == "container" && container.Ipc.Container.Namespace == ex.namespace {
+		set("ipc", "service:"+container.Ipc.Container.Name)
+	} else {
+		setString("ipc", hostConfig.IpcMode)
+	}
+
+	if len(hostConfig.DNS) > 0 {
+		set("dns", hostConfig.DNS)
+	}
+	if len(hostConfig.ExtraHosts) > 0 {
+		set("extra_hosts", hostConfig.ExtraHosts)
+	}
+
+	ports := []string{}
+	for _, port := range container.Ports {
+		binding := port.Port
+		if port.HostPort != "" || port.HostIP != "" {
+			binding = port.HostPort + ":" + binding
+		}
+		if port.HostIP != "" {
+			binding = port.HostIP + ":" + binding
+		}
+		ports = append(ports, strings.TrimSuffix(binding, "/tcp"))
+	}
+	if len(ports) > 0 {
+		set("ports", ports)
+	}
+	if len(container.Expose) > 0 {
+		set("expose", []string(container.Expose))
+	}
+
+	volumes := []string{}
+	for volume := range apiConfig.Volumes {
+		volumes = append(volumes, volume)
+	}
+	sort.Strings(volumes)
+	if volumes = append(append([]string{}, hostConfig.Binds...), volumes...); len(volumes) > 0 {
+		set("volumes", volumes)
+	}
+	for _, ref := range container.VolumesFrom {
+		ex.unsupported(name, "volumes_from", "volumes_from `%s` is not supported by docker-compose v3, use named volumes instead", ref.String())
+	}
+	if len(container.Tmpfs) > 0 {
+		set("tmpfs", []string(container.Tmpfs))
+	}
+	if hostConfig.ShmSize > 0 {
+		set("shm_size", fmt.Sprintf("%d", hostConfig.ShmSize))
+	}
+
+	restart := hostConfig.RestartPolicy.Name
+	if restart == "" {
+		restart = "no"
+	} else if restart == "on-failure" && hostConfig.RestartPolicy.MaximumRetryCount > 0 {
+		restart = fmt.Sprintf("on-failure:%d", hostConfig.RestartPolicy.MaximumRetryCount)
+	}
+	set("restart", restart)
+
+	if hostConfig.Privileged {
+		set("privileged", true)
+	}
+	if len(hostConfig.CapAdd) > 0 {
+		set("cap_add", hostConfig.CapAdd)
+	}
+	if len(hostConfig.CapDrop) > 0 {
+		set("cap_drop", hostConfig.CapDrop)
+	}
+	if len(container.Devices) > 0 {
+		set("devices", []string(container.Devices))
+	}
+	if hostConfig.ReadonlyRootfs {
+		set("read_only", true)
+	}
+	if len(hostConfig.SecurityOpt) > 0 {
+		set("security_opt", hostConfig.SecurityOpt)
+	}
+	setString("userns_mode", hostConfig.UsernsMode)
+	if len(hostConfig.Sysctls) > 0 {
+		set("sysctls", hostConfig.Sysctls)
+	}
+	if apiConfig.Tty {
+		set("tty", true)
+	}
+	if apiConfig.OpenStdin {
+		set("stdin_open", true)
+	}
+	if hostConfig.Init {
+		set("init", true)
+	}
+	setString("stop_signal", apiConfig.StopSignal)
+	if container.StopGracePeriod != nil {
+		set("stop_grace_period", container.StopGracePeriod.String())
+	} else if container.KillTimeout != nil {
+		set("stop_grace_period", fmt.Sprintf("%ds", *container.KillTimeout))
+	}
+
+	if hostConfig.LogConfig.Type != "" {
+		logging := yaml.MapSlice{{Key: "driver", Value: hostConfig.LogConfig.Type}}
+		if len(hostConfig.LogConfig.Config) > 0 {
+			logging = append(logging, yaml.MapItem{Key: "options", Value: hostConfig.LogConfig.Config})
+		}
+		set("logging", logging)
+	}
+
+	if len(hostConfig.Ulimits) > 0 {
+		ulimits := yaml.MapSlice{}
+		for _, ulimit := range hostConfig.Ulimits {
+			ulimits = append(ulimits, yaml.MapItem{
+				Key:   ulimit.Name,
+				Value: yaml.MapSlice{{Key: "soft", Value: ulimit.Soft}, {Key: "hard", Value: ulimit.Hard}},
+			})
+		}
+		set("ulimits", ulimits)
+	}
+
+	// resource limits of v3 are under deploy, docker-compose applies them with --compatibility
+	limits, reservations, resources := yaml.MapSlice{}, yaml.MapSlice{}, yaml.MapSlice{}
+	if cpus := exportCPUs(hostConfig); cpus > 0 {
+		limits = append(limits, yaml.MapItem{Key: "cpus", Value: strconv.FormatFloat(cpus, 'f', -1, 64)})
+	}
+	if hostConfig.Memory > 0 {
+		limits = append(limits, yaml.MapItem{Key: "memory", Value: fmt.Sprintf("%d", hostConfig.Memory)})
+	}
+	if hostConfig.MemoryReservation > 0 {
+		reservations = append(reservations, yaml.MapItem{Key: "memory", Value: fmt.Sprintf("%d", hostConfig.MemoryReservation)})
+	}
+	if len(limits) > 0 {
+		resources = append(resources, yaml.MapItem{Key: "limits", Value: limits})
+	}
+	if len(reservations) > 0 {
+		resources = append(resources, yaml.MapItem{Key: "reservations", Value: reservations})
+	}
+	if len(resources) > 0 {
+		set("deploy", yaml.MapSlice{{Key: "resources", Value: resources}})
+	}
+
+	ex.unsupportedCommon(name, container, hostConfig, "docker-compose v3")
+	if container.CPUShares != nil {
+		ex.unsupported(name, "cpu_shares", "not supported by docker-compose v3")
+	}
+	if len(container.GroupAdd) > 0 {
+		ex.unsupported(name, "group_add", "not supported by docker-compose v3")
+	}
+
+	if container.State != nil && *container.State == "created" {
+		ex.unsupported(name, "state", "state `created` is not supported by docker-compose, the container is started")
+	}
+
+	return service
+}
+
+// unsupportedCommon reports properties that neither docker-compose v3 nor kubernetes have
+func (ex *exporter) unsupportedCommon(name string, container *Container, hostConfig *docker.HostConfig, format string) {
+	if hostConfig.MemorySwap != 0 {
+		ex.unsupported(name, "memory_swap", "not supported by %s", format)
+	}
+	if container.CpusetCpus != nil {
+		ex.unsupported(name, "cpuset_cpus", "not supported by %s", format)
+	}
+	if container.OomKillDisable != nil && *container.OomKillDisable {
+		ex.unsupported(name, "oom_kill_disable", "not supported by %s", format)
+	}
+	if hostConfig.KernelMemory != 0 {
+		ex.unsupported(name, "kernel_memory", "not supported by %s", format)
+	}
+	if inspectInt64(hostConfig.PidsLimit) != 0 {
+		ex.unsupported(name, "pids_limit", "not supported by %s", format)
+	}
+	if hostConfig.OomScoreAdj != 0 {
+		ex.unsupported(name, "oom_score_adj", "not supported by %s", format)
+	}
+	if hostConfig.BlkioWeight != 0 {
+		ex.unsupported(name, "blkio_weight", "not supported by %s", format)
+	}
+	if len(hostConfig.BlkioDeviceReadBps) > 0 {
+		ex.unsupported(name, "device_read_bps", "not supported by %s", format)
+	}
+	if len(hostConfig.BlkioDeviceWriteBps) > 0 {
+		ex.unsupported(name, "device_write_bps", "not supported by %s", format)
+	}
+	if container.Uts != nil {
+		ex.unsupported(name, "uts", "not supported by %s", format)
+	}
+	if hostConfig.PublishAllPorts {
+		ex.unsupported(name, "publish_all_ports", "not supported by %s, publish ports explicitly", format)
+	}
+}
+
+// exportCPUs returns the cpu limit given either by cpus or by cpu_quota of cpu_period, or zero
+func exportCPUs(hostConfig *docker.HostConfig) float64 {
+	if hostConfig.CPUQuota <= 0 {
+		return 0
+	}
+	period := hostConfig.CPUPeriod
+	if period == 0 {
+		period = DefaultCPUPeriod
+	}
+	return float64(hostConfig.CPUQuota) / float64(period)
+}
+
+// composeEscape doubles dollar signs in string values, otherwise
+// docker-compose substitutes them by variables of its environment
+func composeEscape(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return strings.Replace(v, "$", "$$", -1)
+	case []string:
+		result := make([]string, len(v))
+		for i, item := range v {
+			result[i] = composeEscape(item).(string)
+		}
+		return result
+	case StringMap:
+		return composeEscape((map[string]string)(v))
+	case map[string]string:
+		result := map[string]string{}
+		for key, item := range v {
+			result[key] = composeEscape(item).(string)
+		}
+		return result
+	case yaml.MapSlice:
+		result := yaml.MapSlice{}
+		for _, item := range v {
+			result = append(result, yaml.MapItem{Key: item.Key, Value: composeEscape(item.Value)})
+		}
+		return result
+	}
+	return value
+}
+
+// sortedNames returns names of containers that are deployed, i.e. not prefixed with underscore
+func (config *Config) sortedNames() []string {
+	names := []string{}
+	for name := range config.Containers {
+		if !strings.HasPrefix(name, "_") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
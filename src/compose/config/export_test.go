@@ -0,0 +1,250 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-yaml/yaml"
+	"github.com/grammarly/rocker/src/template"
+	"github.com/stretchr/testify/assert"
+)
+
+var exportTestManifest = `namespace: myapp
+containers:
+  web:
+    image: nginx:1.9
+    ports:
+      - "80:80"
+      - "127.0.0.1:8443:443"
+    links:
+      - api
+      - cache:redis
+      - .statsd
+    wait_for: migrate
+    volumes_from: data
+    volumes:
+      - /etc/nginx:/etc/nginx:ro
+    cpu_shares: 512
+    cpus: 0.5
+    memory: 128m
+    memory_reservation: 64m
+    pids_limit: 100
+    cmd: ["nginx", "-g", "daemon off; env=$HOME;"]
+
+  api:
+    image: myapp/api:1.0
+    expose: 8080
+    user: "1000"
+    cap_add: NET_ADMIN
+    read_only: true
+    group_add: "50"
+    env:
+      MODE: production
+    add_host:
+      - db:10.0.0.1
+      - db-ro:10.0.0.1
+    sysctls:
+      net.core.somaxconn: 1024
+
+  cache:
+    image: redis:3
+    expose: 6379
+    net: container:api
+    ipc: container:api
+    shm_size: 256m
+    tmpfs: /run:size=64m,noexec
+
+  migrate:
+    image: myapp/api:1.0
+    state: ran
+    restart: on-failure,3
+    cmd: migrate
+    tty: true
+    stop_grace_period: 1m
+
+  data:
+    image: busybox:latest
+    state: created
+    volumes: /data
+
+  _base:
+    image: busybox:latest
+`
+
+func readExportTestConfig(t *testing.T) *Config {
+	config, err := ReadConfig("compose.yml", strings.NewReader(exportTestManifest), template.Vars{}, map[string]interface{}{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return config.Resolved()
+}
+
+// exportedDoc marshals an exported document and parses it back, so it can be inspected by keys
+func exportedDoc(t *testing.T, doc interface{}) map[string]interface{} {
+	data, err := yaml.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &result); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func unsupportedFields(errs ValidationErrors) []string {
+	fields := []string{}
+	for _, e := range errs {
+		fields = append(fields, e.Container+"."+e.Field)
+	}
+	return fields
+}
+
+func TestExportDockerCompose(t *testing.T) {
+	result, errs := readExportTestConfig(t).ExportDockerCompose()
+	doc := exportedDoc(t, result)
+
+	assert.Equal(t, "3", doc["version"])
+
+	services := doc["services"].(map[interface{}]interface{})
+	assert.Equal(t, 5, len(services))
+
+	web := services["web"].(map[interface{}]interface{})
+	assert.Equal(t, "nginx:1.9", web["image"])
+	assert.Equal(t, "myapp.web", web["container_name"])
+	assert.Equal(t, []interface{}{"nginx", "-g", "daemon off; env=$$HOME;"}, web["command"])
+	assert.Equal(t, []interface{}{"80:80", "127.0.0.1:8443:443"}, web["ports"])
+	assert.Equal(t, []interface{}{"api:api", "cache:redis"}, web["links"])
+	assert.Equal(t, []interface{}{"statsd:statsd"}, web["external_links"])
+	assert.Equal(t, []interface{}{"migrate"}, web["depends_on"])
+	assert.Equal(t, []interface{}{"/etc/nginx:/etc/nginx:ro"}, web["volumes"])
+	assert.Equal(t, "always", web["restart"])
+	assert.Equal(t, map[interface{}]interface{}{
+		"limits":       map[interface{}]interface{}{"cpus": "0.5", "memory": "134217728"},
+		"reservations": map[interface{}]interface{}{"memory": "67108864"},
+	}, web["deploy"].(map[interface{}]interface{})["resources"])
+
+	api := services["api"].(map[interface{}]interface{})
+	assert.Equal(t, []interface{}{"NET_ADMIN"}, api["cap_add"])
+	assert.Equal(t, true, api["read_only"])
+
+	cache := services["cache"].(map[interface{}]interface{})
+	assert.Equal(t, "service:api", cache["network_mode"])
+	assert.Equal(t, "service:api", cache["ipc"])
+	assert.Equal(t, "268435456", cache["shm_size"])
+	assert.Equal(t, []interface{}{"/run:size=64m,noexec"}, cache["tmpfs"])
+	assert.Equal(t, map[interface{}]interface{}{"net.core.somaxconn": "1024"}, api["sysctls"])
+
+	migrate := services["migrate"].(map[interface{}]interface{})
+	assert.Equal(t, "on-failure:3", migrate["restart"])
+	assert.Equal(t, true, migrate["tty"])
+	assert.Equal(t, "1m0s", migrate["stop_grace_period"])
+
+	assert.Equal(t, []string{"api.group_add", "data.state", "web.cpu_shares", "web.pids_limit", "web.volumes_from"}, unsupportedFields(errs))
+}
+
+func TestExportKubernetes(t *testing.T) {
+	docs, errs := readExportTestConfig(t).ExportKubernetes()
+
+	kinds := []string{}
+	objects := map[string]map[string]interface{}{}
+	for _, d := range docs {
+		doc := exportedDoc(t, d)
+		name := doc["metadata"].(map[interface{}]interface{})["name"].(string)
+		kind := doc["kind"].(string)
+		kinds = append(kinds, kind+"/"+name)
+		objects[kind+"/"+name] = doc
+	}
+
+	assert.Equal(t, []string{
+		"Namespace/myapp",
+		"Service/api",
+		"Deployment/api",
+		"Service/cache",
+		"Deployment/cache",
+		"Deployment/data",
+		"Job/migrate",
+		"Service/web",
+		"Deployment/web",
+	}, kinds)
+
+	pod := func(kind, name string) map[interface{}]interface{} {
+		spec := objects[kind+"/"+name]["spec"].(map[interface{}]interface{})
+		return spec["template"].(map[interface{}]interface{})["spec"].(map[interface{}]interface{})
+	}
+	container := func(kind, name string) map[interface{}]interface{} {
+		return pod(kind, name)["containers"].([]interface{})[0].(map[interface{}]interface{})
+	}
+
+	web := container("Deployment", "web")
+	assert.Equal(t, []interface{}{
+		map[interface{}]interface{}{"containerPort": 80, "protocol": "TCP", "hostPort": 80},
+		map[interface{}]interface{}{"containerPort": 443, "protocol": "TCP", "hostPort": 8443, "hostIP": "127.0.0.1"},
+	}, web["ports"])
+	assert.Equal(t, map[interface{}]interface{}{
+		"limits":   map[interface{}]interface{}{"cpu": "500m", "memory": 134217728},
+		"requests": map[interface{}]interface{}{"cpu": "500m", "memory": 67108864},
+	}, web["resources"])
+	assert.Equal(t, []interface{}{
+		map[interface{}]interface{}{"name": "volume-0", "mountPath": "/etc/nginx", "readOnly": true},
+	}, web["volumeMounts"])
+
+	api := container("Deployment", "api")
+	assert.Equal(t, map[interface{}]interface{}{
+		"runAsUser":              1000,
+		"capabilities":           map[interface{}]interface{}{"add": []interface{}{"NET_ADMIN"}},
+		"readOnlyRootFilesystem": true,
+	}, api["securityContext"])
+	assert.Equal(t, map[interface{}]interface{}{
+		"supplementalGroups": []interface{}{50},
+		"sysctls":            []interface{}{map[interface{}]interface{}{"name": "net.core.somaxconn", "value": "1024"}},
+	}, pod("Deployment", "api")["securityContext"])
+	assert.Equal(t, []interface{}{
+		map[interface{}]interface{}{"ip": "10.0.0.1", "hostnames": []interface{}{"db", "db-ro"}},
+	}, pod("Deployment", "api")["hostAliases"])
+
+	assert.Equal(t, []interface{}{
+		map[interface{}]interface{}{"name": "volume-0", "mountPath": "/run"},
+		map[interface{}]interface{}{"name": "volume-1", "mountPath": "/dev/shm"},
+	}, container("Deployment", "cache")["volumeMounts"])
+	assert.Equal(t, []interface{}{
+		map[interface{}]interface{}{"name": "volume-0", "emptyDir": map[interface{}]interface{}{"medium": "Memory", "sizeLimit": 67108864}},
+		map[interface{}]interface{}{"name": "volume-1", "emptyDir": map[interface{}]interface{}{"medium": "Memory", "sizeLimit": 268435456}},
+	}, pod("Deployment", "cache")["volumes"])
+
+	data := objects["Deployment/data"]["spec"].(map[interface{}]interface{})
+	assert.Equal(t, 0, data["replicas"])
+
+	job := objects["Job/migrate"]["spec"].(map[interface{}]interface{})
+	assert.Equal(t, 3, job["backoffLimit"])
+	assert.Equal(t, "OnFailure", pod("Job", "migrate")["restartPolicy"])
+	assert.Equal(t, 60, pod("Job", "migrate")["terminationGracePeriodSeconds"])
+	assert.Equal(t, true, container("Job", "migrate")["tty"])
+
+	assert.Equal(t, []string{
+		"cache.ipc",
+		"cache.net",
+		"cache.tmpfs",
+		"web.links",
+		"web.links",
+		"web.pids_limit",
+		"web.volumes_from",
+		"web.wait_for",
+	}, unsupportedFields(errs))
+}
@@ -16,6 +16,17 @@
 
 package config
 
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-yaml/yaml"
+	"github.com/grammarly/rocker/src/template"
+)
+
 // ExtendFrom extends the container spec from a given one
 func (container *Container) ExtendFrom(parent *Container) {
 	if container.Image == nil {
@@ -30,6 +41,9 @@ func (container *Container) ExtendFrom(parent *Container) {
 	if container.Uts == nil {
 		container.Uts = parent.Uts
 	}
+	if container.Ipc == nil {
+		container.Ipc = parent.Ipc
+	}
 	if container.State == nil {
 		container.State = parent.State
 	}
@@ -57,12 +71,69 @@ func (container *Container) ExtendFrom(parent *Container) {
 	if container.OomKillDisable == nil {
 		container.OomKillDisable = parent.OomKillDisable
 	}
+	if container.CPUQuota == nil {
+		container.CPUQuota = parent.CPUQuota
+	}
+	if container.CPUPeriod == nil {
+		container.CPUPeriod = parent.CPUPeriod
+	}
+	if container.CPUs == nil {
+		container.CPUs = parent.CPUs
+	}
+	if container.MemoryReservation == nil {
+		container.MemoryReservation = parent.MemoryReservation
+	}
+	if container.KernelMemory == nil {
+		container.KernelMemory = parent.KernelMemory
+	}
+	if container.ShmSize == nil {
+		container.ShmSize = parent.ShmSize
+	}
+	if container.PidsLimit == nil {
+		container.PidsLimit = parent.PidsLimit
+	}
+	if container.OomScoreAdj == nil {
+		container.OomScoreAdj = parent.OomScoreAdj
+	}
+	if container.BlkioWeight == nil {
+		container.BlkioWeight = parent.BlkioWeight
+	}
+	if container.DeviceReadBps == nil {
+		container.DeviceReadBps = parent.DeviceReadBps
+	}
+	if container.DeviceWriteBps == nil {
+		container.DeviceWriteBps = parent.DeviceWriteBps
+	}
 	if container.Ulimits == nil {
 		container.Ulimits = parent.Ulimits
 	}
 	if container.Privileged == nil {
 		container.Privileged = parent.Privileged
 	}
+	if container.CapAdd == nil {
+		container.CapAdd = parent.CapAdd
+	}
+	if container.CapDrop == nil {
+		container.CapDrop = parent.CapDrop
+	}
+	if container.Devices == nil {
+		container.Devices = parent.Devices
+	}
+	if container.ReadOnly == nil {
+		container.ReadOnly = parent.ReadOnly
+	}
+	if container.SecurityOpt == nil {
+		container.SecurityOpt = parent.SecurityOpt
+	}
+	if container.UsernsMode == nil {
+		container.UsernsMode = parent.UsernsMode
+	}
+	if container.GroupAdd == nil {
+		container.GroupAdd = parent.GroupAdd
+	}
+	if container.Sysctls == nil {
+		container.Sysctls = parent.Sysctls
+	}
 	if container.Cmd == nil {
 		container.Cmd = parent.Cmd
 	}
@@ -110,6 +181,11 @@ func (container *Container) ExtendFrom(parent *Container) {
 	}
 	container.Env = newEnv
 
+	// variables of env files are in env already, the list keeps the files of both
+	if len(parent.EnvFile) > 0 {
+		container.EnvFile = append(append(Strings{}, parent.EnvFile...), container.EnvFile...)
+	}
+
 	if container.Links == nil {
 		container.Links = parent.Links
 	}
@@ -122,9 +198,27 @@ func (container *Container) ExtendFrom(parent *Container) {
 	if container.Volumes == nil {
 		container.Volumes = parent.Volumes
 	}
+	if container.Tmpfs == nil {
+		container.Tmpfs = parent.Tmpfs
+	}
 	if container.KillTimeout == nil {
 		container.KillTimeout = parent.KillTimeout
 	}
+	if container.StopSignal == nil {
+		container.StopSignal = parent.StopSignal
+	}
+	if container.StopGracePeriod == nil {
+		container.StopGracePeriod = parent.StopGracePeriod
+	}
+	if container.Tty == nil {
+		container.Tty = parent.Tty
+	}
+	if container.StdinOpen == nil {
+		container.StdinOpen = parent.StdinOpen
+	}
+	if container.Init == nil {
+		container.Init = parent.Init
+	}
 	if container.Hostname == nil {
 		container.Hostname = parent.Hostname
 	}
@@ -143,3 +237,131 @@ func (container *Container) ExtendFrom(parent *Container) {
 
 	return
 }
+
+// extendsResolver extends containers from their parents in topological order of `extends`,
+// so chains of any depth are resolved, and detects cycles. Files of parents that are referred
+// by `extends: {file: base.yml, container: _java}` are loaded once and processed
+// through the template engine with the same variables as the manifest.
+type extendsResolver struct {
+	main    string
+	vars    template.Vars
+	funcs   map[string]interface{}
+	getHome func() (string, error)
+
+	// containers and base directories of loaded files by file names
+	files map[string]map[string]*Container
+	dirs  map[string]string
+
+	resolved map[*Container]bool
+	visiting map[*Container]bool
+}
+
+func newExtendsResolver(configName, basedir string, containers map[string]*Container,
+	vars template.Vars, funcs map[string]interface{}, getHome func() (string, error)) *extendsResolver {
+	return &extendsResolver{
+		main:     configName,
+		vars:     vars,
+		funcs:    funcs,
+		getHome:  getHome,
+		files:    map[string]map[string]*Container{configName: containers},
+		dirs:     map[string]string{configName: basedir},
+		resolved: map[*Container]bool{},
+		visiting: map[*Container]bool{},
+	}
+}
+
+// resolve extends the container from its parent, after the parent is extended from its own one;
+// 'chain' is the list of containers that are being extended from the given one
+func (r *extendsResolver) resolve(file, name string, container *Container, chain []string) error {
+	if r.resolved[container] {
+		return nil
+	}
+
+	chain = append(chain, r.label(file, name))
+
+	if r.visiting[container] {
+		return fmt.Errorf("Container %s: cannot extend, extends have a cycle: %s", chain[0], strings.Join(chain, " -> "))
+	}
+	if container.Extends == nil {
+		r.resolved[container] = true
+		return nil
+	}
+
+	r.visiting[container] = true
+	defer delete(r.visiting, container)
+
+	parentFile := file
+	if container.Extends.File != "" {
+		parentFile = container.Extends.File
+		if !filepath.IsAbs(parentFile) {
+			parentFile = filepath.Join(r.dirs[file], parentFile)
+		}
+		if err := r.load(parentFile); err != nil {
+			return fmt.Errorf("Container %s: %s", r.label(file, name), err)
+		}
+	}
+
+	parent, ok := r.files[parentFile][container.Extends.Container]
+	if !ok || parent == nil {
+		return fmt.Errorf("Container %s: cannot find container %s to extend from",
+			r.label(file, name), r.label(parentFile, container.Extends.Container))
+	}
+
+	if err := r.resolve(parentFile, container.Extends.Container, parent, chain); err != nil {
+		return err
+	}
+
+	container.ExtendFrom(parent)
+	r.resolved[container] = true
+
+	return nil
+}
+
+// load reads containers of a file to extend from; paths of volumes are resolved from
+// the directory of the file, since they are inherited by containers of other directories
+func (r *extendsResolver) load(file string) error {
+	if _, ok := r.files[file]; ok {
+		return nil
+	}
+
+	source, err := ioutil.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("Failed to read file to extend from, error: %s", err)
+	}
+
+	data, err := template.Process(file, bytes.NewReader(source), r.vars, r.funcs)
+	if err != nil {
+		return templateError(file, source, err)
+	}
+
+	config := &Config{}
+	if err := yaml.Unmarshal(data.Bytes(), config); err != nil {
+		return newRenderedConfig(file, source, data.Bytes()).yamlError("Failed to parse YAML file "+file, err)
+	}
+
+	for name, container := range config.Containers {
+		if container == nil {
+			return fmt.Errorf("Invalid specification for container `%s` in %s", name, file)
+		}
+		container.processAliases()
+		if err := resolveVolumePaths(container.Volumes, filepath.Dir(file), r.getHome); err != nil {
+			return err
+		}
+		if err := container.resolveEnvFiles(filepath.Dir(file), r.getHome); err != nil {
+			return fmt.Errorf("Container `%s` in %s: %s", name, file, err)
+		}
+	}
+
+	r.files[file] = config.Containers
+	r.dirs[file] = filepath.Dir(file)
+
+	return nil
+}
+
+// label is the name of a container for messages, containers of other files are given with file names
+func (r *extendsResolver) label(file, name string) string {
+	if file == r.main {
+		return name
+	}
+	return fmt.Sprintf("%s (%s)", name, file)
+}
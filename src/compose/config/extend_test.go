@@ -17,6 +17,10 @@
 package config
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -39,6 +43,9 @@ func TestConfigExtend(t *testing.T) {
 
 	// should be inherited
 	assert.EqualValues(t, 512, *config.Containers["main2"].CPUShares)
+	assert.EqualValues(t, 1.5, *config.Containers["main2"].CPUs)
+	assert.EqualValues(t, 200*1024*1024, *config.Containers["main2"].MemoryReservation)
+	assert.Equal(t, Strings{"/dev/sda:10m"}, config.Containers["main2"].DeviceReadBps)
 
 	// should inherit and merge labels
 	assert.Equal(t, 3, len(config.Containers["main2"].Labels))
@@ -53,4 +60,116 @@ func TestConfigExtend(t *testing.T) {
 
 	// should be overriden
 	assert.EqualValues(t, 200, *config.Containers["main2"].KillTimeout)
+	assert.EqualValues(t, 90, config.Containers["main2"].StopTimeout())
+	assert.Equal(t, "SIGINT", *config.Containers["main2"].StopSignal)
+}
+
+func TestConfigExtendChain(t *testing.T) {
+	configStr := `namespace: test
+containers:
+  main:
+    extends: _app
+    cmd: run
+  _app:
+    extends: _base
+    env:
+      APP: "1"
+  _base:
+    image: busybox:latest
+    env:
+      BASE: "1"
+    dns: 8.8.8.8`
+
+	config, err := ReadConfig("test", strings.NewReader(configStr), configTestVars, map[string]interface{}{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "busybox:latest", *config.Containers["main"].Image)
+	assert.Equal(t, Strings{"8.8.8.8"}, config.Containers["main"].DNS)
+	assert.Equal(t, StringMap{"APP": "1", "BASE": "1"}, config.Containers["main"].Env)
+}
+
+func TestConfigExtendCycle(t *testing.T) {
+	configStr := `namespace: test
+containers:
+  a:
+    extends: b
+  b:
+    extends: c
+  c:
+    image: busybox:latest
+    extends: a`
+
+	_, err := ReadConfig("test", strings.NewReader(configStr), configTestVars, map[string]interface{}{}, false)
+	assert.EqualError(t, err, "Container a: cannot extend, extends have a cycle: a -> b -> c -> a")
+
+	configStr = `namespace: test
+containers:
+  a:
+    image: busybox:latest
+    extends: a`
+
+	_, err = ReadConfig("test", strings.NewReader(configStr), configTestVars, map[string]interface{}{}, false)
+	assert.EqualError(t, err, "Container a: cannot extend, extends have a cycle: a -> a")
+}
+
+func TestConfigExtendFromFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rocker-compose-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	baseStr := `namespace: base
+containers:
+  _jvm:
+    image: java:{{ .version.myapp }}
+    environment:
+      JAVA_OPTS: -Xmx1g
+  _java:
+    extends: _jvm
+    volumes: ./log:/var/log/app
+    links: db`
+
+	configStr := `namespace: test
+containers:
+  main:
+    extends:
+      file: base.yml
+      container: _java
+  db:
+    image: busybox:latest
+  missing:
+    extends:
+      file: base.yml
+      container: _python`
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "base.yml"), []byte(baseStr), 0644); err != nil {
+		t.Fatal(err)
+	}
+	configFile := filepath.Join(dir, "compose.yml")
+	if err := ioutil.WriteFile(configFile, []byte(configStr), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = NewFromFile(configFile, configTestVars, map[string]interface{}{}, false)
+	assert.EqualError(t, err, "Container missing: cannot find container _python ("+filepath.Join(dir, "base.yml")+") to extend from")
+
+	configStr = strings.Split(configStr, "  missing:")[0]
+	if err := ioutil.WriteFile(configFile, []byte(configStr), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := NewFromFile(configFile, configTestVars, map[string]interface{}{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	main := config.Containers["main"]
+	assert.Equal(t, "java:1.9.2", *main.Image)
+	assert.Equal(t, StringMap{"JAVA_OPTS": "-Xmx1g"}, main.Env)
+	assert.Equal(t, Strings{filepath.Join(dir, "log") + ":/var/log/app"}, main.Volumes)
+	// links are given the namespace of the extending manifest
+	assert.Equal(t, "test.db:db", main.Links[0].String())
 }
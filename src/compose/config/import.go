@@ -0,0 +1,616 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-yaml/yaml"
+)
+
+var (
+	// importRenames are docker-compose service properties that have the same
+	// meaning in rocker-compose, probably under a different name
+	importRenames = map[string]string{
+		"image":             "image",
+		"hostname":          "hostname",
+		"domainname":        "domainname",
+		"user":              "user",
+		"working_dir":       "workdir",
+		"privileged":        "privileged",
+		"cap_add":           "cap_add",
+		"cap_drop":          "cap_drop",
+		"devices":           "devices",
+		"read_only":         "read_only",
+		"security_opt":      "security_opt",
+		"userns_mode":       "userns_mode",
+		"group_add":         "group_add",
+		"sysctls":           "sysctls",
+		"tmpfs":             "tmpfs",
+		"pid":               "pid",
+		"dns":               "dns",
+		"extra_hosts":       "add_host",
+		"cpu_shares":        "cpu_shares",
+		"cpuset":            "cpuset_cpus",
+		"oom_kill_disable":  "oom_kill_disable",
+		"cpu_quota":         "cpu_quota",
+		"cpu_period":        "cpu_period",
+		"pids_limit":        "pids_limit",
+		"oom_score_adj":     "oom_score_adj",
+		"tty":               "tty",
+		"stdin_open":        "stdin_open",
+		"init":              "init",
+		"stop_signal":       "stop_signal",
+		"stop_grace_period": "stop_grace_period",
+		"env_file":          "env_file",
+		"log_driver":        "log_driver",
+		"log_opt":           "log_opt",
+	}
+
+	// importMemory are docker-compose properties with memory values
+	importMemory = map[string]string{
+		"mem_limit":       "memory",
+		"memswap_limit":   "memory_swap",
+		"mem_reservation": "memory_reservation",
+		"shm_size":        "shm_size",
+	}
+
+	// docker-compose names containers as <project>_<service>_<index>
+	composeContainerRegexp = regexp.MustCompile(`^([a-z0-9]+)_([a-zA-Z0-9_-]+?)_[0-9]+$`)
+
+	composeMemoryRegexp   = regexp.MustCompile(`^(?i)([0-9]+)([bkmg])b?$`)
+	composeVariableRegexp = regexp.MustCompile(`\$(\$|\{([a-zA-Z_][a-zA-Z0-9_]*)((:?-)([^}]*))?\}|[a-zA-Z_][a-zA-Z0-9_]*)`)
+)
+
+type importer struct {
+	namespace string
+	warnings  []string
+}
+
+// ImportDockerCompose converts a docker-compose manifest of v1, v2 or v3 format into
+// a rocker-compose manifest with the given namespace. Services become containers,
+// `depends_on` turns into `wait_for` (unless there is a link already), external links and
+// volumes_from of other containers refer to other namespaces, variables ${VAR} are
+// replaced by {{ .Env.VAR }} template actions. Properties that cannot be converted
+// are skipped, a warning is returned for each of them.
+func ImportDockerCompose(reader io.Reader, namespace string) (yaml.MapSlice, []string, error) {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to read docker-compose manifest, error: %s", err)
+	}
+
+	doc := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, nil, fmt.Errorf("Failed to parse docker-compose manifest, error: %s", err)
+	}
+
+	im := &importer{
+		namespace: namespace,
+		warnings:  []string{},
+	}
+
+	services := doc
+	if _, ok := doc["services"]; ok || doc["version"] != nil {
+		services = map[string]interface{}{}
+		if s, ok := doc["services"].(map[interface{}]interface{}); ok {
+			for name, spec := range s {
+				services[fmt.Sprint(name)] = spec
+			}
+		}
+		for key := range doc {
+			switch {
+			case key == "version" || key == "services" || strings.HasPrefix(key, "x-"):
+			case key == "volumes":
+				im.warnings = append(im.warnings, "named volumes are not supported, volumes of services"+
+					" referring to them become host directories relative to the manifest")
+			default:
+				im.warnings = append(im.warnings, fmt.Sprintf("top level `%s` is not supported, skipped", key))
+			}
+		}
+	}
+
+	names := []string{}
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	containers := yaml.MapSlice{}
+	for _, name := range names {
+		spec, ok := services[name].(map[interface{}]interface{})
+		if !ok {
+			return nil, nil, fmt.Errorf("Invalid specification of service `%s`, expected a map", name)
+		}
+		container := im.importService(name, interpolate(spec).(map[interface{}]interface{}))
+		containers = append(containers, yaml.MapItem{Key: name, Value: container})
+	}
+
+	result := yaml.MapSlice{
+		{Key: "namespace", Value: namespace},
+		{Key: "containers", Value: containers},
+	}
+	return result, im.warnings, nil
+}
+
+// importService converts a docker-compose service spec to a container spec,
+// properties of the result are ordered as they are in the Container struct
+func (im *importer) importService(name string, spec map[interface{}]interface{}) yaml.MapSlice {
+	var (
+		c     = map[string]interface{}{}
+		links = map[string]bool{}
+		keys  = []string{}
+	)
+
+	for key := range spec {
+		keys = append(keys, fmt.Sprint(key))
+	}
+	sort.Strings(keys)
+
+	warn := func(format string, args ...interface{}) {
+		im.warnings = append(im.warnings, fmt.Sprintf("service `%s`: ", name)+fmt.Sprintf(format, args...))
+	}
+
+	for _, key := range keys {
+		value := spec[key]
+
+		if target, ok := importRenames[key]; ok {
+			c[target] = value
+			continue
+		}
+		if target, ok := importMemory[key]; ok {
+			c[target] = composeMemory(value)
+			continue
+		}
+
+		switch key {
+		case "command":
+			c["cmd"] = composeCommand(value)
+
+		case "entrypoint":
+			c["entrypoint"] = composeCommand(value)
+
+		case "environment":
+			c["env"] = composeEnv(value)
+
+		case "labels":
+			c["labels"] = keyValueMap(value)
+
+		case "expose":
+			c["expose"] = stringList(value)
+
+		case "ports":
+			ports := []string{}
+			for _, port := range listOf(value) {
+				if long, ok := port.(map[interface{}]interface{}); ok {
+					ports = append(ports, composeLongPort(long))
+				} else {
+					ports = append(ports, fmt.Sprint(port))
+				}
+			}
+			c["ports"] = ports
+
+		case "volumes":
+			volumes := []string{}
+			for _, volume := range listOf(value) {
+				if long, ok := volume.(map[interface{}]interface{}); ok {
+					volume = composeLongVolume(long)
+				}
+				str := fmt.Sprint(volume)
+				if split := strings.SplitN(str, ":", 2); len(split) == 2 && !isHostPath(split[0]) {
+					warn("named volume `%s` becomes a host directory relative to the manifest", split[0])
+				}
+				volumes = append(volumes, str)
+			}
+			c["volumes"] = volumes
+
+		case "volumes_from":
+			refs := []string{}
+			for _, ref := range stringList(value) {
+				split := strings.Split(ref, ":")
+				if split[0] == "container" && len(split) > 1 {
+					ref, split = im.externalName(split[1]), split[1:]
+				} else {
+					ref = split[0]
+				}
+				if len(split) > 1 && split[1] == "ro" {
+					warn("read-only mode of volumes_from `%s` is not supported, volumes are mounted read-write", ref)
+				}
+				refs = append(refs, ref)
+			}
+			c["volumes_from"] = refs
+
+		case "links":
+			list := stringList(value)
+			for _, link := range list {
+				links[strings.SplitN(link, ":", 2)[0]] = true
+			}
+			c["links"] = append(stringList(c["links"]), list...)
+
+		case "external_links":
+			list := []string{}
+			for _, link := range stringList(value) {
+				split := strings.SplitN(link, ":", 2)
+				if len(split) == 1 {
+					// keep the alias the container is known by in the original setup
+					split = append(split, split[0])
+				}
+				list = append(list, im.externalName(split[0])+":"+split[1])
+			}
+			c["links"] = append(list, stringList(c["links"])...)
+
+		case "depends_on":
+			// v2.1 and v3 allow a map of services with conditions
+			deps := []string{}
+			if m, ok := value.(map[interface{}]interface{}); ok {
+				for dep := range m {
+					deps = append(deps, fmt.Sprint(dep))
+				}
+				sort.Strings(deps)
+			} else {
+				deps = stringList(value)
+			}
+			c["wait_for"] = deps
+
+		case "net", "network_mode":
+			net := fmt.Sprint(value)
+			if split := strings.SplitN(net, ":", 2); len(split) == 2 {
+				switch split[0] {
+				case "service":
+					net = "container:" + split[1]
+				case "container":
+					net = "container:" + im.externalName(split[1])
+				}
+			}
+			c["net"] = net
+
+		case "ipc":
+			ipc := fmt.Sprint(value)
+			if split := strings.SplitN(ipc, ":", 2); len(split) == 2 {
+				switch split[0] {
+				case "service":
+					ipc = "container:" + split[1]
+				case "container":
+					ipc = "container:" + im.externalName(split[1])
+				}
+			}
+			c["ipc"] = ipc
+
+		case "restart":
+			restart := fmt.Sprint(value)
+			if restart == "unless-stopped" {
+				warn("restart policy `unless-stopped` is not supported, `always` is used instead")
+				restart = "always"
+			} else if restart == "false" {
+				restart = "no"
+			}
+			c["restart"] = restart
+
+		case "logging":
+			logging, _ := value.(map[interface{}]interface{})
+			if driver, ok := logging["driver"]; ok {
+				c["log_driver"] = driver
+			}
+			if options, ok := logging["options"]; ok {
+				c["log_opt"] = options
+			}
+
+		case "ulimits":
+			c["ulimits"] = composeUlimits(value)
+
+		case "cpus":
+			// cpus may be given as a string, e.g. "0.5"
+			cpus, err := strconv.ParseFloat(fmt.Sprint(value), 64)
+			if err != nil {
+				warn("cpus `%v` is not a number, skipped", value)
+				continue
+			}
+			c["cpus"] = cpus
+
+		case "blkio_config":
+			blkio, _ := value.(map[interface{}]interface{})
+			for option, v := range blkio {
+				switch option {
+				case "weight":
+					c["blkio_weight"] = v
+				case "device_read_bps", "device_write_bps":
+					rates := []string{}
+					for _, item := range listOf(v) {
+						device, _ := item.(map[interface{}]interface{})
+						rates = append(rates, fmt.Sprintf("%v:%v", device["path"], composeMemory(device["rate"])))
+					}
+					c[fmt.Sprint(option)] = rates
+				default:
+					warn("`blkio_config.%v` is not supported, skipped", option)
+				}
+			}
+
+		case "extends":
+			extends, ok := value.(map[interface{}]interface{})
+			if !ok {
+				c["extends"] = value
+			} else if file, ok := extends["file"]; ok {
+				c["extends"] = map[string]interface{}{"file": file, "container": extends["service"]}
+			} else {
+				c["extends"] = extends["service"]
+			}
+			warn("properties of the parent of `extends` are not merged by docker-compose rules, please review the result")
+
+		case "build":
+			if _, ok := spec["image"]; !ok {
+				image := fmt.Sprintf("%s_%s:latest", im.namespace, name)
+				c["image"] = image
+				warn("`build` is not supported, image `%s` has to be built beforehand", image)
+			} else {
+				warn("`build` is not supported, skipped")
+			}
+
+		default:
+			if !strings.HasPrefix(key, "x-") {
+				warn("`%s` is not supported, skipped", key)
+			}
+		}
+	}
+
+	// links also define the start order, wait_for is needed for other dependencies only
+	if deps, ok := c["wait_for"].([]string); ok {
+		waitFor := []string{}
+		for _, dep := range deps {
+			if !links[dep] {
+				waitFor = append(waitFor, dep)
+			}
+		}
+		c["wait_for"] = waitFor
+	}
+
+	if image, ok := c["image"].(string); ok && !imageHasTag(image) {
+		c["image"] = image + ":latest"
+	} else if !ok {
+		warn("image is not specified")
+	}
+
+	// the default restart policy of docker-compose is "no", while rocker-compose has "always"
+	if _, ok := c["restart"]; !ok {
+		c["restart"] = "no"
+	}
+
+	result := yaml.MapSlice{}
+	for _, field := range getYamlFields() {
+		if value, ok := c[field]; ok && !isEmptyList(value) {
+			result = append(result, yaml.MapItem{Key: field, Value: value})
+		}
+	}
+	return result
+}
+
+// externalName converts a name of a container that is not managed by the manifest;
+// containers of other docker-compose projects are referred by their future rocker-compose
+// names, e.g. "project_db_1" becomes "project.db", other containers are in the global namespace
+func (im *importer) externalName(name string) string {
+	if match := composeContainerRegexp.FindStringSubmatch(name); match != nil {
+		return match[1] + "." + match[2]
+	}
+	if strings.Contains(name, ".") {
+		return name
+	}
+	return "." + name
+}
+
+// interpolate replaces docker-compose variables in all string values by template
+// actions: ${VAR} and $VAR become {{ .Env.VAR }}, defaults ${VAR:-default} are kept
+func interpolate(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		result := map[interface{}]interface{}{}
+		for key, item := range v {
+			result[key] = interpolate(item)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = interpolate(item)
+		}
+		return result
+	case string:
+		// template actions in values should be printed as is
+		v = strings.Replace(v, "{{", `{{"{{"}}`, -1)
+		return composeVariableRegexp.ReplaceAllStringFunc(v, func(match string) string {
+			if match == "$$" {
+				return "$"
+			}
+			sub := composeVariableRegexp.FindStringSubmatch(match)
+			name := sub[2]
+			if name == "" {
+				name = match[1:]
+			}
+			if sub[4] != "" {
+				return fmt.Sprintf("{{ or .Env.%s %q }}", name, sub[5])
+			}
+			return fmt.Sprintf("{{ .Env.%s }}", name)
+		})
+	}
+	return value
+}
+
+// composeEnv converts environment given as a map or a list of "KEY=value" strings,
+// variables without values are taken from the environment of rocker-compose
+func composeEnv(value interface{}) map[interface{}]interface{} {
+	env := keyValueMap(value)
+	if list, ok := value.([]interface{}); ok {
+		for _, item := range list {
+			if str := fmt.Sprint(item); !strings.Contains(str, "=") {
+				env[str] = nil
+			}
+		}
+	}
+	for key, v := range env {
+		if v == nil {
+			env[key] = fmt.Sprintf("{{ .Env.%v }}", key)
+		}
+	}
+	return env
+}
+
+// composeCommand splits a command given as a string into arguments the way
+// docker-compose does, rocker-compose would run such a command by "/bin/sh -c"
+func composeCommand(value interface{}) interface{} {
+	str, ok := value.(string)
+	if !ok {
+		return value
+	}
+
+	var (
+		args    = []string{}
+		arg     bytes.Buffer
+		quote   rune
+		escaped bool
+		started bool
+	)
+	for _, r := range str {
+		switch {
+		case escaped:
+			arg.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, started = true, true
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			arg.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote, started = r, true
+		case r == ' ' || r == '\t' || r == '\n':
+			if started {
+				args = append(args, arg.String())
+				arg.Reset()
+				started = false
+			}
+		default:
+			arg.WriteRune(r)
+			started = true
+		}
+	}
+	if started {
+		args = append(args, arg.String())
+	}
+	return args
+}
+
+// composeMemory converts memory values such as "512mb" to rocker-compose units
+func composeMemory(value interface{}) interface{} {
+	str, ok := value.(string)
+	if !ok {
+		return value
+	}
+	if match := composeMemoryRegexp.FindStringSubmatch(str); match != nil {
+		return match[1] + strings.ToLower(match[2])
+	}
+	return str
+}
+
+// composeLongPort converts the v3 long syntax {target, published, protocol} of ports
+func composeLongPort(port map[interface{}]interface{}) string {
+	str := fmt.Sprint(port["target"])
+	if published, ok := port["published"]; ok {
+		str = fmt.Sprintf("%v:%s", published, str)
+	}
+	if protocol, ok := port["protocol"]; ok && protocol != "tcp" {
+		str = fmt.Sprintf("%s/%v", str, protocol)
+	}
+	return str
+}
+
+// composeLongVolume converts the v3 long syntax {source, target, read_only} of volumes
+func composeLongVolume(volume map[interface{}]interface{}) string {
+	str := fmt.Sprint(volume["target"])
+	if source, ok := volume["source"]; ok {
+		str = fmt.Sprintf("%v:%s", source, str)
+	}
+	if readOnly, _ := volume["read_only"].(bool); readOnly {
+		str += ":ro"
+	}
+	return str
+}
+
+// composeUlimits converts ulimits given as {nofile: {soft: 1, hard: 2}, nproc: 3}
+func composeUlimits(value interface{}) []map[string]interface{} {
+	limits, _ := value.(map[interface{}]interface{})
+	names := []string{}
+	for name := range limits {
+		names = append(names, fmt.Sprint(name))
+	}
+	sort.Strings(names)
+
+	result := []map[string]interface{}{}
+	for _, name := range names {
+		limit := map[string]interface{}{"name": name}
+		if m, ok := limits[name].(map[interface{}]interface{}); ok {
+			limit["soft"], limit["hard"] = m["soft"], m["hard"]
+		} else {
+			limit["soft"], limit["hard"] = limits[name], limits[name]
+		}
+		result = append(result, limit)
+	}
+	return result
+}
+
+func isHostPath(path string) bool {
+	return strings.HasPrefix(path, "/") || strings.HasPrefix(path, ".") || strings.HasPrefix(path, "~")
+}
+
+// imageHasTag returns true if the image name has a tag or a digest,
+// a registry may have a port e.g. "localhost:5000/app"
+func imageHasTag(image string) bool {
+	if strings.Contains(image, "@") {
+		return true
+	}
+	split := strings.Split(image, "/")
+	return strings.Contains(split[len(split)-1], ":")
+}
+
+func listOf(value interface{}) []interface{} {
+	if list, ok := value.([]interface{}); ok {
+		return list
+	}
+	if value == nil {
+		return []interface{}{}
+	}
+	return []interface{}{value}
+}
+
+func stringList(value interface{}) []string {
+	if list, ok := value.([]string); ok {
+		return list
+	}
+	result := []string{}
+	for _, item := range listOf(value) {
+		result = append(result, fmt.Sprint(item))
+	}
+	return result
+}
+
+func isEmptyList(value interface{}) bool {
+	list, ok := value.([]string)
+	return ok && len(list) == 0
+}
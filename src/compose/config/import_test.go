@@ -0,0 +1,187 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/go-yaml/yaml"
+	"github.com/grammarly/rocker/src/template"
+	"github.com/stretchr/testify/assert"
+)
+
+func importAndRead(t *testing.T, manifest string) (*Config, []string) {
+	result, warnings, err := ImportDockerCompose(strings.NewReader(manifest), "myapp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := yaml.Marshal(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := ReadConfig("compose.yml", bytes.NewReader(data), template.Vars{}, map[string]interface{}{}, false)
+	if err != nil {
+		t.Fatalf("%s\n%s", err, data)
+	}
+	return config, warnings
+}
+
+func TestImportDockerComposeV1(t *testing.T) {
+	config, warnings := importAndRead(t, `
+web:
+  build: .
+  command: python app.py --title "my app"
+  ports:
+    - "5000:5000"
+  links:
+    - redis:cache
+  external_links:
+    - otherapp_db_1:db
+    - memcached
+  mem_limit: 512mb
+redis:
+  image: redis
+  restart: always
+  volumes_from:
+    - container:data_store:ro
+`)
+
+	assert.Equal(t, "myapp", config.Namespace)
+
+	web := config.Containers["web"]
+	assert.Equal(t, "myapp_web:latest", *web.Image)
+	assert.Equal(t, Cmd{"python", "app.py", "--title", "my app"}, web.Cmd)
+	assert.Equal(t, "5000", web.Ports[0].HostPort)
+	assert.Equal(t, 3, len(web.Links))
+	assert.Equal(t, "otherapp.db:db", web.Links[0].String())
+	assert.Equal(t, "memcached", web.Links[1].ContainerName.Name)
+	assert.True(t, web.Links[1].IsGlobalNs())
+	assert.Equal(t, "myapp.redis:cache", web.Links[2].String())
+	assert.EqualValues(t, 512*1024*1024, *web.Memory)
+	assert.Equal(t, "no", web.Restart.Name)
+
+	redis := config.Containers["redis"]
+	assert.Equal(t, "redis:latest", *redis.Image)
+	assert.Equal(t, "always", redis.Restart.Name)
+	assert.Equal(t, "data_store", redis.VolumesFrom[0].Name)
+	assert.True(t, redis.VolumesFrom[0].IsGlobalNs())
+
+	assert.Equal(t, []string{
+		"service `redis`: read-only mode of volumes_from `.data_store` is not supported, volumes are mounted read-write",
+		"service `web`: `build` is not supported, image `myapp_web:latest` has to be built beforehand",
+	}, warnings)
+}
+
+func TestImportDockerComposeV3(t *testing.T) {
+	os.Setenv("ROCKER_COMPOSE_IMPORT_TAG", "1.2")
+	defer os.Unsetenv("ROCKER_COMPOSE_IMPORT_TAG")
+
+	config, warnings := importAndRead(t, `
+version: "3.4"
+services:
+  app:
+    image: "quay.io/app:${ROCKER_COMPOSE_IMPORT_TAG}"
+    depends_on:
+      - db
+      - migrate
+    links:
+      - db
+    environment:
+      - MODE=production
+      - PRICE=$$5
+      - ROCKER_COMPOSE_IMPORT_TAG
+    ports:
+      - target: 80
+        published: 8080
+      - target: 53
+        published: 53
+        protocol: udp
+    volumes:
+      - data:/var/lib/app
+      - type: bind
+        source: ./conf
+        target: /etc/app
+        read_only: true
+    logging:
+      driver: syslog
+      options:
+        tag: app
+    ulimits:
+      nproc: 65535
+      nofile:
+        soft: 20000
+        hard: 40000
+    cpus: "0.5"
+    mem_reservation: 256mb
+    blkio_config:
+      weight: 300
+      device_read_bps:
+        - path: /dev/sda
+          rate: 12mb
+    deploy:
+      replicas: 2
+  db:
+    image: mysql:5.6
+    network_mode: "service:app"
+    ipc: "service:app"
+    shm_size: 256mb
+    tmpfs: /run
+    sysctls:
+      - net.core.somaxconn=1024
+    restart: unless-stopped
+  migrate:
+    image: app:${TAG:-latest}
+volumes:
+  data: {}
+`)
+
+	app := config.Containers["app"]
+	assert.Equal(t, "quay.io/app:1.2", *app.Image)
+	assert.Equal(t, ContainerNames{{"myapp", "migrate"}}, app.WaitFor)
+	assert.Equal(t, StringMap{"MODE": "production", "PRICE": "$5", "ROCKER_COMPOSE_IMPORT_TAG": "1.2"}, app.Env)
+	assert.Equal(t, "8080", app.Ports[0].HostPort)
+	assert.Equal(t, "53/udp", app.Ports[1].Port)
+	assert.Equal(t, Strings{"data:/var/lib/app", "conf:/etc/app:ro"}, app.Volumes)
+	assert.Equal(t, "syslog", *app.LogDriver)
+	assert.Equal(t, StringMap{"tag": "app"}, app.LogOpt)
+	assert.Equal(t, []Ulimit{{"nofile", 20000, 40000}, {"nproc", 65535, 65535}}, app.Ulimits)
+	assert.Equal(t, 0.5, *app.CPUs)
+	assert.EqualValues(t, 256*1024*1024, *app.MemoryReservation)
+	assert.EqualValues(t, 300, *app.BlkioWeight)
+	assert.Equal(t, Strings{"/dev/sda:12m"}, app.DeviceReadBps)
+
+	db := config.Containers["db"]
+	assert.Equal(t, "container", db.Net.Type)
+	assert.Equal(t, "app", db.Net.Container.Name)
+	assert.Equal(t, "container:myapp.app", db.Ipc.String())
+	assert.EqualValues(t, 256*1024*1024, *db.ShmSize)
+	assert.Equal(t, Strings{"/run"}, db.Tmpfs)
+	assert.Equal(t, StringMap{"net.core.somaxconn": "1024"}, db.Sysctls)
+	assert.Equal(t, "always", db.Restart.Name)
+
+	assert.Equal(t, "app:latest", *config.Containers["migrate"].Image)
+
+	assert.Equal(t, []string{
+		"named volumes are not supported, volumes of services referring to them become host directories relative to the manifest",
+		"service `app`: `deploy` is not supported, skipped",
+		"service `app`: named volume `data` becomes a host directory relative to the manifest",
+		"service `db`: restart policy `unless-stopped` is not supported, `always` is used instead",
+	}, warnings)
+}
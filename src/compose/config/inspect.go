@@ -0,0 +1,613 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	docker "github.com/fsouza/go-dockerclient"
+)
+
+// defaultShmSize is the size of /dev/shm docker gives to containers if shm_size is not specified
+const defaultShmSize int64 = 64 * 1024 * 1024
+
+// DiffAPIContainer compares the container spec against a container given by `docker inspect`
+// that was not created by rocker-compose, so it has no spec to compare with. The spec is
+// converted by GetAPIConfig and GetAPIHostConfig and compared with the docker configs
+// of the container the way docker applies them: defaults are taken from the config of the
+// image (cmd, env, exposed ports, etc.), properties assigned by docker (hostname, swap) are
+// compared only if they are specified. It returns names of properties that differ.
+func (config *Container) DiffAPIContainer(apiContainer *docker.Container, image *docker.Config) []string {
+	var (
+		diffs      = []string{}
+		apiConfig  = config.GetAPIConfig()
+		hostConfig = config.GetAPIHostConfig()
+		actual     = apiContainer.Config
+		actualHost = apiContainer.HostConfig
+	)
+
+	if image == nil {
+		image = &docker.Config{}
+	}
+	if actual == nil {
+		actual = &docker.Config{}
+	}
+	if actualHost == nil {
+		actualHost = &docker.HostConfig{}
+	}
+
+	compare := func(field string, expected, actual interface{}) {
+		if !reflect.DeepEqual(expected, actual) {
+			diffs = append(diffs, field)
+		}
+	}
+
+	// docker takes both cmd and entrypoint from the image unless entrypoint is specified
+	entrypoint, cmd := apiConfig.Entrypoint, apiConfig.Cmd
+	if len(entrypoint) == 0 {
+		entrypoint = image.Entrypoint
+		if len(cmd) == 0 {
+			cmd = image.Cmd
+		}
+	}
+	compare("entrypoint", inspectList(entrypoint), inspectList(actual.Entrypoint))
+	compare("cmd", inspectList(cmd), inspectList(actual.Cmd))
+
+	compare("env", inspectEnv(image.Env, apiConfig.Env), inspectEnv(actual.Env))
+	compare("labels", inspectMap(image.Labels, apiConfig.Labels), inspectMap(actual.Labels))
+	compare("workdir", inspectDefault(apiConfig.WorkingDir, image.WorkingDir), actual.WorkingDir)
+	compare("user", inspectDefault(apiConfig.User, image.User), actual.User)
+	compare("stop_signal", inspectDefault(apiConfig.StopSignal, image.StopSignal, "SIGTERM"), inspectDefault(actual.StopSignal, "SIGTERM"))
+	compare("tty", apiConfig.Tty, actual.Tty)
+	compare("stdin_open", apiConfig.OpenStdin, actual.OpenStdin)
+	compare("init", hostConfig.Init, actualHost.Init)
+
+	// docker assigns the hostname by the container id if it is not specified
+	if apiConfig.Hostname != "" {
+		compare("hostname", apiConfig.Hostname, actual.Hostname)
+	}
+	if apiConfig.Domainname != "" {
+		compare("domainname", apiConfig.Domainname, actual.Domainname)
+	}
+
+	expose := inspectPorts(image.ExposedPorts)
+	for port := range inspectPorts(apiConfig.ExposedPorts) {
+		expose[port] = true
+	}
+	compare("expose", expose, inspectPorts(actual.ExposedPorts))
+
+	volumes := inspectSet(image.Volumes)
+	for volume := range apiConfig.Volumes {
+		volumes[volume] = true
+	}
+	compare("volumes", volumes, inspectSet(actual.Volumes))
+	compare("volumes", inspectStrings(hostConfig.Binds), inspectStrings(actualHost.Binds))
+	compare("volumes_from", inspectNames(hostConfig.VolumesFrom), inspectNames(actualHost.VolumesFrom))
+
+	compare("links", inspectStrings(hostConfig.Links), inspectLinks(actualHost.Links))
+	compare("ports", inspectPortBindings(hostConfig.PortBindings), inspectPortBindings(actualHost.PortBindings))
+	compare("publish_all_ports", hostConfig.PublishAllPorts, actualHost.PublishAllPorts)
+	compare("privileged", hostConfig.Privileged, actualHost.Privileged)
+	compare("cap_add", inspectStrings(hostConfig.CapAdd), inspectStrings(actualHost.CapAdd))
+	compare("cap_drop", inspectStrings(hostConfig.CapDrop), inspectStrings(actualHost.CapDrop))
+	compare("devices", inspectDevices(hostConfig.Devices), inspectDevices(actualHost.Devices))
+	compare("read_only", hostConfig.ReadonlyRootfs, actualHost.ReadonlyRootfs)
+	compare("security_opt", inspectStrings(hostConfig.SecurityOpt), inspectStrings(actualHost.SecurityOpt))
+	compare("userns_mode", hostConfig.UsernsMode, actualHost.UsernsMode)
+	compare("group_add", inspectStrings(hostConfig.GroupAdd), inspectStrings(actualHost.GroupAdd))
+	compare("sysctls", inspectMap(hostConfig.Sysctls), inspectMap(actualHost.Sysctls))
+	compare("tmpfs", inspectTmpfs(hostConfig.Tmpfs), inspectTmpfs(actualHost.Tmpfs))
+
+	compare("net", inspectNetworkMode(hostConfig.NetworkMode), inspectNetworkMode(actualHost.NetworkMode))
+	compare("network_disabled", apiConfig.NetworkDisabled, actual.NetworkDisabled)
+	compare("pid", hostConfig.PidMode, actualHost.PidMode)
+	compare("uts", hostConfig.UTSMode, actualHost.UTSMode)
+	// the default ipc mode depends on the daemon, it is either private or shareable
+	if hostConfig.IpcMode != "" {
+		compare("ipc", inspectIpcMode(hostConfig.IpcMode), inspectIpcMode(actualHost.IpcMode))
+	}
+	compare("dns", inspectList(hostConfig.DNS), inspectList(actualHost.DNS))
+	compare("add_host", inspectStrings(hostConfig.ExtraHosts), inspectStrings(actualHost.ExtraHosts))
+
+	compare("restart", inspectRestartPolicy(hostConfig.RestartPolicy), inspectRestartPolicy(actualHost.RestartPolicy))
+
+	compare("memory", hostConfig.Memory, actualHost.Memory)
+	// docker sets swap to the double of memory if it is not specified
+	if hostConfig.MemorySwap != 0 {
+		compare("memory_swap", hostConfig.MemorySwap, actualHost.MemorySwap)
+	}
+	compare("cpu_shares", apiConfig.CPUShares, inspectDefault(actualHost.CPUShares, actual.CPUShares))
+	compare("cpuset_cpus", hostConfig.CPUSet, inspectDefault(actualHost.CPUSetCPUs, actualHost.CPUSet, actual.CPUSet))
+	compare("oom_kill_disable", inspectBool(hostConfig.OOMKillDisable), inspectBool(actualHost.OOMKillDisable))
+	compare("cpu_quota", hostConfig.CPUQuota, actualHost.CPUQuota)
+	compare("cpu_period", hostConfig.CPUPeriod, actualHost.CPUPeriod)
+	compare("memory_reservation", hostConfig.MemoryReservation, actualHost.MemoryReservation)
+	compare("kernel_memory", hostConfig.KernelMemory, actualHost.KernelMemory)
+	compare("shm_size", inspectDefault(hostConfig.ShmSize, defaultShmSize), inspectDefault(actualHost.ShmSize, defaultShmSize))
+	// docker reports either 0 or -1 for no limit of pids
+	if pids, actualPids := inspectInt64(hostConfig.PidsLimit), inspectInt64(actualHost.PidsLimit); pids != 0 || actualPids > 0 {
+		compare("pids_limit", pids, actualPids)
+	}
+	compare("oom_score_adj", hostConfig.OomScoreAdj, actualHost.OomScoreAdj)
+	compare("blkio_weight", hostConfig.BlkioWeight, actualHost.BlkioWeight)
+	compare("device_read_bps", inspectBlockLimits(hostConfig.BlkioDeviceReadBps), inspectBlockLimits(actualHost.BlkioDeviceReadBps))
+	compare("device_write_bps", inspectBlockLimits(hostConfig.BlkioDeviceWriteBps), inspectBlockLimits(actualHost.BlkioDeviceWriteBps))
+
+	compare("log_driver", hostConfig.LogConfig.Type, actualHost.LogConfig.Type)
+	compare("log_opt", inspectMap(hostConfig.LogConfig.Config), inspectMap(actualHost.LogConfig.Config))
+
+	ulimits, actualUlimits := map[string]bool{}, map[string]bool{}
+	for _, ulimit := range hostConfig.Ulimits {
+		ulimits[fmt.Sprintf("%s=%d:%d", ulimit.Name, ulimit.Soft, ulimit.Hard)] = true
+	}
+	for _, ulimit := range actualHost.Ulimits {
+		actualUlimits[fmt.Sprintf("%s=%d:%d", ulimit.Name, ulimit.Soft, ulimit.Hard)] = true
+	}
+	compare("ulimits", ulimits, actualUlimits)
+
+	return uniqueStrings(diffs)
+}
+
+// NewFromAPIContainer makes a container spec of a container given by `docker inspect`, it is
+// the inverse of GetAPIConfig and GetAPIHostConfig. Properties the container has from its image
+// (cmd, env, exposed ports, etc.), as well as values docker assigns by default, are left out.
+// Referenced containers keep names docker has for them, names without a namespace are global.
+func NewFromAPIContainer(apiContainer *docker.Container, image *docker.Config) *Container {
+	var (
+		container  = &Container{}
+		actual     = apiContainer.Config
+		actualHost = apiContainer.HostConfig
+	)
+
+	if image == nil {
+		image = &docker.Config{}
+	}
+	if actual == nil {
+		actual = &docker.Config{}
+	}
+	if actualHost == nil {
+		actualHost = &docker.HostConfig{}
+	}
+
+	setString := func(value, defaultValue string) *string {
+		if value == "" || value == defaultValue {
+			return nil
+		}
+		return &value
+	}
+	setBool := func(value bool) *bool {
+		if !value {
+			return nil
+		}
+		return &value
+	}
+
+	container.Image = setString(actual.Image, "")
+	container.StopSignal = setString(actual.StopSignal, inspectDefault(image.StopSignal, "SIGTERM").(string))
+	container.Tty = setBool(actual.Tty)
+	container.StdinOpen = setBool(actual.OpenStdin)
+	container.Init = setBool(actualHost.Init)
+
+	if !reflect.DeepEqual(inspectList(actual.Entrypoint), inspectList(image.Entrypoint)) {
+		container.Entrypoint = actual.Entrypoint
+	}
+	// cmd is taken from the image only if entrypoint is not specified
+	if container.Entrypoint != nil || !reflect.DeepEqual(inspectList(actual.Cmd), inspectList(image.Cmd)) {
+		container.Cmd = actual.Cmd
+	}
+
+	imageEnv := inspectEnv(image.Env)
+	for key, value := range inspectEnv(actual.Env) {
+		if imageValue, ok := imageEnv[key]; ok && imageValue == value {
+			continue
+		}
+		if container.Env == nil {
+			container.Env = StringMap{}
+		}
+		container.Env[key] = value
+	}
+
+	for key, value := range actual.Labels {
+		if imageValue, ok := image.Labels[key]; (ok && imageValue == value) || strings.HasPrefix(key, "rocker-compose-") {
+			continue
+		}
+		if container.Labels == nil {
+			container.Labels = StringMap{}
+		}
+		container.Labels[key] = value
+	}
+
+	container.Workdir = setString(actual.WorkingDir, image.WorkingDir)
+	container.User = setString(actual.User, image.User)
+	// docker assigns the hostname by the container id if it is not specified
+	if actual.Hostname != "" && !strings.HasPrefix(apiContainer.ID, actual.Hostname) {
+		container.Hostname = &actual.Hostname
+	}
+	container.Domainname = setString(actual.Domainname, "")
+	container.NetworkDisabled = setBool(actual.NetworkDisabled)
+
+	// ports
+	published := map[string]bool{}
+	for port, bindings := range actualHost.PortBindings {
+		for _, binding := range bindings {
+			container.Ports = append(container.Ports, PortBinding{
+				Port:     inspectPort(port),
+				HostIP:   binding.HostIP,
+				HostPort: binding.HostPort,
+			})
+		}
+		published[inspectPort(port)] = true
+	}
+	sort.Sort(portsByName(container.Ports))
+
+	imagePorts := inspectPorts(image.ExposedPorts)
+	for port := range inspectPorts(actual.ExposedPorts) {
+		if !imagePorts[port] && !published[port] {
+			container.Expose = append(container.Expose, strings.TrimSuffix(port, "/tcp"))
+		}
+	}
+	sort.Strings(container.Expose)
+
+	// volumes
+	mounted := map[string]bool{}
+	for _, bind := range actualHost.Binds {
+		container.Volumes = append(container.Volumes, bind)
+		if split := strings.Split(bind, ":"); len(split) > 1 {
+			mounted[split[1]] = true
+		}
+	}
+	anonymous := []string{}
+	for volume := range actual.Volumes {
+		if _, ok := image.Volumes[volume]; !ok && !mounted[volume] {
+			anonymous = append(anonymous, volume)
+		}
+	}
+	sort.Strings(anonymous)
+	container.Volumes = append(container.Volumes, anonymous...)
+
+	for _, name := range actualHost.VolumesFrom {
+		container.VolumesFrom = append(container.VolumesFrom, *inspectContainerName(strings.Split(name, ":")[0]))
+	}
+
+	for _, link := range actualHost.Links {
+		split := strings.SplitN(link, ":", 2)
+		ref := Link{ContainerName: *inspectContainerName(split[0])}
+		ref.Alias = ref.ContainerName.Name
+		if len(split) == 2 {
+			ref.Alias = split[1][strings.LastIndex(split[1], "/")+1:]
+		}
+		container.Links = append(container.Links, ref)
+	}
+
+	switch mode := inspectNetworkMode(actualHost.NetworkMode); {
+	case strings.HasPrefix(mode, "container:"):
+		container.Net = &Net{Type: "container", Container: *inspectContainerName(strings.TrimPrefix(mode, "container:"))}
+	case mode != "bridge":
+		container.Net = &Net{Type: mode}
+	}
+
+	switch mode := inspectIpcMode(actualHost.IpcMode); {
+	case strings.HasPrefix(mode, "container:"):
+		container.Ipc = &Ipc{Type: "container", Container: *inspectContainerName(strings.TrimPrefix(mode, "container:"))}
+	case mode == "none" || mode == "host":
+		container.Ipc = &Ipc{Type: mode}
+	}
+
+	container.Pid = setString(actualHost.PidMode, "")
+	container.Uts = setString(actualHost.UTSMode, "")
+	if len(actualHost.DNS) > 0 {
+		container.DNS = actualHost.DNS
+	}
+	if len(actualHost.ExtraHosts) > 0 {
+		container.AddHost = actualHost.ExtraHosts
+	}
+
+	// the default restart policy of rocker-compose is "always", so "no" is given explicitly
+	restart := inspectRestartPolicy(actualHost.RestartPolicy)
+	container.Restart = &RestartPolicy{Name: restart.Name, MaximumRetryCount: restart.MaximumRetryCount}
+
+	if memory := inspectDefault(actualHost.Memory, actual.Memory).(int64); memory > 0 {
+		container.Memory = NewConfigMemoryFromInt64(memory)
+		// docker sets swap to the double of memory if it is not specified
+		if swap := inspectDefault(actualHost.MemorySwap, actual.MemorySwap).(int64); swap != 0 && swap != memory*2 {
+			container.MemorySwap = NewConfigMemoryFromInt64(swap)
+		}
+	}
+	if shares := inspectDefault(actualHost.CPUShares, actual.CPUShares).(int64); shares > 0 {
+		container.CPUShares = &shares
+	}
+	container.CpusetCpus = setString(inspectDefault(actualHost.CPUSetCPUs, actualHost.CPUSet, actual.CPUSet).(string), "")
+	container.OomKillDisable = setBool(inspectBool(actualHost.OOMKillDisable))
+	container.MemoryReservation = NewConfigMemoryFromInt64(actualHost.MemoryReservation)
+	container.KernelMemory = NewConfigMemoryFromInt64(actualHost.KernelMemory)
+	if actualHost.ShmSize != defaultShmSize {
+		container.ShmSize = NewConfigMemoryFromInt64(actualHost.ShmSize)
+	}
+	if actualHost.CPUQuota > 0 {
+		container.CPUQuota = &actualHost.CPUQuota
+	}
+	if actualHost.CPUPeriod > 0 {
+		container.CPUPeriod = &actualHost.CPUPeriod
+	}
+	if pids := inspectInt64(actualHost.PidsLimit); pids > 0 {
+		container.PidsLimit = &pids
+	}
+	if actualHost.OomScoreAdj != 0 {
+		container.OomScoreAdj = &actualHost.OomScoreAdj
+	}
+	if actualHost.BlkioWeight > 0 {
+		container.BlkioWeight = &actualHost.BlkioWeight
+	}
+	for rate := range inspectBlockLimits(actualHost.BlkioDeviceReadBps) {
+		container.DeviceReadBps = append(container.DeviceReadBps, rate)
+	}
+	sort.Strings(container.DeviceReadBps)
+	for rate := range inspectBlockLimits(actualHost.BlkioDeviceWriteBps) {
+		container.DeviceWriteBps = append(container.DeviceWriteBps, rate)
+	}
+	sort.Strings(container.DeviceWriteBps)
+	container.Privileged = setBool(actualHost.Privileged)
+	container.ReadOnly = setBool(actualHost.ReadonlyRootfs)
+	container.UsernsMode = setString(actualHost.UsernsMode, "")
+	if len(actualHost.CapAdd) > 0 {
+		container.CapAdd = actualHost.CapAdd
+	}
+	if len(actualHost.CapDrop) > 0 {
+		container.CapDrop = actualHost.CapDrop
+	}
+	if len(actualHost.SecurityOpt) > 0 {
+		container.SecurityOpt = actualHost.SecurityOpt
+	}
+	if len(actualHost.GroupAdd) > 0 {
+		container.GroupAdd = actualHost.GroupAdd
+	}
+	if len(actualHost.Sysctls) > 0 {
+		container.Sysctls = actualHost.Sysctls
+	}
+	for tmpfs := range inspectTmpfs(actualHost.Tmpfs) {
+		container.Tmpfs = append(container.Tmpfs, tmpfs)
+	}
+	sort.Strings(container.Tmpfs)
+	for device := range inspectDevices(actualHost.Devices) {
+		container.Devices = append(container.Devices, device)
+	}
+	sort.Strings(container.Devices)
+	container.PublishAllPorts = setBool(actualHost.PublishAllPorts)
+
+	for _, ulimit := range actualHost.Ulimits {
+		container.Ulimits = append(container.Ulimits, Ulimit{Name: ulimit.Name, Soft: ulimit.Soft, Hard: ulimit.Hard})
+	}
+
+	// logging is omitted if it is the default one of rocker-compose
+	defaults := (&Container{}).GetAPIHostConfig().LogConfig
+	logConfig := actualHost.LogConfig
+	if logConfig.Type != defaults.Type || !reflect.DeepEqual(inspectMap(logConfig.Config), inspectMap(defaults.Config)) {
+		container.LogDriver = setString(logConfig.Type, "")
+		if len(logConfig.Config) > 0 {
+			container.LogOpt = logConfig.Config
+		}
+	}
+
+	if !apiContainer.State.Running {
+		created := State("created")
+		container.State = &created
+	}
+
+	return container
+}
+
+// inspectContainerName parses a name of a referenced container, a name
+// without namespace refers to a global container
+func inspectContainerName(name string) *ContainerName {
+	containerName := NewContainerNameFromString(name)
+	if containerName.Namespace == "" {
+		containerName.Namespace = "."
+	}
+	return containerName
+}
+
+type portsByName []PortBinding
+
+func (p portsByName) Len() int           { return len(p) }
+func (p portsByName) Less(i, j int) bool { return p[i].Port+p[i].HostPort < p[j].Port+p[j].HostPort }
+func (p portsByName) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+
+// inspectDefault returns the first non-zero value, values are of the same type
+func inspectDefault(values ...interface{}) interface{} {
+	for _, value := range values {
+		if !reflect.DeepEqual(value, reflect.Zero(reflect.TypeOf(value)).Interface()) {
+			return value
+		}
+	}
+	return values[len(values)-1]
+}
+
+// inspectBool returns the value of an optional flag, false if it is not set
+func inspectBool(value *bool) bool {
+	return value != nil && *value
+}
+
+// inspectInt64 returns the value of an optional number, 0 if it is not set
+func inspectInt64(value *int64) int64 {
+	if value == nil {
+		return 0
+	}
+	return *value
+}
+
+// inspectList makes nil and empty lists equal
+func inspectList(list []string) []string {
+	if len(list) == 0 {
+		return []string{}
+	}
+	return list
+}
+
+func inspectStrings(list []string) map[string]bool {
+	result := map[string]bool{}
+	for _, item := range list {
+		result[item] = true
+	}
+	return result
+}
+
+func inspectSet(set map[string]struct{}) map[string]bool {
+	result := map[string]bool{}
+	for item := range set {
+		result[item] = true
+	}
+	return result
+}
+
+// inspectMap merges maps, values of latter ones override values of former
+func inspectMap(maps ...map[string]string) map[string]string {
+	result := map[string]string{}
+	for _, m := range maps {
+		for key, value := range m {
+			result[key] = value
+		}
+	}
+	return result
+}
+
+// inspectEnv merges lists of "KEY=value" variables, latter ones override former
+func inspectEnv(lists ...[]string) map[string]string {
+	result := map[string]string{}
+	for _, list := range lists {
+		for _, item := range list {
+			split := strings.SplitN(item, "=", 2)
+			if len(split) == 1 {
+				split = append(split, "")
+			}
+			result[split[0]] = split[1]
+		}
+	}
+	return result
+}
+
+// inspectDevices formats devices as "host_path:container_path:permissions"
+func inspectDevices(devices []docker.Device) map[string]bool {
+	result := map[string]bool{}
+	for _, device := range devices {
+		result[fmt.Sprintf("%s:%s:%s", device.PathOnHost, device.PathInContainer, device.CgroupPermissions)] = true
+	}
+	return result
+}
+
+// inspectTmpfs formats tmpfs mounts as "path" or "path:options"
+func inspectTmpfs(mounts map[string]string) map[string]bool {
+	result := map[string]bool{}
+	for path, options := range mounts {
+		if options != "" {
+			path += ":" + options
+		}
+		result[path] = true
+	}
+	return result
+}
+
+// inspectBlockLimits formats rate limits of devices as "path:rate"
+func inspectBlockLimits(limits []docker.BlockLimit) map[string]bool {
+	result := map[string]bool{}
+	for _, limit := range limits {
+		result[fmt.Sprintf("%s:%d", limit.Path, limit.Rate)] = true
+	}
+	return result
+}
+
+// inspectPorts normalizes ports to "port/protocol"
+func inspectPorts(ports map[docker.Port]struct{}) map[string]bool {
+	result := map[string]bool{}
+	for port := range ports {
+		result[inspectPort(port)] = true
+	}
+	return result
+}
+
+func inspectPort(port docker.Port) string {
+	if !strings.Contains(string(port), "/") {
+		return string(port) + "/tcp"
+	}
+	return string(port)
+}
+
+func inspectPortBindings(bindings map[docker.Port][]docker.PortBinding) map[string]bool {
+	result := map[string]bool{}
+	for port, list := range bindings {
+		for _, binding := range list {
+			result[fmt.Sprintf("%s:%s:%s", binding.HostIP, binding.HostPort, inspectPort(port))] = true
+		}
+	}
+	return result
+}
+
+// inspectLinks converts links given by docker as "/ns.db:/ns.web/alias" to "ns.db:alias"
+func inspectLinks(links []string) map[string]bool {
+	result := map[string]bool{}
+	for _, link := range links {
+		split := strings.SplitN(link, ":", 2)
+		name := strings.TrimPrefix(split[0], "/")
+		alias := name
+		if len(split) == 2 {
+			alias = split[1][strings.LastIndex(split[1], "/")+1:]
+		}
+		result[name+":"+alias] = true
+	}
+	return result
+}
+
+func inspectNames(names []string) map[string]bool {
+	result := map[string]bool{}
+	for _, name := range names {
+		result[strings.TrimPrefix(name, "/")] = true
+	}
+	return result
+}
+
+func inspectNetworkMode(mode string) string {
+	if mode == "" || mode == "default" {
+		return "bridge"
+	}
+	return strings.Replace(mode, ":/", ":", 1)
+}
+
+func inspectIpcMode(mode string) string {
+	return strings.Replace(mode, ":/", ":", 1)
+}
+
+func inspectRestartPolicy(policy docker.RestartPolicy) docker.RestartPolicy {
+	if policy.Name == "" {
+		policy.Name = "no"
+	}
+	return policy
+}
+
+func uniqueStrings(list []string) []string {
+	seen := map[string]bool{}
+	result := []string{}
+	for _, item := range list {
+		if !seen[item] {
+			seen[item] = true
+			result = append(result, item)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,180 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"strings"
+	"testing"
+
+	docker "github.com/fsouza/go-dockerclient"
+	"github.com/grammarly/rocker/src/template"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigDiffAPIContainer(t *testing.T) {
+	manifest := `namespace: myapp
+containers:
+  db:
+    image: mysql:5.6
+  web:
+    image: nginx:1.9
+    env:
+      MODE: production
+    ports:
+      - "80:80"
+    links: db
+    memory: 128m
+    log_driver: syslog
+    cap_add: [NET_ADMIN, SYS_ADMIN]
+    devices: /dev/fuse
+    oom_kill_disable: true
+    device_read_bps: /dev/sda:1m
+    ipc: host
+    shm_size: 128m
+    tmpfs: /run:size=64m
+    sysctls:
+      net.core.somaxconn: 1024
+`
+	config, err := ReadConfig("compose.yml", strings.NewReader(manifest), template.Vars{}, map[string]interface{}{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	image := &docker.Config{
+		Cmd:          []string{"nginx", "-g", "daemon off;"},
+		Env:          []string{"PATH=/usr/bin", "MODE=development"},
+		ExposedPorts: map[docker.Port]struct{}{"80/tcp": {}, "443/tcp": {}},
+	}
+
+	oomKillDisable, noPidsLimit := true, int64(-1)
+	inspect := func() *docker.Container {
+		return &docker.Container{
+			Config: &docker.Config{
+				Hostname:     "4f2a1b3c5d6e",
+				Cmd:          []string{"nginx", "-g", "daemon off;"},
+				Env:          []string{"PATH=/usr/bin", "MODE=production"},
+				ExposedPorts: map[docker.Port]struct{}{"80/tcp": {}, "443/tcp": {}},
+			},
+			HostConfig: &docker.HostConfig{
+				Links:              []string{"/myapp.db:/myapp.web/db"},
+				PortBindings:       map[docker.Port][]docker.PortBinding{"80/tcp": {{HostPort: "80"}}},
+				NetworkMode:        "default",
+				RestartPolicy:      docker.RestartPolicy{Name: "always"},
+				Memory:             128 * 1024 * 1024,
+				MemorySwap:         256 * 1024 * 1024,
+				LogConfig:          docker.LogConfig{Type: "syslog", Config: map[string]string{}},
+				CapAdd:             []string{"SYS_ADMIN", "NET_ADMIN"},
+				Devices:            []docker.Device{{PathOnHost: "/dev/fuse", PathInContainer: "/dev/fuse", CgroupPermissions: "rwm"}},
+				OOMKillDisable:     &oomKillDisable,
+				PidsLimit:          &noPidsLimit,
+				BlkioDeviceReadBps: []docker.BlockLimit{{Path: "/dev/sda", Rate: 1024 * 1024}},
+				IpcMode:            "host",
+				ShmSize:            128 * 1024 * 1024,
+				Tmpfs:              map[string]string{"/run": "size=64m"},
+				Sysctls:            map[string]string{"net.core.somaxconn": "1024"},
+			},
+		}
+	}
+
+	web := config.Containers["web"]
+	assert.Equal(t, []string{}, web.DiffAPIContainer(inspect(), image))
+
+	changed := inspect()
+	changed.Config.Env = []string{"PATH=/usr/bin", "MODE=development"}
+	changed.HostConfig.Links = nil
+	changed.HostConfig.RestartPolicy = docker.RestartPolicy{}
+	changed.HostConfig.LogConfig = docker.LogConfig{Type: "json-file"}
+	changed.HostConfig.Devices = nil
+	changed.HostConfig.OOMKillDisable = nil
+	changed.HostConfig.IpcMode = "shareable"
+	changed.HostConfig.ShmSize = 64 * 1024 * 1024
+	changed.HostConfig.Tmpfs = map[string]string{"/run": ""}
+	assert.Equal(t, []string{"devices", "env", "ipc", "links", "log_driver", "oom_kill_disable", "restart", "shm_size", "tmpfs"}, web.DiffAPIContainer(changed, image))
+
+	// the ipc mode and the size of /dev/shm are daemon defaults if they are not specified
+	diffs := config.Containers["db"].DiffAPIContainer(&docker.Container{
+		Config:     &docker.Config{},
+		HostConfig: &docker.HostConfig{IpcMode: "private", ShmSize: 64 * 1024 * 1024},
+	}, &docker.Config{})
+	assert.NotContains(t, diffs, "ipc")
+	assert.NotContains(t, diffs, "shm_size")
+}
+
+func TestConfigNewFromAPIContainer(t *testing.T) {
+	image := &docker.Config{
+		Cmd:          []string{"nginx", "-g", "daemon off;"},
+		Env:          []string{"PATH=/usr/bin"},
+		ExposedPorts: map[docker.Port]struct{}{"80/tcp": {}},
+		Volumes:      map[string]struct{}{"/var/cache/nginx": {}},
+	}
+
+	container := NewFromAPIContainer(&docker.Container{
+		ID:    "4f2a1b3c5d6e7f80",
+		State: docker.State{Running: true},
+		Config: &docker.Config{
+			Hostname:     "4f2a1b3c5d6e",
+			Image:        "nginx:1.9",
+			Cmd:          []string{"nginx", "-g", "daemon off;"},
+			Env:          []string{"PATH=/usr/bin", "MODE=production"},
+			Labels:       map[string]string{"rocker-compose-id": "123", "team": "web"},
+			ExposedPorts: map[docker.Port]struct{}{"80/tcp": {}, "443/tcp": {}, "8080/tcp": {}},
+			Volumes:      map[string]struct{}{"/var/cache/nginx": {}, "/data": {}, "/etc/nginx": {}},
+		},
+		HostConfig: &docker.HostConfig{
+			Binds:          []string{"/etc/nginx:/etc/nginx:ro"},
+			Links:          []string{"/myapp.db:/myapp.web/db", "/statsd:/myapp.web/statsd"},
+			PortBindings:   map[docker.Port][]docker.PortBinding{"443/tcp": {{HostPort: "443"}}},
+			NetworkMode:    "default",
+			RestartPolicy:  docker.RestartPolicy{Name: "on-failure", MaximumRetryCount: 3},
+			Memory:         128 * 1024 * 1024,
+			MemorySwap:     256 * 1024 * 1024,
+			LogConfig:      docker.LogConfig{Type: "json-file", Config: map[string]string{"max-file": "5", "max-size": "100m"}},
+			CapAdd:         []string{"NET_ADMIN"},
+			Devices:        []docker.Device{{PathOnHost: "/dev/sda", PathInContainer: "/dev/xvda", CgroupPermissions: "r"}},
+			ReadonlyRootfs: true,
+			IpcMode:        "container:myapp.db",
+			ShmSize:        64 * 1024 * 1024,
+			Tmpfs:          map[string]string{"/run": "size=64m", "/tmp": ""},
+			Sysctls:        map[string]string{"net.core.somaxconn": "1024"},
+		},
+	}, image)
+
+	assert.Equal(t, "nginx:1.9", *container.Image)
+	assert.Nil(t, container.Cmd)
+	assert.Nil(t, container.Hostname)
+	assert.Equal(t, StringMap{"MODE": "production"}, container.Env)
+	assert.Equal(t, StringMap{"team": "web"}, container.Labels)
+	assert.Equal(t, Strings{"8080"}, container.Expose)
+	assert.Equal(t, Ports{{Port: "443/tcp", HostPort: "443"}}, container.Ports)
+	assert.Equal(t, Strings{"/etc/nginx:/etc/nginx:ro", "/data"}, container.Volumes)
+	assert.Equal(t, "myapp.db:db", container.Links[0].String())
+	assert.True(t, container.Links[1].IsGlobalNs())
+	assert.Nil(t, container.Net)
+	assert.Equal(t, &RestartPolicy{"on-failure", 3}, container.Restart)
+	assert.EqualValues(t, 128*1024*1024, *container.Memory)
+	assert.Nil(t, container.MemorySwap)
+	assert.Nil(t, container.LogDriver)
+	assert.Nil(t, container.State)
+	assert.Equal(t, Strings{"NET_ADMIN"}, container.CapAdd)
+	assert.Equal(t, Strings{"/dev/sda:/dev/xvda:r"}, container.Devices)
+	assert.True(t, *container.ReadOnly)
+	assert.Nil(t, container.UsernsMode)
+	assert.Equal(t, "container:myapp.db", container.Ipc.String())
+	assert.Nil(t, container.ShmSize)
+	assert.Equal(t, Strings{"/run:size=64m", "/tmp"}, container.Tmpfs)
+	assert.Equal(t, StringMap{"net.core.somaxconn": "1024"}, container.Sysctls)
+}
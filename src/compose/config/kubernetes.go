@@ -0,0 +1,556 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-yaml/yaml"
+)
+
+var (
+	kubernetesNameRegexp = regexp.MustCompile(`[^a-z0-9-]+`)
+	kubernetesUserRegexp = regexp.MustCompile(`^([0-9]+)(:([0-9]+))?$`)
+)
+
+// ExportKubernetes converts the manifest to kubernetes objects: the namespace, a Deployment
+// for every running container (with no replicas for `state: created`), a Job for every container
+// with `state: ran`, and a Service for every container that has ports or expose, so other
+// containers can reach it by its name. Properties that cannot be represented in kubernetes
+// (volumes_from, net: container, wait_for, link aliases, etc.) are skipped and returned as errors.
+func (config *Config) ExportKubernetes() ([]yaml.MapSlice, ValidationErrors) {
+	var (
+		ex        = &exporter{namespace: config.Namespace}
+		namespace = kubernetesName(config.Namespace)
+		docs      = []yaml.MapSlice{}
+	)
+
+	if namespace != "" {
+		docs = append(docs, yaml.MapSlice{
+			{Key: "apiVersion", Value: "v1"},
+			{Key: "kind", Value: "Namespace"},
+			{Key: "metadata", Value: yaml.MapSlice{{Key: "name", Value: namespace}}},
+		})
+	}
+
+	for _, name := range config.sortedNames() {
+		container := config.Containers[name]
+		ex.kubernetesLinks(name, config)
+
+		if service := ex.kubernetesService(name, namespace, container); service != nil {
+			docs = append(docs, service)
+		}
+		docs = append(docs, ex.kubernetesWorkload(name, namespace, container))
+	}
+
+	return docs, ex.result()
+}
+
+// kubernetesWorkload makes a Deployment or a Job of the container depending on its state
+func (ex *exporter) kubernetesWorkload(name, namespace string, container *Container) yaml.MapSlice {
+	var (
+		hostConfig = container.GetAPIHostConfig()
+		restart    = hostConfig.RestartPolicy.Name
+		pod        = ex.kubernetesPod(name, container)
+		template   = yaml.MapSlice{
+			{Key: "metadata", Value: kubernetesPodMetadata(name, container)},
+		}
+	)
+
+	if container.State.IsRan() {
+		policy := "Never"
+		if restart == "on-failure" || restart == "always" {
+			policy = "OnFailure"
+		}
+		if restart == "always" {
+			ex.unsupported(name, "restart", "restart policy `always` is not supported by kubernetes jobs, OnFailure is used")
+		}
+		pod = append(yaml.MapSlice{{Key: "restartPolicy", Value: policy}}, pod...)
+		template = append(template, yaml.MapItem{Key: "spec", Value: pod})
+
+		spec := yaml.MapSlice{}
+		if hostConfig.RestartPolicy.MaximumRetryCount > 0 {
+			spec = append(spec, yaml.MapItem{Key: "backoffLimit", Value: hostConfig.RestartPolicy.MaximumRetryCount})
+		}
+		spec = append(spec, yaml.MapItem{Key: "template", Value: template})
+
+		return yaml.MapSlice{
+			{Key: "apiVersion", Value: "batch/v1"},
+			{Key: "kind", Value: "Job"},
+			{Key: "metadata", Value: kubernetesMetadata(name, namespace)},
+			{Key: "spec", Value: spec},
+		}
+	}
+
+	// containers with `state: created` are not started, so the deployment is scaled to zero
+	replicas := 1
+	if container.State != nil && *container.State == "created" {
+		replicas = 0
+	} else if restart != "always" {
+		ex.unsupported(name, "restart", "restart policy `%s` is not supported by kubernetes deployments, containers are always restarted", restart)
+	}
+
+	template = append(template, yaml.MapItem{Key: "spec", Value: pod})
+
+	return yaml.MapSlice{
+		{Key: "apiVersion", Value: "apps/v1"},
+		{Key: "kind", Value: "Deployment"},
+		{Key: "metadata", Value: kubernetesMetadata(name, namespace)},
+		{Key: "spec", Value: yaml.MapSlice{
+			{Key: "replicas", Value: replicas},
+			{Key: "selector", Value: yaml.MapSlice{
+				{Key: "matchLabels", Value: yaml.MapSlice{{Key: "app", Value: kubernetesName(name)}}},
+			}},
+			{Key: "template", Value: template},
+		}},
+	}
+}
+
+// kubernetesPod makes the pod spec of the container
+func (ex *exporter) kubernetesPod(name string, container *Container) yaml.MapSlice {
+	var (
+		pod        = yaml.MapSlice{}
+		spec       = yaml.MapSlice{{Key: "name", Value: kubernetesName(name)}}
+		apiConfig  = container.GetAPIConfig()
+		hostConfig = container.GetAPIHostConfig()
+		security   = yaml.MapSlice{}
+	)
+
+	setPod := func(key string, value interface{}) {
+		pod = append(pod, yaml.MapItem{Key: key, Value: value})
+	}
+	setSpec := func(key string, value interface{}) {
+		spec = append(spec, yaml.MapItem{Key: key, Value: value})
+	}
+
+	setSpec("image", apiConfig.Image)
+	if len(apiConfig.Entrypoint) > 0 {
+		setSpec("command", apiConfig.Entrypoint)
+	}
+	if len(apiConfig.Cmd) > 0 {
+		setSpec("args", apiConfig.Cmd)
+	}
+	if apiConfig.WorkingDir != "" {
+		setSpec("workingDir", apiConfig.WorkingDir)
+	}
+	if apiConfig.Tty {
+		setSpec("tty", true)
+	}
+	if apiConfig.OpenStdin {
+		setSpec("stdin", true)
+	}
+
+	if len(container.Env) > 0 {
+		keys := []string{}
+		for key := range container.Env {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		env := []yaml.MapSlice{}
+		for _, key := range keys {
+			env = append(env, yaml.MapSlice{{Key: "name", Value: key}, {Key: "value", Value: container.Env[key]}})
+		}
+		setSpec("env", env)
+	}
+
+	if ports := ex.kubernetesContainerPorts(name, container); len(ports) > 0 {
+		setSpec("ports", ports)
+	}
+
+	resources, limits, requests := yaml.MapSlice{}, yaml.MapSlice{}, yaml.MapSlice{}
+	if cpus := exportCPUs(hostConfig); cpus > 0 {
+		limits = append(limits, yaml.MapItem{Key: "cpu", Value: fmt.Sprintf("%dm", int64(cpus*1000))})
+	}
+	if hostConfig.Memory > 0 {
+		limits = append(limits, yaml.MapItem{Key: "memory", Value: hostConfig.Memory})
+	}
+	// the weight of 1024 cpu shares is a whole cpu
+	if apiConfig.CPUShares > 0 {
+		requests = append(requests, yaml.MapItem{Key: "cpu", Value: fmt.Sprintf("%dm", apiConfig.CPUShares*1000/1024)})
+	}
+	if hostConfig.MemoryReservation > 0 {
+		requests = append(requests, yaml.MapItem{Key: "memory", Value: hostConfig.MemoryReservation})
+	}
+	if len(limits) > 0 {
+		resources = append(resources, yaml.MapItem{Key: "limits", Value: limits})
+	}
+	if len(requests) > 0 {
+		resources = append(resources, yaml.MapItem{Key: "requests", Value: requests})
+	}
+	if len(resources) > 0 {
+		setSpec("resources", resources)
+	}
+
+	if hostConfig.Privileged {
+		security = append(security, yaml.MapItem{Key: "privileged", Value: true})
+	}
+	if apiConfig.User != "" {
+		if match := kubernetesUserRegexp.FindStringSubmatch(apiConfig.User); match != nil {
+			uid, _ := strconv.ParseInt(match[1], 10, 64)
+			security = append(security, yaml.MapItem{Key: "runAsUser", Value: uid})
+			if match[3] != "" {
+				gid, _ := strconv.ParseInt(match[3], 10, 64)
+				security = append(security, yaml.MapItem{Key: "runAsGroup", Value: gid})
+			}
+		} else {
+			ex.unsupported(name, "user", "user `%s` is not supported by kubernetes, only numeric uid[:gid] is", apiConfig.User)
+		}
+	}
+	if len(hostConfig.CapAdd) > 0 || len(hostConfig.CapDrop) > 0 {
+		capabilities := yaml.MapSlice{}
+		if len(hostConfig.CapAdd) > 0 {
+			capabilities = append(capabilities, yaml.MapItem{Key: "add", Value: hostConfig.CapAdd})
+		}
+		if len(hostConfig.CapDrop) > 0 {
+			capabilities = append(capabilities, yaml.MapItem{Key: "drop", Value: hostConfig.CapDrop})
+		}
+		security = append(security, yaml.MapItem{Key: "capabilities", Value: capabilities})
+	}
+	if hostConfig.ReadonlyRootfs {
+		security = append(security, yaml.MapItem{Key: "readOnlyRootFilesystem", Value: true})
+	}
+	if len(security) > 0 {
+		setSpec("securityContext", security)
+	}
+
+	// bind mounts become hostPath volumes, other volumes become emptyDir ones
+	var (
+		mounts  = []yaml.MapSlice{}
+		volumes = []yaml.MapSlice{}
+		anon    = []string{}
+	)
+	for volume := range apiConfig.Volumes {
+		anon = append(anon, volume)
+	}
+	sort.Strings(anon)
+	addVolume := func(source yaml.MapItem, path string, readOnly bool) {
+		volumeName := fmt.Sprintf("volume-%d", len(volumes))
+		mount := yaml.MapSlice{{Key: "name", Value: volumeName}, {Key: "mountPath", Value: path}}
+		if readOnly {
+			mount = append(mount, yaml.MapItem{Key: "readOnly", Value: true})
+		}
+		mounts = append(mounts, mount)
+		volumes = append(volumes, yaml.MapSlice{{Key: "name", Value: volumeName}, source})
+	}
+	for _, bind := range hostConfig.Binds {
+		split := strings.Split(bind, ":")
+		addVolume(yaml.MapItem{Key: "hostPath", Value: yaml.MapSlice{{Key: "path", Value: split[0]}}},
+			split[1], len(split) > 2 && strings.Contains(split[2], "ro"))
+	}
+	for _, path := range anon {
+		addVolume(yaml.MapItem{Key: "emptyDir", Value: yaml.MapSlice{}}, path, false)
+	}
+
+	// tmpfs mounts and /dev/shm become emptyDir volumes in memory
+	tmpfs := []string{}
+	for path := range hostConfig.Tmpfs {
+		tmpfs = append(tmpfs, path)
+	}
+	sort.Strings(tmpfs)
+	for _, path := range tmpfs {
+		source := yaml.MapSlice{{Key: "medium", Value: "Memory"}}
+		for _, option := range strings.Split(hostConfig.Tmpfs[path], ",") {
+			size := strings.TrimPrefix(option, "size=")
+			switch {
+			case option == "" || option == "rw":
+			case size != option && memoryRegexp.MatchString(size):
+				limit, _ := NewConfigMemoryFromString(size)
+				source = append(source, yaml.MapItem{Key: "sizeLimit", Value: limit.Int64()})
+			default:
+				ex.unsupported(name, "tmpfs", "option `%s` of tmpfs `%s` is not supported by kubernetes", option, path)
+			}
+		}
+		addVolume(yaml.MapItem{Key: "emptyDir", Value: source}, path, false)
+	}
+	if hostConfig.ShmSize > 0 {
+		addVolume(yaml.MapItem{Key: "emptyDir", Value: yaml.MapSlice{
+			{Key: "medium", Value: "Memory"},
+			{Key: "sizeLimit", Value: hostConfig.ShmSize},
+		}}, "/dev/shm", false)
+	}
+	if len(mounts) > 0 {
+		setSpec("volumeMounts", mounts)
+	}
+	for _, ref := range container.VolumesFrom {
+		ex.unsupported(name, "volumes_from", "volumes_from `%s` is not supported by kubernetes, containers of different pods cannot share volumes", ref.String())
+	}
+
+	if apiConfig.Hostname != "" {
+		setPod("hostname", apiConfig.Hostname)
+	}
+	if apiConfig.Domainname != "" {
+		ex.unsupported(name, "domainname", "not supported by kubernetes")
+	}
+
+	switch {
+	case apiConfig.NetworkDisabled:
+		ex.unsupported(name, "network_disabled", "not supported by kubernetes")
+	case container.Net == nil || container.Net.Type == "bridge":
+	case container.Net.Type == "host":
+		setPod("hostNetwork", true)
+	case container.Net.Type == "container":
+		ex.unsupported(name, "net", "network of container `%s` cannot be shared by a different pod", container.Net.Container.String())
+	default:
+		ex.unsupported(name, "net", "network mode `%s` is not supported by kubernetes", container.Net.Type)
+	}
+
+	if hostConfig.PidMode == "host" {
+		setPod("hostPID", true)
+	} else if hostConfig.PidMode != "" {
+		ex.unsupported(name, "pid", "pid mode `%s` is not supported by kubernetes", hostConfig.PidMode)
+	}
+
+	switch {
+	case container.Ipc == nil || container.Ipc.Type == "private" || container.Ipc.Type == "shareable":
+	case container.Ipc.Type == "host":
+		setPod("hostIPC", true)
+	case container.Ipc.Type == "container":
+		ex.unsupported(name, "ipc", "ipc namespace of container `%s` cannot be shared by a different pod", container.Ipc.Container.String())
+	default:
+		ex.unsupported(name, "ipc", "ipc mode `%s` is not supported by kubernetes", container.Ipc.Type)
+	}
+
+	if len(hostConfig.DNS) > 0 {
+		setPod("dnsConfig", yaml.MapSlice{{Key: "nameservers", Value: hostConfig.DNS}})
+	}
+
+	// add_host entries are "host:ip", kubernetes groups host names by ip
+	if len(hostConfig.ExtraHosts) > 0 {
+		var (
+			ips       = []string{}
+			hostnames = map[string][]string{}
+		)
+		for _, host := range hostConfig.ExtraHosts {
+			split := strings.SplitN(host, ":", 2)
+			if len(split) != 2 {
+				continue
+			}
+			if _, ok := hostnames[split[1]]; !ok {
+				ips = append(ips, split[1])
+			}
+			hostnames[split[1]] = append(hostnames[split[1]], split[0])
+		}
+		aliases := []yaml.MapSlice{}
+		for _, ip := range ips {
+			aliases = append(aliases, yaml.MapSlice{{Key: "ip", Value: ip}, {Key: "hostnames", Value: hostnames[ip]}})
+		}
+		setPod("hostAliases", aliases)
+	}
+
+	// only numeric groups can be supplemental groups of the pod
+	groups := []int64{}
+	for _, group := range hostConfig.GroupAdd {
+		gid, err := strconv.ParseInt(group, 10, 64)
+		if err != nil {
+			ex.unsupported(name, "group_add", "group `%s` is not supported by kubernetes, only numeric gid is", group)
+			continue
+		}
+		groups = append(groups, gid)
+	}
+	podSecurity := yaml.MapSlice{}
+	if len(groups) > 0 {
+		podSecurity = append(podSecurity, yaml.MapItem{Key: "supplementalGroups", Value: groups})
+	}
+	if len(hostConfig.Sysctls) > 0 {
+		keys := []string{}
+		for key := range hostConfig.Sysctls {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		sysctls := []yaml.MapSlice{}
+		for _, key := range keys {
+			sysctls = append(sysctls, yaml.MapSlice{{Key: "name", Value: key}, {Key: "value", Value: hostConfig.Sysctls[key]}})
+		}
+		podSecurity = append(podSecurity, yaml.MapItem{Key: "sysctls", Value: sysctls})
+	}
+	if len(podSecurity) > 0 {
+		setPod("securityContext", podSecurity)
+	}
+
+	if container.StopGracePeriod != nil || container.KillTimeout != nil {
+		setPod("terminationGracePeriodSeconds", container.StopTimeout())
+	}
+
+	setPod("containers", []yaml.MapSlice{spec})
+	if len(volumes) > 0 {
+		setPod("volumes", volumes)
+	}
+
+	for _, ref := range container.WaitFor {
+		ex.unsupported(name, "wait_for", "kubernetes does not start pods in order, the container does not wait for `%s`", ref.String())
+	}
+	if len(hostConfig.Ulimits) > 0 {
+		ex.unsupported(name, "ulimits", "not supported by kubernetes")
+	}
+	if len(hostConfig.Devices) > 0 {
+		ex.unsupported(name, "devices", "not supported by kubernetes, use a device plugin or a privileged container")
+	}
+	if len(hostConfig.SecurityOpt) > 0 {
+		ex.unsupported(name, "security_opt", "not supported by kubernetes, use fields of the pod securityContext instead")
+	}
+	if hostConfig.UsernsMode != "" {
+		ex.unsupported(name, "userns_mode", "not supported by kubernetes")
+	}
+	if hostConfig.Init {
+		ex.unsupported(name, "init", "not supported by kubernetes, the process of the container runs as PID 1")
+	}
+	if apiConfig.StopSignal != "" {
+		ex.unsupported(name, "stop_signal", "not supported by kubernetes, containers are stopped by SIGTERM")
+	}
+	if hostConfig.LogConfig.Type != "" && hostConfig.LogConfig.Type != "json-file" {
+		ex.unsupported(name, "log_driver", "log driver `%s` is not supported by kubernetes", hostConfig.LogConfig.Type)
+	}
+	ex.unsupportedCommon(name, container, hostConfig, "kubernetes")
+
+	return pod
+}
+
+// kubernetesContainerPorts makes ports of the container spec, published ports are bound
+// on the node by hostPort as docker does it
+func (ex *exporter) kubernetesContainerPorts(name string, container *Container) []yaml.MapSlice {
+	ports := []yaml.MapSlice{}
+	for _, binding := range container.Ports {
+		port, proto, err := kubernetesPort(binding.Port)
+		if err != nil {
+			ex.unsupported(name, "ports", "port `%s` is not supported by kubernetes, port ranges are not supported", binding.Port)
+			continue
+		}
+		spec := yaml.MapSlice{{Key: "containerPort", Value: port}, {Key: "protocol", Value: proto}}
+		if binding.HostPort != "" {
+			hostPort, err := strconv.Atoi(binding.HostPort)
+			if err != nil {
+				ex.unsupported(name, "ports", "host port `%s` is not supported by kubernetes, port ranges are not supported", binding.HostPort)
+				continue
+			}
+			spec = append(spec, yaml.MapItem{Key: "hostPort", Value: hostPort})
+		}
+		if binding.HostIP != "" {
+			spec = append(spec, yaml.MapItem{Key: "hostIP", Value: binding.HostIP})
+		}
+		ports = append(ports, spec)
+	}
+	return ports
+}
+
+// kubernetesService makes a Service of the container if it has ports or expose,
+// it is named after the container, so it can be reached by the name
+func (ex *exporter) kubernetesService(name, namespace string, container *Container) yaml.MapSlice {
+	var (
+		ports = []yaml.MapSlice{}
+		seen  = map[string]bool{}
+		all   = append([]string{}, container.Expose...)
+	)
+
+	for _, binding := range container.Ports {
+		all = append(all, binding.Port)
+	}
+
+	for _, str := range all {
+		port, proto, err := kubernetesPort(str)
+		if err != nil {
+			// reported by kubernetesContainerPorts for ports
+			continue
+		}
+		key := fmt.Sprintf("%s-%d", strings.ToLower(proto), port)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		ports = append(ports, yaml.MapSlice{
+			{Key: "name", Value: key},
+			{Key: "port", Value: port},
+			{Key: "targetPort", Value: port},
+			{Key: "protocol", Value: proto},
+		})
+	}
+
+	if len(ports) == 0 {
+		return nil
+	}
+
+	return yaml.MapSlice{
+		{Key: "apiVersion", Value: "v1"},
+		{Key: "kind", Value: "Service"},
+		{Key: "metadata", Value: kubernetesMetadata(name, namespace)},
+		{Key: "spec", Value: yaml.MapSlice{
+			{Key: "selector", Value: yaml.MapSlice{{Key: "app", Value: kubernetesName(name)}}},
+			{Key: "ports", Value: ports},
+		}},
+	}
+}
+
+// kubernetesLinks reports links that do not work in kubernetes: a linked container is reachable
+// by the name of its service, so aliases different from it or containers without ports cannot be linked
+func (ex *exporter) kubernetesLinks(name string, config *Config) {
+	for _, link := range config.Containers[name].Links {
+		ref := link.ContainerName
+		hostname := kubernetesName(ref.Name)
+		if ns := ref.GetNamespace(); ns != config.Namespace && ns != "" {
+			hostname = hostname + "." + kubernetesName(ns)
+		}
+		if ref.IsGlobalNs() {
+			ex.unsupported(name, "links", "linked container `%s` of the global namespace has no kubernetes service", ref.Name)
+		} else if target, ok := config.Containers[ref.Name]; ok && ref.Namespace == config.Namespace &&
+			len(target.Ports) == 0 && len(target.Expose) == 0 {
+			ex.unsupported(name, "links", "linked container `%s` has no ports or expose, so it has no kubernetes service", ref.Name)
+		} else if link.Alias != hostname {
+			ex.unsupported(name, "links", "link alias `%s` is not supported by kubernetes, container `%s` is reachable as `%s`",
+				link.Alias, ref.String(), hostname)
+		}
+	}
+}
+
+func kubernetesMetadata(name, namespace string) yaml.MapSlice {
+	metadata := yaml.MapSlice{{Key: "name", Value: kubernetesName(name)}}
+	if namespace != "" {
+		metadata = append(metadata, yaml.MapItem{Key: "namespace", Value: namespace})
+	}
+	return append(metadata, yaml.MapItem{Key: "labels", Value: yaml.MapSlice{{Key: "app", Value: kubernetesName(name)}}})
+}
+
+// kubernetesPodMetadata labels pods for selectors, container labels are kept as annotations
+// since kubernetes label values are restricted
+func kubernetesPodMetadata(name string, container *Container) yaml.MapSlice {
+	metadata := yaml.MapSlice{{Key: "labels", Value: yaml.MapSlice{{Key: "app", Value: kubernetesName(name)}}}}
+	if len(container.Labels) > 0 {
+		metadata = append(metadata, yaml.MapItem{Key: "annotations", Value: container.Labels})
+	}
+	return metadata
+}
+
+// kubernetesPort parses "8080/tcp" or "8080" into a port number and an upper-case protocol
+func kubernetesPort(str string) (int, string, error) {
+	var (
+		split = strings.SplitN(str, "/", 2)
+		proto = "TCP"
+	)
+	if len(split) == 2 {
+		proto = strings.ToUpper(split[1])
+	}
+	port, err := strconv.Atoi(split[0])
+	return port, proto, err
+}
+
+// kubernetesName makes a valid kubernetes object name: lower case letters, digits and dashes
+func kubernetesName(name string) string {
+	name = strings.ToLower(strings.Replace(name, "_", "-", -1))
+	return strings.Trim(kubernetesNameRegexp.ReplaceAllString(name, "-"), "-")
+}
@@ -0,0 +1,249 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/go-yaml/yaml"
+)
+
+var (
+	// mergeAliases are alias properties that are renamed before merging,
+	// so `environment` of one config merges with `env` of another one
+	mergeAliases = map[string]string{
+		"command":     "cmd",
+		"link":        "links",
+		"label":       "labels",
+		"hosts":       "add_host",
+		"extra_hosts": "add_host",
+		"working_dir": "workdir",
+		"environment": "env",
+	}
+
+	// mergeReplaceLists are list properties that are replaced as a whole,
+	// all other lists are appended
+	mergeReplaceLists = map[string]bool{
+		"cmd":        true,
+		"entrypoint": true,
+	}
+
+	// mergeKeyValueMaps are properties that can be given either as a map
+	// or as a list of "key=value" strings, they are merged as maps
+	mergeKeyValueMaps = map[string]bool{
+		"env":     true,
+		"labels":  true,
+		"log_opt": true,
+		"sysctls": true,
+	}
+)
+
+// mergeConfigs deep-merges parsed configs, later ones into earlier ones. The rules are:
+//
+//   - root level properties (namespace, strict) of later configs override earlier ones;
+//   - containers are merged by names, containers that are missing in earlier configs are added;
+//   - scalar properties of containers override, null removes the property;
+//   - maps (labels, env, log_opt, sysctls, extra...) are merged by keys, values of later configs win;
+//   - cmd and entrypoint are replaced, all other lists (ports, volumes, links, dns...) are
+//     appended, values that are already there are not added twice;
+//   - aliases (environment, command...) are merged with the properties they stand for.
+//
+// Configs in docker-compose format, without namespace and containers keys, are treated
+// as maps of containers. The result is in docker-compose format in case there is no namespace.
+func mergeConfigs(docs []map[interface{}]interface{}) interface{} {
+	var (
+		result     = map[interface{}]interface{}{}
+		containers = map[interface{}]interface{}{}
+	)
+
+	for _, doc := range docs {
+		docContainers := doc
+		if _, ok := doc["namespace"]; ok || doc["containers"] != nil {
+			for key, value := range doc {
+				if key != "containers" {
+					result[key] = value
+				}
+			}
+			docContainers, _ = doc["containers"].(map[interface{}]interface{})
+		}
+
+		for name, spec := range docContainers {
+			specMap, ok := spec.(map[interface{}]interface{})
+			if !ok {
+				// invalid specs are reported by ReadConfig
+				containers[name] = spec
+				continue
+			}
+			specMap = normalizeMergeAliases(specMap)
+			if prev, ok := containers[name].(map[interface{}]interface{}); ok {
+				containers[name] = mergeContainer(prev, specMap)
+			} else {
+				containers[name] = specMap
+			}
+		}
+	}
+
+	if result["namespace"] == nil {
+		return containers
+	}
+
+	// keep root level properties on top of containers, as they are usually written
+	keys := []string{}
+	for key := range result {
+		keys = append(keys, fmt.Sprint(key))
+	}
+	sort.Strings(keys)
+
+	merged := yaml.MapSlice{}
+	for _, key := range keys {
+		merged = append(merged, yaml.MapItem{Key: key, Value: result[key]})
+	}
+	return append(merged, yaml.MapItem{Key: "containers", Value: containers})
+}
+
+// mergeContainer merges container spec 'b' into 'a', see mergeConfigs for the rules
+func mergeContainer(a, b map[interface{}]interface{}) map[interface{}]interface{} {
+	var (
+		result     = map[interface{}]interface{}{}
+		listFields = map[string]bool{}
+	)
+
+	for _, field := range getYamlFieldsOfKind(reflect.Slice) {
+		listFields[field] = true
+	}
+	for key, value := range a {
+		result[key] = value
+	}
+
+	for key, value := range b {
+		prev, ok := result[key]
+		if !ok || prev == nil || value == nil {
+			result[key] = value
+			continue
+		}
+
+		name := fmt.Sprint(key)
+
+		if mergeKeyValueMaps[name] {
+			result[key] = mergeMaps(keyValueMap(prev), keyValueMap(value))
+			continue
+		}
+
+		prevMap, prevIsMap := prev.(map[interface{}]interface{})
+		valueMap, valueIsMap := value.(map[interface{}]interface{})
+		if prevIsMap && valueIsMap {
+			result[key] = mergeMaps(prevMap, valueMap)
+			continue
+		}
+
+		if listFields[name] && !mergeReplaceLists[name] && !prevIsMap && !valueIsMap {
+			// a single value can be given instead of a list, e.g. `ports: "8080:80"`
+			prevList, ok := prev.([]interface{})
+			if !ok {
+				prevList = []interface{}{prev}
+			}
+			valueList, ok := value.([]interface{})
+			if !ok {
+				valueList = []interface{}{value}
+			}
+			result[key] = appendUnique(prevList, valueList)
+			continue
+		}
+
+		result[key] = value
+	}
+
+	return result
+}
+
+// mergeMaps deep-merges map 'b' into 'a'
+func mergeMaps(a, b map[interface{}]interface{}) map[interface{}]interface{} {
+	result := map[interface{}]interface{}{}
+	for key, value := range a {
+		result[key] = value
+	}
+	for key, value := range b {
+		prevMap, prevIsMap := result[key].(map[interface{}]interface{})
+		valueMap, valueIsMap := value.(map[interface{}]interface{})
+		if prevIsMap && valueIsMap {
+			result[key] = mergeMaps(prevMap, valueMap)
+		} else {
+			result[key] = value
+		}
+	}
+	return result
+}
+
+// normalizeMergeAliases renames alias properties of a container spec, in case both
+// an alias and the property it stands for are given, the property wins as in ReadConfig
+func normalizeMergeAliases(spec map[interface{}]interface{}) map[interface{}]interface{} {
+	result := map[interface{}]interface{}{}
+	for key, value := range spec {
+		name, _ := key.(string)
+		target, isAlias := mergeAliases[name]
+		if !isAlias {
+			result[key] = value
+			continue
+		}
+		if _, ok := spec[target]; !ok {
+			result[target] = value
+		}
+	}
+	return result
+}
+
+// keyValueMap converts a list of "key=value" strings to a map, maps are returned as they are
+func keyValueMap(value interface{}) map[interface{}]interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		return v
+	case []interface{}:
+		result := map[interface{}]interface{}{}
+		for _, item := range v {
+			split := strings.SplitN(fmt.Sprint(item), "=", 2)
+			if len(split) == 2 {
+				result[split[0]] = split[1]
+			} else {
+				result[split[0]] = ""
+			}
+		}
+		return result
+	case string:
+		return keyValueMap([]interface{}{v})
+	}
+	return map[interface{}]interface{}{}
+}
+
+func appendUnique(a, b []interface{}) []interface{} {
+	result := append([]interface{}{}, a...)
+	for _, value := range b {
+		found := false
+		for _, existing := range result {
+			if reflect.DeepEqual(existing, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			result = append(result, value)
+		}
+	}
+	return result
+}
@@ -0,0 +1,104 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadConfigsMerge(t *testing.T) {
+	base := `namespace: myapp
+containers:
+  main:
+    image: quay.io/myapp:{{ .version.myapp }}
+    environment:
+      A: "1"
+      B: "2"
+    ports: "8080:80"
+    cmd: ["sleep", "100"]
+    labels:
+      team: core
+    memory: 1g
+  db:
+    image: mysql:5.6`
+
+	override := `containers:
+  main:
+    env:
+      - B=3
+    ports:
+      - "8080:80"
+      - "8443:443"
+    cmd: ["sleep", "1"]
+    memory: ~
+  cache:
+    image: redis:3`
+
+	prod := `namespace: myapp-prod
+containers:
+  main:
+    labels:
+      env: prod`
+
+	config, err := ReadConfigs(
+		[]string{"compose.yml", "compose.override.yml", "prod.yml"},
+		[]io.Reader{strings.NewReader(base), strings.NewReader(override), strings.NewReader(prod)},
+		configTestVars, map[string]interface{}{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "myapp-prod", config.Namespace)
+	assert.Equal(t, 3, len(config.Containers))
+
+	main := config.Containers["main"]
+	assert.Equal(t, "quay.io/myapp:1.9.2", *main.Image)
+	assert.Equal(t, StringMap{"A": "1", "B": "3"}, main.Env)
+	assert.Equal(t, StringMap{"team": "core", "env": "prod"}, main.Labels)
+	assert.Equal(t, Cmd{"sleep", "1"}, main.Cmd)
+	assert.Nil(t, main.Memory)
+
+	ports := []string{}
+	for _, port := range main.Ports {
+		ports = append(ports, port.HostPort+":"+port.Port)
+	}
+	assert.Equal(t, []string{"8080:80/tcp", "8443:443/tcp"}, ports)
+
+	assert.Equal(t, "redis:3", *config.Containers["cache"].Image)
+}
+
+func TestReadConfigsMergeDockerComposeFormat(t *testing.T) {
+	base := `main:
+  image: busybox:latest
+  dns: 8.8.8.8`
+
+	override := `main:
+  dns: 8.8.4.4`
+
+	config, err := ReadConfigs([]string{"-", "override.yml"},
+		[]io.Reader{strings.NewReader(base), strings.NewReader(override)},
+		configTestVars, map[string]interface{}{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, Strings{"8.8.8.8", "8.8.4.4"}, config.Containers["main"].DNS)
+}
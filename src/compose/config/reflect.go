@@ -27,6 +27,8 @@ var compareSkipFields = []string{
 	"Image",
 	"Extends",
 	"KillTimeout",
+	"StopGracePeriod",
+	"EnvFile",
 	"NetworkDisabled",
 	"State",
 	"KeepVolumes",
@@ -100,3 +102,42 @@ func getYamlFieldName(fieldName string) string {
 	split := strings.SplitN(yamlTag, ",", 2)
 	return split[0]
 }
+
+// getYamlFieldsOfType returns the list of yaml field names of the container spec
+// which have the same type as the given value, e.g. (*Memory)(nil)
+func getYamlFieldsOfType(value interface{}) []string {
+	var (
+		fields = []string{}
+		t      = reflect.TypeOf(value)
+	)
+
+	for _, fieldName := range getContainerFields() {
+		field, _ := reflect.TypeOf(Container{}).FieldByName(fieldName)
+		if field.Type != t {
+			continue
+		}
+		if name := getYamlFieldName(fieldName); name != "" && name != "-" {
+			fields = append(fields, name)
+		}
+	}
+
+	return fields
+}
+
+// getYamlFieldsOfKind returns the list of yaml field names of the container spec
+// which types are of the given kind, e.g. reflect.Slice
+func getYamlFieldsOfKind(kind reflect.Kind) []string {
+	fields := []string{}
+
+	for _, fieldName := range getContainerFields() {
+		field, _ := reflect.TypeOf(Container{}).FieldByName(fieldName)
+		if field.Type.Kind() != kind {
+			continue
+		}
+		if name := getYamlFieldName(fieldName); name != "" && name != "-" {
+			fields = append(fields, name)
+		}
+	}
+
+	return fields
+}
@@ -0,0 +1,299 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/grammarly/rocker/src/template"
+)
+
+// number of lines shown before and after the line an error refers to
+const errorContextLines = 3
+
+var (
+	yamlErrorLineRegexp     = regexp.MustCompile(`line (\d+):`)
+	templateErrorLineRegexp = regexp.MustCompile(`template: [^\n]*?:(\d+)(:\d+)?: `)
+)
+
+// renderedConfig keeps the manifest template together with its rendered output,
+// so errors that refer to rendered lines can be shown along with the source lines
+type renderedConfig struct {
+	name     string
+	source   []string
+	rendered []string
+
+	// source line number for every rendered line, 0 if it cannot be found out;
+	// it is only needed to show errors, so it is computed on the first use
+	mapping []int
+}
+
+// PrintRendered renders the manifest template and writes the result annotated
+// with line numbers, and with numbers of source template lines where they are known.
+func PrintRendered(w io.Writer, configName string, reader io.Reader, vars template.Vars, funcs map[string]interface{}) error {
+	source, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return fmt.Errorf("Failed to read config %s, error: %s", configName, err)
+	}
+
+	data, err := template.Process(configName, bytes.NewReader(source), vars, funcs)
+	if err != nil {
+		return templateError(configName, source, err)
+	}
+
+	r := newRenderedConfig(configName, source, data.Bytes())
+	return r.writeLines(w, 1, len(r.rendered), 0)
+}
+
+func newRenderedConfig(name string, source, rendered []byte) *renderedConfig {
+	return &renderedConfig{
+		name:     name,
+		source:   splitLines(source),
+		rendered: splitLines(rendered),
+	}
+}
+
+// sourceLine returns the source line number of the rendered line n (1-based), 0 if unknown
+func (r *renderedConfig) sourceLine(n int) int {
+	if r.mapping == nil {
+		r.mapping = mapRenderedLines(r.source, r.rendered)
+	}
+	return r.mapping[n-1]
+}
+
+// yamlError adds rendered lines around the line that the YAML parsing error refers to
+func (r *renderedConfig) yamlError(message string, err error) error {
+	match := yamlErrorLineRegexp.FindStringSubmatch(err.Error())
+	if match == nil {
+		return fmt.Errorf("%s, error: %s", message, err)
+	}
+	line, _ := strconv.Atoi(match[1])
+
+	// syntax errors are reported by the yaml parser with zero-based line numbers,
+	// while errors of unmarshalling values have line numbers starting from one
+	if !strings.HasPrefix(err.Error(), "yaml: unmarshal errors") {
+		line++
+	}
+	if line < 1 || line > len(r.rendered) {
+		return fmt.Errorf("%s, error: %s", message, err)
+	}
+
+	var (
+		buf      bytes.Buffer
+		from, to = contextRange(line, len(r.rendered))
+	)
+	if src := r.sourceLine(line); src > 0 {
+		fmt.Fprintf(&buf, "Rendered %s around line %d (source line %d), source line numbers are in brackets:\n",
+			r.name, line, src)
+	} else {
+		fmt.Fprintf(&buf, "Rendered %s around line %d, source line numbers are in brackets:\n", r.name, line)
+	}
+	r.writeLines(&buf, from, to, line)
+
+	return fmt.Errorf("%s, error: %s\n%s", message, err, strings.TrimRight(buf.String(), "\n"))
+}
+
+// writeLines writes rendered lines from..to (1-based, inclusive) with their numbers
+// and numbers of source lines; the 'mark' line is pointed with an arrow
+func (r *renderedConfig) writeLines(w io.Writer, from, to, mark int) error {
+	for n := from; n <= to; n++ {
+		src := "      "
+		if line := r.sourceLine(n); line > 0 {
+			src = fmt.Sprintf("[%4d]", line)
+		}
+		if _, err := fmt.Fprintf(w, "%s%4d %s | %s\n", marker(n == mark), n, src, r.rendered[n-1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// templateError adds source lines around the line that the template error refers to,
+// errors of the template engine already have source line numbers
+func templateError(name string, source []byte, err error) error {
+	match := templateErrorLineRegexp.FindStringSubmatch(err.Error())
+	if match == nil {
+		return fmt.Errorf("Failed to process config template, error: %s", err)
+	}
+	line, _ := strconv.Atoi(match[1])
+	lines := splitLines(source)
+	if line < 1 || line > len(lines) {
+		return fmt.Errorf("Failed to process config template, error: %s", err)
+	}
+
+	var (
+		buf      bytes.Buffer
+		from, to = contextRange(line, len(lines))
+	)
+	fmt.Fprintf(&buf, "Template %s around line %d:\n", name, line)
+	for n := from; n <= to; n++ {
+		fmt.Fprintf(&buf, "%s%4d | %s\n", marker(n == line), n, lines[n-1])
+	}
+
+	return fmt.Errorf("Failed to process config template, error: %s\n%s", err, strings.TrimRight(buf.String(), "\n"))
+}
+
+// mapRenderedLines finds out source lines of rendered lines. Lines without template
+// actions are copied to the output as is, so they are matched by the longest common
+// subsequence of source and rendered lines. Lines that are left are matched against
+// source lines with actions, where every action may render to anything; such a line is
+// mapped if there is a single matching source line between its mapped neighbours, or in
+// the whole template. Lines repeated by a range can be matched only once, others are left unmapped.
+func mapRenderedLines(source, rendered []string) []int {
+	var (
+		mapping  = make([]int, len(rendered))
+		literal  = make([]bool, len(source))
+		patterns = make([]*regexp.Regexp, len(source))
+	)
+
+	for i, line := range source {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if !strings.Contains(line, "{{") {
+			literal[i] = true
+			continue
+		}
+		patterns[i] = templateLineRegexp(line)
+	}
+
+	// lcs[i][j] is the length of the common subsequence of source[i:] and rendered[j:]
+	lcs := make([][]int, len(source)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(rendered)+1)
+	}
+	for i := len(source) - 1; i >= 0; i-- {
+		for j := len(rendered) - 1; j >= 0; j-- {
+			if literal[i] && source[i] == rendered[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	for i, j := 0, 0; i < len(source) && j < len(rendered); {
+		switch {
+		case literal[i] && source[i] == rendered[j]:
+			mapping[j] = i + 1
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+
+	for j, line := range rendered {
+		if mapping[j] > 0 || strings.TrimSpace(line) == "" {
+			continue
+		}
+		prev, next := 0, len(source)+1
+		for k := j - 1; k >= 0; k-- {
+			if mapping[k] > 0 {
+				prev = mapping[k]
+				break
+			}
+		}
+		for k := j + 1; k < len(rendered); k++ {
+			if mapping[k] > 0 {
+				next = mapping[k]
+				break
+			}
+		}
+
+		inGap, anywhere := []int{}, []int{}
+		for i, pattern := range patterns {
+			if pattern == nil || !pattern.MatchString(line) {
+				continue
+			}
+			if i+1 > prev && i+1 < next {
+				inGap = append(inGap, i+1)
+			}
+			anywhere = append(anywhere, i+1)
+		}
+		if len(inGap) == 1 {
+			mapping[j] = inGap[0]
+		} else if len(inGap) == 0 && len(anywhere) == 1 {
+			mapping[j] = anywhere[0]
+		}
+	}
+
+	return mapping
+}
+
+// templateLineRegexp makes a pattern of a source line with template actions, where
+// every action matches anything; lines that consist of actions only give no pattern
+func templateLineRegexp(line string) *regexp.Regexp {
+	var (
+		pattern = "^"
+		rest    = line
+		text    = false
+	)
+	for {
+		start := strings.Index(rest, "{{")
+		if start < 0 {
+			break
+		}
+		end := strings.Index(rest[start:], "}}")
+		if end < 0 {
+			break
+		}
+		if strings.TrimSpace(rest[:start]) != "" {
+			text = true
+		}
+		pattern += regexp.QuoteMeta(rest[:start]) + ".*"
+		rest = rest[start+end+2:]
+	}
+	if strings.TrimSpace(rest) != "" {
+		text = true
+	}
+	if !text {
+		return nil
+	}
+	return regexp.MustCompile(pattern + regexp.QuoteMeta(rest) + "$")
+}
+
+func contextRange(line, total int) (int, int) {
+	from, to := line-errorContextLines, line+errorContextLines
+	if from < 1 {
+		from = 1
+	}
+	if to > total {
+		to = total
+	}
+	return from, to
+}
+
+func splitLines(data []byte) []string {
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+func marker(mark bool) string {
+	if mark {
+		return "  > "
+	}
+	return "    "
+}
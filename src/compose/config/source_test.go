@@ -0,0 +1,107 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var sourceTestTemplate = `namespace: test
+containers:
+  {{ range $i := seq 2 }}
+  app{{ $i }}:
+    image: busybox:latest
+    cmd: echo {{ $i }}
+  {{ end }}
+  db:
+    image: busybox:latest
+    env: FOO: bar`
+
+func TestMapRenderedLines(t *testing.T) {
+	source := strings.Split(sourceTestTemplate, "\n")
+	rendered := []string{
+		"namespace: test",
+		"containers:",
+		"  ",
+		"  app1:",
+		"    image: busybox:latest",
+		"    cmd: echo 1",
+		"  ",
+		"  app2:",
+		"    image: busybox:latest",
+		"    cmd: echo 2",
+		"  ",
+		"  db:",
+		"    image: busybox:latest",
+		"    env: FOO: bar",
+	}
+
+	// the second "image" line of the range cannot be told apart from the first one
+	assert.Equal(t, []int{1, 2, 0, 4, 5, 6, 0, 4, 0, 6, 0, 8, 9, 10}, mapRenderedLines(source, rendered))
+}
+
+func TestReadConfigYamlErrorContext(t *testing.T) {
+	_, err := ReadConfig("test", strings.NewReader(sourceTestTemplate), configTestVars, map[string]interface{}{}, false)
+	assert.EqualError(t, err, strings.Join([]string{
+		"Failed to parse YAML config, error: yaml: line 13: mapping values are not allowed in this context",
+		"Rendered test around line 14 (source line 10), source line numbers are in brackets:",
+		"      11        |   ",
+		"      12 [   8] |   db:",
+		"      13 [   9] |     image: busybox:latest",
+		"  >   14 [  10] |     env: FOO: bar",
+	}, "\n"))
+}
+
+func TestReadConfigTemplateErrorContext(t *testing.T) {
+	configStr := strings.Replace(sourceTestTemplate, "seq 2", "sequence 2", 1)
+
+	_, err := ReadConfig("test", strings.NewReader(configStr), configTestVars, map[string]interface{}{}, false)
+	assert.EqualError(t, err, strings.Join([]string{
+		"Failed to process config template, error: Error parsing template test, error: template: test:3: function \"sequence\" not defined",
+		"Template test around line 3:",
+		"       1 | namespace: test",
+		"       2 | containers:",
+		"  >    3 |   {{ range $i := sequence 2 }}",
+		"       4 |   app{{ $i }}:",
+		"       5 |     image: busybox:latest",
+		"       6 |     cmd: echo {{ $i }}",
+	}, "\n"))
+}
+
+func TestPrintRendered(t *testing.T) {
+	configStr := `namespace: test
+containers:
+  main:
+    image: busybox:{{ .version.myapp }}`
+
+	var buf bytes.Buffer
+	if err := PrintRendered(&buf, "test", strings.NewReader(configStr), configTestVars, map[string]interface{}{}); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, strings.Join([]string{
+		"       1 [   1] | namespace: test",
+		"       2 [   2] | containers:",
+		"       3 [   3] |   main:",
+		"       4 [   4] |     image: busybox:1.9.2",
+		"",
+	}, "\n"), buf.String())
+}
@@ -0,0 +1,347 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	memoryRegexp  = regexp.MustCompile("^(?i)[0-9]+[bkmg]?$")
+	restartRegexp = regexp.MustCompile("^(no|always|on-failure(,[0-9]+)?)$")
+)
+
+// ValidationError is a problem of a particular property of a container spec
+type ValidationError struct {
+	Container string
+	Field     string
+	Message   string
+}
+
+// ValidationErrors is a list of problems found by Validate, it is an error itself
+type ValidationErrors []*ValidationError
+
+// Error returns the string representation of the validation error
+func (e *ValidationError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("container `%s`: %s", e.Container, e.Message)
+	}
+	return fmt.Sprintf("container `%s`, field `%s`: %s", e.Container, e.Field, e.Message)
+}
+
+// Error returns all validation errors, one per line
+func (errs ValidationErrors) Error() string {
+	lines := make([]string, len(errs))
+	for i, e := range errs {
+		lines[i] = e.Error()
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (errs ValidationErrors) Len() int      { return len(errs) }
+func (errs ValidationErrors) Swap(i, j int) { errs[i], errs[j] = errs[j], errs[i] }
+func (errs ValidationErrors) Less(i, j int) bool {
+	if errs[i].Container != errs[j].Container {
+		return errs[i].Container < errs[j].Container
+	}
+	if errs[i].Field != errs[j].Field {
+		return errs[i].Field < errs[j].Field
+	}
+	return errs[i].Message < errs[j].Message
+}
+
+// Validate makes checks of the manifest that ReadConfig does not do, since they are not
+// fatal for parsing: memory values with unknown units, unknown restart policies, invalid devices
+// and device rates, conflicting cpu limits, host ports bound by more than one container and
+// references to containers that are missing in the manifest.
+// Returns nil if the config is valid, the list of errors sorted by container names otherwise.
+func (config *Config) Validate() ValidationErrors {
+	errs := ValidationErrors{}
+
+	add := func(container, field, format string, args ...interface{}) {
+		errs = append(errs, &ValidationError{
+			Container: container,
+			Field:     field,
+			Message:   fmt.Sprintf(format, args...),
+		})
+	}
+
+	memoryFields := getYamlFieldsOfType((*Memory)(nil))
+
+	for name, raw := range config.raw {
+		for _, field := range memoryFields {
+			value, ok := raw[field]
+			if !ok {
+				continue
+			}
+			if str, ok := value.(string); ok && !memoryRegexp.MatchString(str) {
+				add(name, field, "invalid memory value `%s`, expected a number with an optional unit b, k, m or g", str)
+			}
+		}
+
+		if value, ok := raw["restart"]; ok && value != nil {
+			// unquoted `no` is parsed by yaml as boolean false
+			if str := fmt.Sprintf("%v", value); str != "false" && !restartRegexp.MatchString(str) {
+				add(name, "restart", "unknown restart policy `%s`, expected no, always or on-failure[,N]", str)
+			}
+		}
+	}
+
+	for name, container := range config.Containers {
+		// containers prefixed with underscore are only used to extend from
+		if strings.HasPrefix(name, "_") {
+			continue
+		}
+
+		check := func(field string, ref ContainerName) {
+			if ref.Namespace != config.Namespace {
+				return
+			}
+			if c, ok := config.Containers[ref.Name]; !ok || c == nil || strings.HasPrefix(ref.Name, "_") {
+				add(name, field, "container `%s` is not found in the manifest", ref.Name)
+			}
+		}
+
+		for _, ref := range container.VolumesFrom {
+			check("volumes_from", ref)
+		}
+		for _, link := range container.Links {
+			check("links", link.ContainerName)
+		}
+		for _, ref := range container.WaitFor {
+			check("wait_for", ref)
+		}
+		if container.Net != nil && container.Net.Type == "container" {
+			check("net", container.Net.Container)
+		}
+		if container.Ipc != nil && container.Ipc.Type == "container" {
+			check("ipc", container.Ipc.Container)
+		}
+
+		for _, device := range container.Devices {
+			if _, err := ParseDevice(device); err != nil {
+				add(name, "devices", "%s", err)
+			}
+		}
+		for _, tmpfs := range container.Tmpfs {
+			if _, _, err := ParseTmpfs(tmpfs); err != nil {
+				add(name, "tmpfs", "%s", err)
+			}
+		}
+		for _, rate := range container.DeviceReadBps {
+			if _, err := ParseDeviceRate(rate); err != nil {
+				add(name, "device_read_bps", "%s", err)
+			}
+		}
+		for _, rate := range container.DeviceWriteBps {
+			if _, err := ParseDeviceRate(rate); err != nil {
+				add(name, "device_write_bps", "%s", err)
+			}
+		}
+		if container.CPUs != nil && container.CPUQuota != nil {
+			add(name, "cpus", "cpus and cpu_quota cannot be given together, cpus is a shortcut for cpu_quota")
+		}
+	}
+
+	for _, collision := range config.portCollisions() {
+		add(collision.container, "ports", "host port %s is already bound by container `%s`",
+			collision.port, collision.other)
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	sort.Sort(errs)
+	return errs
+}
+
+// CheckUnknownKeys returns an error listing container properties that are not known
+// by rocker-compose, with a suggestion of the closest known property for each of them.
+// It is used in strict mode, which is turned on by the `strict: true` manifest property
+// or the --strict flag. Properties nested under `extra` and ones prefixed with "x-" are allowed.
+func (config *Config) CheckUnknownKeys() error {
+	var (
+		errs       = ValidationErrors{}
+		yamlFields = getYamlFields()
+		known      = map[string]bool{}
+	)
+
+	for _, field := range yamlFields {
+		known[field] = true
+	}
+
+	for name, raw := range config.raw {
+		for key := range raw {
+			if known[key] || strings.HasPrefix(key, "x-") {
+				continue
+			}
+			message := "unknown property"
+			if closest := closestString(key, yamlFields); closest != "" {
+				message = fmt.Sprintf("unknown property, did you mean `%s`?", closest)
+			}
+			errs = append(errs, &ValidationError{Container: name, Field: key, Message: message})
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	sort.Sort(errs)
+	return errs
+}
+
+// closestString returns the candidate with the smallest edit distance to str,
+// or an empty string if none of them is close enough to be a typo
+func closestString(str string, candidates []string) string {
+	var (
+		closest string
+		best    = len(str)/2 + 1
+	)
+	for _, candidate := range candidates {
+		if d := levenshtein(str, candidate); d <= best {
+			closest, best = candidate, d
+		}
+	}
+	return closest
+}
+
+// levenshtein returns the number of single character edits needed to turn a into b
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = minInt(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+func minInt(values ...int) int {
+	min := values[0]
+	for _, v := range values[1:] {
+		if v < min {
+			min = v
+		}
+	}
+	return min
+}
+
+type portCollision struct {
+	container string
+	other     string
+	port      string
+}
+
+type hostPort struct {
+	container string
+	ip        string
+	port      int
+	proto     string
+}
+
+// portCollisions finds host ports that are bound by more than one container
+// of the manifest; an empty or 0.0.0.0 host ip collides with any other ip
+func (config *Config) portCollisions() []portCollision {
+	var (
+		names      = []string{}
+		bound      = []hostPort{}
+		collisions = []portCollision{}
+	)
+
+	for name := range config.Containers {
+		if !strings.HasPrefix(name, "_") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, binding := range config.Containers[name].Ports {
+			if binding.HostPort == "" {
+				continue
+			}
+
+			proto := "tcp"
+			if split := strings.SplitN(binding.Port, "/", 2); len(split) == 2 {
+				proto = split[1]
+			}
+
+			first, last, err := parsePortRange(binding.HostPort)
+			if err != nil {
+				continue
+			}
+
+			for port := first; port <= last; port++ {
+				hp := hostPort{container: name, ip: binding.HostIP, port: port, proto: proto}
+				for _, other := range bound {
+					if other.container != name && hp.collides(other) {
+						collisions = append(collisions, portCollision{
+							container: name,
+							other:     other.container,
+							port:      fmt.Sprintf("%d/%s", port, proto),
+						})
+						break
+					}
+				}
+				bound = append(bound, hp)
+			}
+		}
+	}
+
+	return collisions
+}
+
+func (a hostPort) collides(b hostPort) bool {
+	if a.port != b.port || a.proto != b.proto {
+		return false
+	}
+	anyIP := func(ip string) bool { return ip == "" || ip == "0.0.0.0" }
+	return anyIP(a.ip) || anyIP(b.ip) || a.ip == b.ip
+}
+
+// parsePortRange parses "8080" or "8080-8090" host port specification
+func parsePortRange(str string) (int, int, error) {
+	split := strings.SplitN(str, "-", 2)
+	first, err := strconv.Atoi(split[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(split) == 1 {
+		return first, first, nil
+	}
+	last, err := strconv.Atoi(split[1])
+	if err != nil {
+		return 0, 0, err
+	}
+	return first, last, nil
+}
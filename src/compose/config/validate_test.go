@@ -0,0 +1,136 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigValidate(t *testing.T) {
+	configStr := `namespace: test
+containers:
+  _base:
+    image: busybox:latest
+    ports: "8080:80"
+  main:
+    extends: _base
+    memory: 100x
+    memory_swap: 1G
+    restart: sometimes
+    links: db
+    cpus: 0.5
+    cpu_quota: 50000
+    kernel_memory: 10mb
+    device_write_bps: /dev/sda:1z
+  db:
+    image: busybox:latest
+    restart: no
+    ports:
+      - "0.0.0.0:8080:8080"
+      - "127.0.0.1:9000-9001:9000"
+    volumes_from: data
+    net: container:other.net
+  worker:
+    image: busybox:latest
+    restart: on-failure,5
+    devices: /dev/fuse:/dev/fuse:rwx
+    ipc: container:cache
+    tmpfs: run:size=64m
+    ports:
+      - "127.0.0.2:9001:9001"
+      - "9001:9001/udp"`
+
+	config, err := ReadConfig("test", strings.NewReader(configStr), configTestVars, map[string]interface{}{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, strings.Join([]string{
+		"container `db`, field `volumes_from`: container `data` is not found in the manifest",
+		"container `main`, field `cpus`: cpus and cpu_quota cannot be given together, cpus is a shortcut for cpu_quota",
+		"container `main`, field `device_write_bps`: invalid device rate `/dev/sda:1z`, expected path:rate with an optional unit b, k, m or g, e.g. /dev/sda:10m",
+		"container `main`, field `kernel_memory`: invalid memory value `10mb`, expected a number with an optional unit b, k, m or g",
+		"container `main`, field `memory`: invalid memory value `100x`, expected a number with an optional unit b, k, m or g",
+		"container `main`, field `ports`: host port 8080/tcp is already bound by container `db`",
+		"container `main`, field `restart`: unknown restart policy `sometimes`, expected no, always or on-failure[,N]",
+		"container `worker`, field `devices`: invalid permissions `rwx` of device `/dev/fuse:/dev/fuse:rwx`, expected a combination of r, w and m",
+		"container `worker`, field `ipc`: container `cache` is not found in the manifest",
+		"container `worker`, field `tmpfs`: invalid tmpfs `run:size=64m`, expected an absolute path[:options]",
+	}, "\n"), config.Validate().Error())
+}
+
+func TestConfigValidateValid(t *testing.T) {
+	configStr := `namespace: test
+containers:
+  main:
+    image: busybox:latest
+    memory: 64m
+    restart: always
+    ports: "127.0.0.1:8080:80"
+    links: db
+  db:
+    image: busybox:latest
+    memory: 1024
+    ports: "127.0.0.2:8080:80"`
+
+	config, err := ReadConfig("test", strings.NewReader(configStr), configTestVars, map[string]interface{}{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Nil(t, config.Validate())
+}
+
+func TestConfigStrict(t *testing.T) {
+	configStr := `namespace: test
+strict: true
+containers:
+  main:
+    image: busybox:latest
+    enviroment:
+      FOO: bar
+    volume: /data
+    x-owner: me
+    extra:
+      owner: me`
+
+	_, err := ReadConfig("test", strings.NewReader(configStr), configTestVars, map[string]interface{}{}, false)
+	assert.EqualError(t, err, strings.Join([]string{
+		"Unknown properties in test (strict mode):",
+		"container `main`, field `enviroment`: unknown property, did you mean `environment`?",
+		"container `main`, field `volume`: unknown property, did you mean `volumes`?",
+	}, "\n"))
+
+	// without strict mode unknown properties are kept in extra
+	config, err := ReadConfig("test", strings.NewReader(strings.Replace(configStr, "strict: true", "", 1)),
+		configTestVars, map[string]interface{}{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "/data", config.Containers["main"].Extra["volume"])
+	assert.Equal(t, 2, len(config.CheckUnknownKeys().(ValidationErrors)))
+}
+
+func TestClosestString(t *testing.T) {
+	fields := getYamlFields()
+	assert.Equal(t, "environment", closestString("enviroment", fields))
+	assert.Equal(t, "volumes_from", closestString("volume_from", fields))
+	assert.Equal(t, "", closestString("foobarbaz", fields))
+}
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // UnmarshalYAML unserialize Config object form YAML
@@ -32,9 +33,11 @@ func (config *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	// we think it is docker-compose format
 	c := &struct {
 		Namespace  *string
+		Strict     *bool
 		Containers *map[string]*Container
 	}{
 		&config.Namespace,
+		&config.Strict,
 		&config.Containers,
 	}
 	if err := unmarshal(c); err != nil {
@@ -49,6 +52,37 @@ func (config *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	return nil
 }
 
+// UnmarshalYAML unserialize Extends object from YAML
+// It is either a container name or a map of 'file' and 'container'
+func (e *Extends) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var name string
+	if err := unmarshal(&name); err == nil {
+		e.Container = name
+		return nil
+	}
+	value := struct {
+		File      string `yaml:"file"`
+		Container string `yaml:"container"`
+	}{}
+	if err := unmarshal(&value); err != nil {
+		return err
+	}
+	if value.Container == "" {
+		return fmt.Errorf("extends: container name should be specified")
+	}
+	e.File = value.File
+	e.Container = value.Container
+	return nil
+}
+
+// MarshalYAML serialize Extends object to YAML
+func (e Extends) MarshalYAML() (interface{}, error) {
+	if e.File == "" {
+		return e.Container, nil
+	}
+	return map[string]string{"file": e.File, "container": e.Container}, nil
+}
+
 // UnmarshalYAML unserialize ContainerName object from YAML
 func (n *ContainerName) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	var name string
@@ -94,6 +128,29 @@ func (m *Memory) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	return nil
 }
 
+// UnmarshalYAML unserialize Duration object from YAML
+func (d *Duration) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var str string
+	if err := unmarshal(&str); err != nil {
+		return err
+	}
+	if seconds, err := strconv.ParseUint(str, 10, 64); err == nil {
+		*d = Duration(time.Duration(seconds) * time.Second)
+		return nil
+	}
+	value, err := time.ParseDuration(str)
+	if err != nil {
+		return fmt.Errorf("Invalid duration `%s`, expected a number of seconds or a value like 1m30s", str)
+	}
+	*d = Duration(value)
+	return nil
+}
+
+// MarshalYAML serialize Duration object to YAML
+func (d Duration) MarshalYAML() (interface{}, error) {
+	return d.String(), nil
+}
+
 // UnmarshalYAML unserialize RestartPolicy object from YAML
 func (r *RestartPolicy) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	var name string
@@ -196,6 +253,25 @@ func (n *Net) MarshalYAML() (interface{}, error) {
 	return n.String(), nil
 }
 
+// UnmarshalYAML unserialize Ipc object from YAML
+func (ipc *Ipc) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var str string
+	if err := unmarshal(&str); err != nil {
+		return err
+	}
+	value, err := NewIpcFromString(str)
+	if err != nil {
+		return err
+	}
+	*ipc = *value
+	return nil
+}
+
+// MarshalYAML serialize Ipc object to YAML
+func (ipc *Ipc) MarshalYAML() (interface{}, error) {
+	return ipc.String(), nil
+}
+
 // UnmarshalYAML unserialize slice of ContainerName objects from YAML
 // Either single value or array can be given. Single 'value' casts to array{'value'}
 func (v *ContainerNames) UnmarshalYAML(unmarshal func(interface{}) error) error {
@@ -177,6 +177,25 @@ func TestYamlNet(t *testing.T) {
 	}
 }
 
+func TestYamlIpc(t *testing.T) {
+	test := &yamlTestCases{
+		map[string]string{
+			"ipc: none":          "ipc: none",
+			"ipc: host":          "ipc: host",
+			"ipc: shareable":     "ipc: shareable",
+			"ipc: container:db":  "ipc: container:db",
+			"ipc: container:a.b": "ipc: container:a.b",
+		},
+	}
+	if err := test.run(t); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Container{}
+	assert.Error(t, yaml.Unmarshal([]byte("ipc: bridge"), c))
+	assert.Error(t, yaml.Unmarshal([]byte("ipc: container"), c))
+}
+
 func TestYamlVolumesFrom(t *testing.T) {
 	test := &yamlTestCases{
 		map[string]string{
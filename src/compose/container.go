@@ -17,16 +17,18 @@
 package compose
 
 import (
+	"fmt"
 	"github.com/grammarly/rocker-compose/src/compose/config"
 	"github.com/grammarly/rocker-compose/src/util"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/go-yaml/yaml"
 	"github.com/grammarly/rocker/src/imagename"
 
-	log "github.com/Sirupsen/logrus"
 	"github.com/fsouza/go-dockerclient"
+	log "github.com/sirupsen/logrus"
 )
 
 // Container object represents a single container produced by a rocker-compose spec
@@ -42,6 +44,10 @@ type Container struct {
 	Io            *ContainerIo
 
 	container *docker.Container
+
+	// unmanaged containers were created by other tools, see GetUnmanagedContainers
+	unmanaged   bool
+	imageConfig *docker.Config
 }
 
 // ContainerState represents the state of a container.
@@ -143,6 +149,14 @@ func (a *Container) IsEqualTo(b *Container) bool {
 		return false
 	}
 
+	// containers created by other tools are recreated unless they are adopted
+	if a.unmanaged || b.unmanaged {
+		log.Debugf("Comparing '%s' and '%s': container is not managed by rocker-compose",
+			a.Name.String(),
+			b.Name.String())
+		return false
+	}
+
 	// check configuration
 	if !a.Config.IsEqualTo(b.Config) {
 		log.Debugf("Comparing '%s' and '%s': found difference in '%s'",
@@ -196,11 +210,103 @@ func (a *Container) IsEqualTo(b *Container) bool {
 	return true
 }
 
+// Diff compares the container against the given actual one by the same dimensions
+// as IsEqualTo does: management, configuration, image version, image id and state. Unlike IsEqualTo,
+// it collects all differences found instead of stopping at the first one.
+func (a *Container) Diff(b *Container) []config.FieldDiff {
+	bConfig := b.Config
+	if bConfig == nil {
+		bConfig = &config.Container{}
+	}
+
+	diffs := []config.FieldDiff{}
+
+	// containers created by other tools are recreated unless they are adopted
+	if a.unmanaged || b.unmanaged {
+		diffs = append(diffs, config.FieldDiff{
+			Field: "managed_by",
+			Old:   "other tool",
+			New:   "rocker-compose",
+		})
+	}
+
+	// check configuration
+	diffs = append(diffs, a.Config.Diff(bConfig)...)
+
+	// check image version
+	if a.Image != nil && !a.Image.Contains(b.Image) {
+		diffs = append(diffs, config.FieldDiff{
+			Field: "image",
+			Old:   b.Image.String(),
+			New:   a.Image.String(),
+		})
+	}
+
+	// check image id
+	if a.ImageID != "" && b.ImageID != "" && a.ImageID != b.ImageID {
+		diffs = append(diffs, config.FieldDiff{
+			Field: "image_id",
+			Old:   fmt.Sprintf("%.12s", b.ImageID),
+			New:   fmt.Sprintf("%.12s", a.ImageID),
+		})
+	}
+
+	// container should run once, but previous exit code was not zero
+	if a.Config.State.IsRan() && a.State.ExitCode+b.State.ExitCode > 0 {
+		diffs = append(diffs, config.FieldDiff{
+			Field: "exit_code",
+			Old:   strconv.Itoa(a.State.ExitCode + b.State.ExitCode),
+			New:   "0",
+		})
+	}
+
+	// check state
+	if !a.State.IsEqualState(b.State) {
+		diffs = append(diffs, config.FieldDiff{
+			Field: "state",
+			Old:   b.State.String(),
+			New:   a.State.String(),
+		})
+	}
+
+	return diffs
+}
+
 // IsEqualState returns true if current and given containers have the same state
 func (a *ContainerState) IsEqualState(b *ContainerState) bool {
 	return a.Running == b.Running
 }
 
+// String returns "running" or "not running" depending on the state
+func (a *ContainerState) String() string {
+	if a.Running {
+		return "running"
+	}
+	return "not running"
+}
+
+// Status returns the detailed state as docker names it:
+// running | paused | restarting | exited | created
+func (a *ContainerState) Status() string {
+	switch {
+	case a.Restarting:
+		return "restarting"
+	case a.Paused:
+		return "paused"
+	case a.Running:
+		return "running"
+	case !a.FinishedAt.IsZero():
+		return "exited"
+	}
+	return "created"
+}
+
+// isTty returns true if the container has a pseudo-TTY, docker does not
+// multiplex stdout and stderr of such containers, so they are read raw
+func (a *Container) isTty() bool {
+	return a.Config != nil && a.Config.Tty != nil && *a.Config.Tty
+}
+
 // CreateContainerOptions returns create configuration eatable by go-dockerclient
 func (a *Container) CreateContainerOptions() (*docker.CreateContainerOptions, error) {
 	apiConfig := a.Config.GetAPIConfig()
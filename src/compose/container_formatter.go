@@ -17,7 +17,7 @@
 package compose
 
 import (
-	log "github.com/Sirupsen/logrus"
+	log "github.com/sirupsen/logrus"
 )
 
 type formatter struct {
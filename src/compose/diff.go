@@ -17,7 +17,11 @@
 package compose
 
 import (
+	"bytes"
 	"fmt"
+	"sort"
+	"strings"
+
 	"github.com/grammarly/rocker-compose/src/compose/config"
 )
 
@@ -42,8 +46,18 @@ type dependency struct {
 	container *Container
 	external  bool
 	waitForIt bool
+	kinds     []string
 }
 
+// Kinds of dependencies between containers
+const (
+	DependencyVolumesFrom = "volumes_from"
+	DependencyWaitFor     = "wait_for"
+	DependencyLinks       = "links"
+	DependencyNet         = "net"
+	DependencyIpc         = "ipc"
+)
+
 // NewDiff returns an implementation of Diff object
 func NewDiff(ns string) Diff {
 	return &graph{
@@ -64,8 +78,8 @@ func (g *graph) Diff(expected []*Container, actual []*Container) (res []Action,
 	}
 
 	//check for cycles in configuration
-	if g.hasCycles() {
-		err = fmt.Errorf("Dependencies have cycles, check links and volumes-from")
+	if cycles := g.findCycles(); len(cycles) > 0 {
+		err = ErrDependencyCycles{cycles}
 		return
 	}
 
@@ -86,42 +100,65 @@ func (g *graph) buildDependencyGraph(expected []*Container, actual []*Container)
 	return nil
 }
 
-func resolveDependencies(ns string, expected []*Container, actual []*Container, target *Container) (resolved []*dependency, err error) {
-	resolved = []*dependency{}
-	toResolve := map[config.ContainerName]*dependency{}
+// dependencyRef is a container that another one refers to, together with the kind of the reference
+type dependencyRef struct {
+	name config.ContainerName
+	kind string
+}
+
+// dependencyRefs returns all containers the given one depends on
+// through volumes_from, wait_for, links, net and ipc
+func dependencyRefs(target *Container) []dependencyRef {
+	refs := []dependencyRef{}
 
 	//VolumesFrom
 	for _, cn := range target.Config.VolumesFrom {
-		if _, found := toResolve[cn]; !found {
-			toResolve[cn] = &dependency{external: cn.Namespace != ns}
-		}
+		refs = append(refs, dependencyRef{cn, DependencyVolumesFrom})
 	}
 
 	//WaitFor
 	for _, cn := range target.Config.WaitFor {
-		if d, found := toResolve[cn]; !found {
-			toResolve[cn] = &dependency{
-				waitForIt: true,
-				external:  cn.Namespace != ns,
-			}
-		} else {
-			d.waitForIt = true
-		}
+		refs = append(refs, dependencyRef{cn, DependencyWaitFor})
 	}
 
 	//Links
 	for _, link := range target.Config.Links {
-		cn := link.ContainerName
-		if _, found := toResolve[cn]; !found {
-			toResolve[cn] = &dependency{external: cn.Namespace != ns}
-		}
+		refs = append(refs, dependencyRef{link.ContainerName, DependencyLinks})
 	}
 
 	//Net
 	if target.Config.Net != nil && target.Config.Net.Type == "container" {
-		cn := target.Config.Net.Container
-		if _, found := toResolve[cn]; !found {
-			toResolve[cn] = &dependency{external: cn.Namespace != ns}
+		refs = append(refs, dependencyRef{target.Config.Net.Container, DependencyNet})
+	}
+
+	//Ipc
+	if target.Config.Ipc != nil && target.Config.Ipc.Type == "container" {
+		refs = append(refs, dependencyRef{target.Config.Ipc.Container, DependencyIpc})
+	}
+
+	return refs
+}
+
+func resolveDependencies(ns string, expected []*Container, actual []*Container, target *Container) (resolved []*dependency, err error) {
+	resolved = []*dependency{}
+	toResolve := map[config.ContainerName]*dependency{}
+
+	add := func(cn config.ContainerName, kind string) *dependency {
+		d, found := toResolve[cn]
+		if !found {
+			d = &dependency{external: cn.Namespace != ns}
+			toResolve[cn] = d
+		}
+		if !containsString(d.kinds, kind) {
+			d.kinds = append(d.kinds, kind)
+		}
+		return d
+	}
+
+	for _, ref := range dependencyRefs(target) {
+		d := add(ref.name, ref.kind)
+		if ref.kind == DependencyWaitFor {
+			d.waitForIt = true
 		}
 	}
 
@@ -255,6 +292,56 @@ func (g *graph) buildExecutionPlan(actual []*Container) (res []Action) {
 	return
 }
 
+// dependencyOrder groups 'expected' containers into steps, so containers of every step
+// depend only on containers of previous steps. Containers within a step are sorted by name.
+func dependencyOrder(ns string, expected []*Container, actual []*Container) ([][]*Container, error) {
+	g := NewDiff(ns).(*graph)
+
+	if err := g.buildDependencyGraph(expected, actual); err != nil {
+		return nil, err
+	}
+	if cycles := g.findCycles(); len(cycles) > 0 {
+		return nil, ErrDependencyCycles{cycles}
+	}
+
+	var (
+		steps   = [][]*Container{}
+		visited = map[*Container]bool{}
+	)
+
+	for len(visited) < len(g.dependencies) {
+		step := []*Container{}
+
+	nextContainer:
+		for container, deps := range g.dependencies {
+			if visited[container] {
+				continue
+			}
+			for _, dep := range deps {
+				if !dep.external && !visited[dep.container] {
+					continue nextContainer
+				}
+			}
+			step = append(step, container)
+		}
+
+		sort.Sort(containersByName(step))
+
+		for _, container := range step {
+			visited[container] = true
+		}
+		steps = append(steps, step)
+	}
+
+	return steps, nil
+}
+
+type containersByName []*Container
+
+func (c containersByName) Len() int           { return len(c) }
+func (c containersByName) Less(i, j int) bool { return c[i].Name.String() < c[j].Name.String() }
+func (c containersByName) Swap(i, j int)      { c[i], c[j] = c[j], c[i] }
+
 func find(containers []*Container, name *config.ContainerName) *Container {
 	for _, c := range containers {
 		if c.Name.IsEqualTo(name) {
@@ -264,27 +351,167 @@ func find(containers []*Container, name *config.ContainerName) *Container {
 	return nil
 }
 
-func (g *graph) hasCycles() bool {
-	for k := range g.dependencies {
-		if g.hasCycles0([]*Container{k}, k) {
-			return true
+// findCycles returns a cycle of every strongly connected component of the graph with kinds
+// of dependencies between containers, e.g. "ns.a -links-> ns.b -volumes_from-> ns.a".
+// Components are found by Tarjan's algorithm. A component may have many cycles, only the
+// shortest one through its container with the least name is reported.
+func (g *graph) findCycles() []string {
+	nodes := []*Container{}
+	for c := range g.dependencies {
+		nodes = append(nodes, c)
+	}
+	sort.Sort(containersByName(nodes))
+
+	var (
+		index   = map[*Container]int{}
+		lowlink = map[*Container]int{}
+		onStack = map[*Container]bool{}
+		stack   = []*Container{}
+		cycles  = []string{}
+		connect func(curr *Container)
+	)
+
+	connect = func(curr *Container) {
+		index[curr] = len(index)
+		lowlink[curr] = index[curr]
+		stack = append(stack, curr)
+		onStack[curr] = true
+
+		for _, d := range g.dependencies[curr] {
+			// containers of other namespaces cannot make cycles
+			if _, ok := g.dependencies[d.container]; !ok {
+				continue
+			}
+			if _, visited := index[d.container]; !visited {
+				connect(d.container)
+				if lowlink[d.container] < lowlink[curr] {
+					lowlink[curr] = lowlink[d.container]
+				}
+			} else if onStack[d.container] && index[d.container] < lowlink[curr] {
+				lowlink[curr] = index[d.container]
+			}
+		}
+
+		if lowlink[curr] != index[curr] {
+			return
+		}
+
+		// curr is the root of a component, which is on the stack above it
+		component := map[*Container]bool{}
+		for {
+			c := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[c] = false
+			component[c] = true
+			if c == curr {
+				break
+			}
+		}
+		if cycle := g.componentCycle(component); cycle != "" {
+			cycles = append(cycles, cycle)
 		}
 	}
-	return false
-}
 
-func (g *graph) hasCycles0(path []*Container, curr *Container) bool {
-	for _, c := range path[:len(path)-1] {
-		if c.IsSameKind(curr) {
-			return true
+	for _, c := range nodes {
+		if _, visited := index[c]; !visited {
+			connect(c)
 		}
 	}
-	if deps := g.dependencies[curr]; deps != nil {
+
+	sort.Strings(cycles)
+	return cycles
+}
+
+// componentCycle returns the shortest cycle through the container with the least name
+// of a strongly connected component, or "" if the component is a single container
+// that does not depend on itself
+func (g *graph) componentCycle(component map[*Container]bool) string {
+	members := []*Container{}
+	for c := range component {
+		members = append(members, c)
+	}
+	sort.Sort(containersByName(members))
+	start := members[0]
+
+	// breadth-first search of the way back to the start container; 'via' keeps
+	// dependencies the containers were reached by, 'from' keeps where they came from
+	var (
+		via   = map[*Container]*dependency{}
+		from  = map[*Container]*Container{}
+		queue = []*Container{start}
+	)
+
+	for len(queue) > 0 {
+		curr := queue[0]
+		queue = queue[1:]
+
+		deps := make([]*dependency, len(g.dependencies[curr]))
+		copy(deps, g.dependencies[curr])
+		sort.Sort(dependenciesByName(deps))
+
 		for _, d := range deps {
-			if g.hasCycles0(append(path, d.container), d.container) {
-				return true
+			if !component[d.container] {
+				continue
+			}
+			if d.container == start {
+				path := []*dependency{d}
+				for c := curr; c != start; c = from[c] {
+					path = append([]*dependency{via[c]}, path...)
+				}
+				return formatCycle(start, path)
 			}
+			if _, seen := via[d.container]; seen {
+				continue
+			}
+			via[d.container] = d
+			from[d.container] = curr
+			queue = append(queue, d.container)
 		}
 	}
-	return false
+
+	return ""
+}
+
+// formatCycle makes a printable cycle path like "a -links-> b -wait_for-> a"
+func formatCycle(start *Container, path []*dependency) string {
+	var buf bytes.Buffer
+	buf.WriteString(start.Name.String())
+	for _, d := range path {
+		buf.WriteString(fmt.Sprintf(" -%s-> %s", strings.Join(d.kinds, ","), d.container.Name))
+	}
+	return buf.String()
+}
+
+// ErrDependencyCycles is an error that lists all cycles found in dependencies of containers
+type ErrDependencyCycles struct {
+	Cycles []string
+}
+
+// Error returns string representation of the error
+func (e ErrDependencyCycles) Error() string {
+	return fmt.Sprintf("Dependencies have cycles, check links, volumes_from, wait_for, net and ipc:\n  %s",
+		strings.Join(e.Cycles, "\n  "))
+}
+
+// CheckDependencyCycles looks for cycles in dependencies of given containers. It does not need
+// a docker connection, since dependencies on containers of other namespaces cannot make cycles.
+func CheckDependencyCycles(ns string, containers []*Container) error {
+	g := NewDiff(ns).(*graph)
+
+	if err := g.buildDependencyGraph(containers, externalContainers(ns, containers)); err != nil {
+		return err
+	}
+	if cycles := g.findCycles(); len(cycles) > 0 {
+		return ErrDependencyCycles{cycles}
+	}
+
+	return nil
+}
+
+type dependenciesByName []*dependency
+
+func (d dependenciesByName) Len() int      { return len(d) }
+func (d dependenciesByName) Swap(i, j int) { d[i], d[j] = d[j], d[i] }
+func (d dependenciesByName) Less(i, j int) bool {
+	return d[i].container.Name.String() < d[j].container.Name.String()
 }
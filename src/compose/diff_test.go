@@ -248,6 +248,38 @@ func TestDiffInDependentNet(t *testing.T) {
 	mock.AssertExpectations(t)
 }
 
+func TestDiffInDependentIpc(t *testing.T) {
+	cmp := NewDiff("test")
+	c2IpcName, err := config.NewIpcFromString("container:test.2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c1 := &Container{
+		State:  &ContainerState{Running: true},
+		Name:   &config.ContainerName{Namespace: "test", Name: "1"},
+		Config: &config.Container{Ipc: c2IpcName},
+	}
+	c2 := &Container{
+		State:  &ContainerState{Running: true},
+		Name:   &config.ContainerName{Namespace: "test", Name: "2"},
+		Config: &config.Container{},
+	}
+	c2x := &Container{
+		State:  &ContainerState{Running: true},
+		Name:   &config.ContainerName{Namespace: "test", Name: "2"},
+		Config: &config.Container{Labels: map[string]string{"test": "test2"}},
+	}
+	actions, _ := cmp.Diff([]*Container{c1, c2x}, []*Container{c1, c2})
+	mock := clientMock{}
+	mock.On("RemoveContainer", c2).Return(nil)
+	mock.On("RunContainer", c2x).Return(nil)
+	mock.On("RemoveContainer", c1).Return(nil)
+	mock.On("RunContainer", c1).Return(nil)
+	runner := NewDockerClientRunner(&mock)
+	runner.Run(actions)
+	mock.AssertExpectations(t)
+}
+
 func TestDiffInDependentExternalNet(t *testing.T) {
 	cmp := NewDiff("test")
 	c2NetName, err := config.NewNetFromString("container:external.2")
@@ -279,6 +311,64 @@ func TestDiffForCycles(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestDiffCyclePaths(t *testing.T) {
+	a := newContainer("test", "a")
+	a.Config.Links = []config.Link{{ContainerName: config.ContainerName{Namespace: "test", Name: "b"}, Alias: "b"}}
+	b := newContainer("test", "b", config.ContainerName{Namespace: "test", Name: "c"})
+	c := newContainerWaitFor("test", "c", config.ContainerName{Namespace: "test", Name: "a"})
+	d := newContainer("test", "d", config.ContainerName{Namespace: "test", Name: "e"})
+	e := newContainer("test", "e", config.ContainerName{Namespace: "test", Name: "d"})
+	e.Config.Net = &config.Net{Type: "container", Container: config.ContainerName{Namespace: "test", Name: "d"}}
+	f := newContainer("test", "f", config.ContainerName{Namespace: "metrics", Name: "f"})
+
+	_, err := NewDiff("test").Diff([]*Container{e, d, c, b, a, f}, []*Container{newContainer("metrics", "f")})
+	assert.Equal(t, ErrDependencyCycles{[]string{
+		"test.a -links-> test.b -volumes_from-> test.c -wait_for-> test.a",
+		"test.d -volumes_from-> test.e -volumes_from,net-> test.d",
+	}}, err)
+	assert.Equal(t, `Dependencies have cycles, check links, volumes_from, wait_for, net and ipc:
+  test.a -links-> test.b -volumes_from-> test.c -wait_for-> test.a
+  test.d -volumes_from-> test.e -volumes_from,net-> test.d`, err.Error())
+}
+
+func TestCheckDependencyCycles(t *testing.T) {
+	a := newContainer("test", "a", config.ContainerName{Namespace: "test", Name: "b"}, config.ContainerName{Namespace: "other", Name: "x"})
+	b := newContainer("test", "b", config.ContainerName{Namespace: "test", Name: "a"}, config.ContainerName{Namespace: "test", Name: "b"})
+	c := newContainer("test", "c", config.ContainerName{Namespace: "test", Name: "a"})
+
+	// external containers are not needed to find cycles,
+	// a single cycle is reported for a and b that depend on each other
+	assert.Equal(t, ErrDependencyCycles{[]string{
+		"test.a -volumes_from-> test.b -volumes_from-> test.a",
+	}}, CheckDependencyCycles("test", []*Container{a, b, c}))
+
+	// a container that depends on itself
+	s := newContainer("test", "s", config.ContainerName{Namespace: "test", Name: "s"})
+	assert.Equal(t, ErrDependencyCycles{[]string{
+		"test.s -volumes_from-> test.s",
+	}}, CheckDependencyCycles("test", []*Container{s, c, newContainer("test", "a")}))
+
+	assert.Nil(t, CheckDependencyCycles("test", []*Container{c, newContainer("test", "a")}))
+}
+
+func TestCheckDependencyCyclesDense(t *testing.T) {
+	// every container depends on every other one, which makes too many
+	// elementary cycles to list them all, a single one is reported
+	names := []config.ContainerName{}
+	for i := 0; i < 30; i++ {
+		names = append(names, config.ContainerName{Namespace: "test", Name: fmt.Sprintf("%02d", i)})
+	}
+	containers := []*Container{}
+	for i, name := range names {
+		deps := append(append([]config.ContainerName{}, names[:i]...), names[i+1:]...)
+		containers = append(containers, newContainer("test", name.Name, deps...))
+	}
+
+	assert.Equal(t, ErrDependencyCycles{[]string{
+		"test.00 -volumes_from-> test.01 -volumes_from-> test.00",
+	}}, CheckDependencyCycles("test", containers))
+}
+
 func TestDiffDifferentConfig(t *testing.T) {
 	cmp := NewDiff("test")
 	containers := []*Container{}
@@ -410,6 +500,27 @@ func TestDiffRecovery(t *testing.T) {
 	mock.AssertExpectations(t)
 }
 
+func TestDependencyOrder(t *testing.T) {
+	c1 := newContainer("test", "1", config.ContainerName{Namespace: "test", Name: "2"}, config.ContainerName{Namespace: "test", Name: "3"})
+	c2 := newContainer("test", "2", config.ContainerName{Namespace: "test", Name: "4"})
+	c3 := newContainer("test", "3", config.ContainerName{Namespace: "test", Name: "4"})
+	c4 := newContainer("test", "4")
+	c5 := newContainer("test", "5", config.ContainerName{Namespace: "metrics", Name: "1"})
+	m1 := newContainer("metrics", "1")
+
+	steps, err := dependencyOrder("test", []*Container{c1, c2, c3, c4, c5}, []*Container{m1})
+	assert.Nil(t, err)
+	assert.Equal(t, [][]*Container{{c4, c5}, {c2, c3}, {c1}}, steps)
+}
+
+func TestDependencyOrderCycle(t *testing.T) {
+	c1 := newContainer("test", "1", config.ContainerName{Namespace: "test", Name: "2"})
+	c2 := newContainer("test", "2", config.ContainerName{Namespace: "test", Name: "1"})
+
+	_, err := dependencyOrder("test", []*Container{c1, c2}, []*Container{})
+	assert.NotNil(t, err)
+}
+
 func newContainer(namespace string, name string, dependencies ...config.ContainerName) *Container {
 	return &Container{
 		State: &ContainerState{
@@ -436,7 +547,7 @@ func newContainerWaitFor(namespace string, name string, dependencies ...config.C
 
 func (m *clientMock) GetContainers(global bool) ([]*Container, error) {
 	args := m.Called()
-	return nil, args.Error(0)
+	return args.Get(0).([]*Container), args.Error(1)
 }
 
 func (m *clientMock) RemoveContainer(container *Container) error {
@@ -449,6 +560,16 @@ func (m *clientMock) RunContainer(container *Container) error {
 	return args.Error(0)
 }
 
+func (m *clientMock) StopContainer(container *Container) error {
+	args := m.Called(container)
+	return args.Error(0)
+}
+
+func (m *clientMock) StartContainer(container *Container) error {
+	args := m.Called(container)
+	return args.Error(0)
+}
+
 func (m *clientMock) EnsureContainerExist(container *Container) error {
 	args := m.Called(container)
 	return args.Error(0)
@@ -489,6 +610,11 @@ func (m *clientMock) FetchImages(container []*Container, vars template.Vars) err
 	return args.Error(0)
 }
 
+func (m *clientMock) InspectImages(container []*Container, vars template.Vars) error {
+	args := m.Called(container, vars)
+	return args.Error(0)
+}
+
 func (m *clientMock) WaitForContainer(container *Container) error {
 	args := m.Called(container)
 	return args.Error(0)
@@ -509,6 +635,26 @@ func (m *clientMock) Pin(local, hub bool, vars template.Vars, container []*Conta
 	return args.Error(0)
 }
 
+func (m *clientMock) Logs(containers []*Container, options LogsOptions) error {
+	args := m.Called(containers, options)
+	return args.Error(0)
+}
+
+func (m *clientMock) Exec(container *Container, options ExecOptions) (int, error) {
+	args := m.Called(container, options)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *clientMock) GetUnmanagedContainers(expected []*Container) ([]*Container, error) {
+	args := m.Called(expected)
+	return args.Get(0).([]*Container), args.Error(1)
+}
+
+func (m *clientMock) AdoptContainers(containers []*Container) error {
+	args := m.Called(containers)
+	return args.Error(0)
+}
+
 type clientMock struct {
 	mock.Mock
 }
@@ -21,13 +21,13 @@ import (
 	"io"
 	"os"
 
-	log "github.com/Sirupsen/logrus"
 	"github.com/docker/docker/pkg/jsonmessage"
-	"github.com/docker/docker/pkg/term"
 	"github.com/fsouza/go-dockerclient"
 	"github.com/grammarly/rocker/src/dockerclient"
 	"github.com/grammarly/rocker/src/imagename"
 	"github.com/grammarly/rocker/src/storage/s3"
+	"github.com/moby/term"
+	log "github.com/sirupsen/logrus"
 )
 
 const emptyImageName = "gliderlabs/alpine:3.2"
@@ -134,7 +134,7 @@ func PullDockerImage(client *docker.Client, image *imagename.ImageName, auth *do
 			out = def.Writer()
 		}
 
-		if err := jsonmessage.DisplayJSONMessagesStream(pipeReader, out, fd, isTerminal); err != nil {
+		if err := jsonmessage.DisplayJSONMessagesStream(pipeReader, out, fd, isTerminal, nil); err != nil {
 			return nil, fmt.Errorf("Failed to process json stream for image: %s, error: %s", image, err)
 		}
 
@@ -0,0 +1,104 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compose
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/moby/term"
+	log "github.com/sirupsen/logrus"
+)
+
+// ExecOptions are the options of 'rocker-compose exec'
+type ExecOptions struct {
+	Cmd  []string
+	Tty  bool
+	User string
+}
+
+// Exec runs a command inside of a running container and passes through
+// stdin, stdout and stderr of the current process. In case TTY is requested
+// and stdin is a terminal, the terminal is switched to raw mode for the session.
+// Returns the exit code of the command.
+func (client *DockerClient) Exec(container *Container, options ExecOptions) (int, error) {
+	exec, err := client.Docker.CreateExec(docker.CreateExecOptions{
+		Container:    container.ID,
+		Cmd:          options.Cmd,
+		Tty:          options.Tty,
+		User:         options.User,
+		AttachStdin:  true,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return -1, fmt.Errorf("Failed to create exec in container %s, error: %s", container.Name, err)
+	}
+
+	inFd, isTerminal := term.GetFdInfo(os.Stdin)
+
+	if options.Tty && isTerminal {
+		state, err := term.SetRawTerminal(inFd)
+		if err != nil {
+			return -1, fmt.Errorf("Failed to set terminal to raw mode, error: %s", err)
+		}
+		defer term.RestoreTerminal(inFd, state)
+	}
+
+	success := make(chan struct{})
+	errors := make(chan error, 1)
+
+	go func() {
+		errors <- client.Docker.StartExec(exec.ID, docker.StartExecOptions{
+			InputStream:  os.Stdin,
+			OutputStream: os.Stdout,
+			ErrorStream:  os.Stderr,
+			Tty:          options.Tty,
+			RawTerminal:  options.Tty,
+			Success:      success,
+		})
+	}()
+
+	select {
+	case err := <-errors:
+		if err != nil {
+			return -1, fmt.Errorf("Failed to start exec in container %s, error: %s", container.Name, err)
+		}
+	case ack := <-success:
+		// the size of the terminal can be set only after the exec is started
+		if options.Tty && isTerminal {
+			if ws, err := term.GetWinsize(inFd); err == nil {
+				if err := client.Docker.ResizeExecTTY(exec.ID, (int)(ws.Height), (int)(ws.Width)); err != nil {
+					log.Debugf("Failed to resize exec TTY, error: %s", err)
+				}
+			}
+		}
+		success <- ack
+
+		if err := <-errors; err != nil {
+			return -1, fmt.Errorf("Exec in container %s failed, error: %s", container.Name, err)
+		}
+	}
+
+	inspect, err := client.Docker.InspectExec(exec.ID)
+	if err != nil {
+		return -1, fmt.Errorf("Failed to inspect exec in container %s, error: %s", container.Name, err)
+	}
+
+	return inspect.ExitCode, nil
+}
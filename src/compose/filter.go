@@ -0,0 +1,147 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compose
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// filterContainers selects containers by glob patterns of their names given with
+// --only and --exclude options. A pattern list can also be comma separated, e.g. "api,worker".
+// Containers that match any of 'only' patterns (or all containers if there are none)
+// and none of 'exclude' patterns are selected.
+//
+// In case 'withDependencies' is true, containers of the namespace that the selected ones
+// depend on are selected as well, transitively, even if they are excluded.
+func filterContainers(ns string, containers []*Container, only, exclude []string, withDependencies bool) ([]*Container, error) {
+	only = splitPatterns(only)
+	exclude = splitPatterns(exclude)
+
+	if len(only) == 0 && len(exclude) == 0 {
+		return containers, nil
+	}
+
+	selected := map[*Container]bool{}
+
+	for _, c := range containers {
+		included, err := matchAny(only, c.Name.Name)
+		if err != nil {
+			return nil, err
+		}
+		excluded, err := matchAny(exclude, c.Name.Name)
+		if err != nil {
+			return nil, err
+		}
+		if (len(only) == 0 || included) && !excluded {
+			selected[c] = true
+		}
+	}
+
+	// check that every --only pattern matches something, to catch typos
+	for _, pattern := range only {
+		found := false
+		for _, c := range containers {
+			if ok, _ := filepath.Match(pattern, c.Name.Name); ok {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("No containers in the manifest match --only %s", pattern)
+		}
+	}
+
+	if withDependencies {
+		queue := []*Container{}
+		for _, c := range containers {
+			if selected[c] {
+				queue = append(queue, c)
+			}
+		}
+
+		for len(queue) > 0 {
+			target := queue[0]
+			queue = queue[1:]
+
+			for _, ref := range dependencyRefs(target) {
+				if ref.name.Namespace != ns {
+					continue
+				}
+				dep := find(containers, &ref.name)
+				if dep == nil || selected[dep] {
+					continue
+				}
+				if excluded, _ := matchAny(exclude, dep.Name.Name); excluded {
+					log.Warnf("Container %s is excluded, but it is included as a dependency of %s", dep.Name, target.Name)
+				} else {
+					log.Infof("Container %s is included as a dependency of %s", dep.Name, target.Name)
+				}
+				selected[dep] = true
+				queue = append(queue, dep)
+			}
+		}
+	}
+
+	result := []*Container{}
+	for _, c := range containers {
+		if selected[c] {
+			result = append(result, c)
+		}
+	}
+
+	return result, nil
+}
+
+// keepSelected returns actual containers that are either selected or belong to other namespaces
+func keepSelected(ns string, actual []*Container, selected []*Container) []*Container {
+	result := []*Container{}
+	for _, a := range actual {
+		if a.Name.Namespace != ns || find(selected, a.Name) != nil {
+			result = append(result, a)
+		}
+	}
+	return result
+}
+
+func splitPatterns(patterns []string) []string {
+	result := []string{}
+	for _, p := range patterns {
+		for _, s := range strings.Split(p, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				result = append(result, s)
+			}
+		}
+	}
+	return result
+}
+
+func matchAny(patterns []string, name string) (bool, error) {
+	for _, pattern := range patterns {
+		ok, err := filepath.Match(pattern, name)
+		if err != nil {
+			return false, fmt.Errorf("Bad container name pattern `%s`, error: %s", pattern, err)
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,88 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compose
+
+import (
+	"testing"
+
+	"github.com/grammarly/rocker-compose/src/compose/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterContainers(t *testing.T) {
+	db := newContainer("test", "db")
+	data := newContainer("test", "data")
+	api := newContainer("test", "api", config.ContainerName{Namespace: "test", Name: "data"})
+	api.Config.Links = []config.Link{{ContainerName: config.ContainerName{Namespace: "test", Name: "db"}, Alias: "db"}}
+	worker := newContainerWaitFor("test", "worker", config.ContainerName{Namespace: "test", Name: "api"})
+	batch1 := newContainer("test", "batch_1", config.ContainerName{Namespace: "metrics", Name: "data"})
+	batch2 := newContainer("test", "batch_2")
+
+	all := []*Container{db, data, api, worker, batch1, batch2}
+
+	selected, err := filterContainers("test", all, []string{}, []string{}, true)
+	assert.Nil(t, err)
+	assert.Equal(t, all, selected)
+
+	selected, err = filterContainers("test", all, []string{"worker"}, []string{}, true)
+	assert.Nil(t, err)
+	assert.Equal(t, []*Container{db, data, api, worker}, selected)
+
+	selected, err = filterContainers("test", all, []string{"worker"}, []string{}, false)
+	assert.Nil(t, err)
+	assert.Equal(t, []*Container{worker}, selected)
+
+	selected, err = filterContainers("test", all, []string{"api,batch_1"}, []string{}, true)
+	assert.Nil(t, err)
+	assert.Equal(t, []*Container{db, data, api, batch1}, selected)
+
+	selected, err = filterContainers("test", all, []string{}, []string{"batch_*"}, true)
+	assert.Nil(t, err)
+	assert.Equal(t, []*Container{db, data, api, worker}, selected)
+
+	// dependencies are included even if they are excluded
+	selected, err = filterContainers("test", all, []string{"api"}, []string{"db"}, true)
+	assert.Nil(t, err)
+	assert.Equal(t, []*Container{db, data, api}, selected)
+
+	_, err = filterContainers("test", all, []string{"web"}, []string{}, true)
+	assert.EqualError(t, err, "No containers in the manifest match --only web")
+
+	_, err = filterContainers("test", all, []string{"[a"}, []string{}, true)
+	assert.NotNil(t, err)
+}
+
+func TestDiffWithSelectionKeepsOtherContainers(t *testing.T) {
+	c1 := newContainer("test", "1")
+	c2 := newContainer("test", "2")
+	c3 := newContainer("test", "3")
+	m1 := newContainer("metrics", "1")
+
+	expected, err := filterContainers("test", []*Container{c1, c2}, []string{"1"}, []string{}, true)
+	assert.Nil(t, err)
+
+	actual := keepSelected("test", []*Container{c2, c3, m1}, expected)
+	assert.Equal(t, []*Container{m1}, actual)
+
+	actions, err := NewDiff("test").Diff(expected, actual)
+	assert.Nil(t, err)
+
+	client := clientMock{}
+	client.On("RunContainer", c1).Return(nil)
+	assert.Nil(t, NewDockerClientRunner(&client).Run(actions))
+	client.AssertExpectations(t)
+}
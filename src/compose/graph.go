@@ -0,0 +1,213 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compose
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/grammarly/rocker-compose/src/compose/config"
+)
+
+// DependencyGraph is a printable form of the graph of dependencies between containers
+type DependencyGraph struct {
+	Nodes []*GraphNode `json:"nodes"`
+	Edges []*GraphEdge `json:"edges"`
+}
+
+// GraphNode is a container in the dependency graph
+type GraphNode struct {
+	Name     string `json:"name"`
+	State    string `json:"state,omitempty"`
+	External bool   `json:"external"`
+}
+
+// GraphEdge is a dependency of a container 'From' on a container 'To'
+type GraphEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Kind string `json:"kind"`
+}
+
+// NewDependencyGraph makes the dependency graph of 'expected' containers. Containers
+// of other namespaces are not looked up in docker, they are added as external nodes.
+func NewDependencyGraph(ns string, expected []*Container) (*DependencyGraph, error) {
+	var (
+		g        = &DependencyGraph{Nodes: []*GraphNode{}, Edges: []*GraphEdge{}}
+		external = externalContainers(ns, expected)
+	)
+
+	for _, c := range expected {
+		state := "running"
+		if c.Config.State != nil {
+			state = (string)(*c.Config.State)
+		}
+		g.Nodes = append(g.Nodes, &GraphNode{Name: c.Name.String(), State: state})
+
+		deps, err := resolveDependencies(ns, expected, external, c)
+		if err != nil {
+			return nil, err
+		}
+		for _, dep := range deps {
+			for _, kind := range dep.kinds {
+				g.Edges = append(g.Edges, &GraphEdge{
+					From: c.Name.String(),
+					To:   dep.container.Name.String(),
+					Kind: kind,
+				})
+			}
+		}
+	}
+
+	for _, c := range external {
+		g.Nodes = append(g.Nodes, &GraphNode{Name: c.Name.String(), External: true})
+	}
+
+	sort.Sort(graphNodes(g.Nodes))
+	sort.Sort(graphEdges(g.Edges))
+
+	return g, nil
+}
+
+// WriteDot writes the graph in the Graphviz DOT format
+func (g *DependencyGraph) WriteDot(w io.Writer) error {
+	nodeStyles := map[string]string{
+		"running": `shape=box`,
+		"ran":     `shape=box, style="rounded,filled", fillcolor=lightgrey`,
+		"created": `shape=box, style=filled, fillcolor=lightyellow`,
+	}
+	edgeStyles := map[string]string{
+		DependencyLinks:       `style=solid`,
+		DependencyVolumesFrom: `style=bold, color=blue`,
+		DependencyWaitFor:     `style=dashed`,
+		DependencyNet:         `style=bold, color=red`,
+		DependencyIpc:         `style=bold, color=purple`,
+	}
+
+	fmt.Fprintln(w, "digraph dependencies {")
+	fmt.Fprintln(w, "  rankdir=LR;")
+
+	for _, node := range g.Nodes {
+		switch {
+		case node.External:
+			fmt.Fprintf(w, "  %q [label=%q, shape=box, style=dashed];\n", node.Name, node.Name+"\n(external)")
+		case node.State == "running":
+			fmt.Fprintf(w, "  %q [%s];\n", node.Name, nodeStyles[node.State])
+		default:
+			fmt.Fprintf(w, "  %q [label=%q, %s];\n", node.Name, node.Name+"\n("+node.State+")", nodeStyles[node.State])
+		}
+	}
+
+	for _, edge := range g.Edges {
+		fmt.Fprintf(w, "  %q -> %q [label=%q, %s];\n", edge.From, edge.To, edge.Kind, edgeStyles[edge.Kind])
+	}
+
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
+
+// WriteMermaid writes the graph as a Mermaid flowchart
+func (g *DependencyGraph) WriteMermaid(w io.Writer) error {
+	arrows := map[string]string{
+		DependencyLinks:       "-->",
+		DependencyVolumesFrom: "==>",
+		DependencyWaitFor:     "-.->",
+		DependencyNet:         "--o",
+		DependencyIpc:         "--o",
+	}
+
+	// mermaid ids cannot contain dots, so nodes are numbered
+	ids := map[string]string{}
+	for i, node := range g.Nodes {
+		ids[node.Name] = fmt.Sprintf("n%d", i)
+	}
+
+	fmt.Fprintln(w, "graph LR")
+
+	for _, node := range g.Nodes {
+		id := ids[node.Name]
+		switch {
+		case node.External:
+			fmt.Fprintf(w, "  %s[\"%s (external)\"]:::external\n", id, node.Name)
+		case node.State == "running":
+			fmt.Fprintf(w, "  %s[\"%s\"]\n", id, node.Name)
+		default:
+			fmt.Fprintf(w, "  %s([\"%s (%s)\"]):::%s\n", id, node.Name, node.State, node.State)
+		}
+	}
+
+	for _, edge := range g.Edges {
+		fmt.Fprintf(w, "  %s %s|%s| %s\n", ids[edge.From], arrows[edge.Kind], edge.Kind, ids[edge.To])
+	}
+
+	fmt.Fprintln(w, "  classDef external stroke-dasharray: 5 5")
+	fmt.Fprintln(w, "  classDef ran fill:#eee")
+	_, err := fmt.Fprintln(w, "  classDef created fill:#ffd")
+	return err
+}
+
+// WriteJSON writes the graph as a JSON object of nodes and edges
+func (g *DependencyGraph) WriteJSON(w io.Writer) error {
+	data, err := json.MarshalIndent(g, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// externalContainers makes placeholders of containers from other namespaces
+// that 'expected' containers depend on, so dependencies can be resolved without docker
+func externalContainers(ns string, expected []*Container) []*Container {
+	external := []*Container{}
+	for _, c := range expected {
+		for _, ref := range dependencyRefs(c) {
+			if ref.name.Namespace == ns || find(external, &ref.name) != nil {
+				continue
+			}
+			n := ref.name
+			external = append(external, &Container{
+				Name:   &n,
+				State:  &ContainerState{},
+				Config: &config.Container{},
+			})
+		}
+	}
+	return external
+}
+
+type graphNodes []*GraphNode
+
+func (n graphNodes) Len() int           { return len(n) }
+func (n graphNodes) Less(i, j int) bool { return n[i].Name < n[j].Name }
+func (n graphNodes) Swap(i, j int)      { n[i], n[j] = n[j], n[i] }
+
+type graphEdges []*GraphEdge
+
+func (e graphEdges) Len() int      { return len(e) }
+func (e graphEdges) Swap(i, j int) { e[i], e[j] = e[j], e[i] }
+func (e graphEdges) Less(i, j int) bool {
+	if e[i].From != e[j].From {
+		return e[i].From < e[j].From
+	}
+	if e[i].To != e[j].To {
+		return e[i].To < e[j].To
+	}
+	return e[i].Kind < e[j].Kind
+}
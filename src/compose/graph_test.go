@@ -0,0 +1,95 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compose
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/grammarly/rocker-compose/src/compose/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func newGraphContainers() []*Container {
+	var ran config.State = "ran"
+
+	data := newContainer("test", "data")
+	data.Config.State = &ran
+	api := newContainer("test", "api", config.ContainerName{Namespace: "test", Name: "data"})
+	api.Config.Links = []config.Link{
+		{ContainerName: config.ContainerName{Namespace: "test", Name: "data"}, Alias: "data"},
+		{ContainerName: config.ContainerName{Namespace: "common", Name: "mysql"}, Alias: "mysql"},
+	}
+	worker := newContainerWaitFor("test", "worker", config.ContainerName{Namespace: "test", Name: "api"})
+	worker.Config.Net = &config.Net{Type: "container", Container: config.ContainerName{Namespace: "test", Name: "api"}}
+
+	return []*Container{worker, api, data}
+}
+
+func TestDependencyGraph(t *testing.T) {
+	g, err := NewDependencyGraph("test", newGraphContainers())
+	assert.Nil(t, err)
+
+	assert.Equal(t, []*GraphNode{
+		{Name: "common.mysql", External: true},
+		{Name: "test.api", State: "running"},
+		{Name: "test.data", State: "ran"},
+		{Name: "test.worker", State: "running"},
+	}, g.Nodes)
+
+	assert.Equal(t, []*GraphEdge{
+		{From: "test.api", To: "common.mysql", Kind: DependencyLinks},
+		{From: "test.api", To: "test.data", Kind: DependencyLinks},
+		{From: "test.api", To: "test.data", Kind: DependencyVolumesFrom},
+		{From: "test.worker", To: "test.api", Kind: DependencyNet},
+		{From: "test.worker", To: "test.api", Kind: DependencyWaitFor},
+	}, g.Edges)
+}
+
+func TestDependencyGraphWriteMermaid(t *testing.T) {
+	g, err := NewDependencyGraph("test", newGraphContainers())
+	assert.Nil(t, err)
+
+	var buf bytes.Buffer
+	assert.Nil(t, g.WriteMermaid(&buf))
+
+	assert.Equal(t, `graph LR
+  n0["common.mysql (external)"]:::external
+  n1["test.api"]
+  n2(["test.data (ran)"]):::ran
+  n3["test.worker"]
+  n1 -->|links| n0
+  n1 -->|links| n2
+  n1 ==>|volumes_from| n2
+  n3 --o|net| n1
+  n3 -.->|wait_for| n1
+  classDef external stroke-dasharray: 5 5
+  classDef ran fill:#eee
+  classDef created fill:#ffd
+`, buf.String())
+}
+
+func TestDependencyGraphWriteDot(t *testing.T) {
+	g, err := NewDependencyGraph("test", newGraphContainers())
+	assert.Nil(t, err)
+
+	var buf bytes.Buffer
+	assert.Nil(t, g.WriteDot(&buf))
+
+	assert.Contains(t, buf.String(), `"common.mysql" [label="common.mysql\n(external)", shape=box, style=dashed];`)
+	assert.Contains(t, buf.String(), `"test.worker" -> "test.api" [label="wait_for", style=dashed];`)
+}
@@ -0,0 +1,200 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compose
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/grammarly/rocker-compose/src/compose/config"
+
+	"github.com/fsouza/go-dockerclient"
+	log "github.com/sirupsen/logrus"
+)
+
+// LogsOptions are the options of 'rocker-compose logs'
+type LogsOptions struct {
+	Follow     bool
+	Tail       string
+	Since      time.Time
+	Timestamps bool
+}
+
+// logsFollower keeps track of log streams that are currently read,
+// so a container is never streamed twice
+type logsFollower struct {
+	client     *DockerClient
+	containers []*Container
+	options    LogsOptions
+
+	mu        sync.Mutex
+	streaming map[string]bool
+	wg        sync.WaitGroup
+}
+
+// Logs reads logs of given containers and writes them to the log tagged by container names.
+// In follow mode it keeps streaming and picks up containers that are started, restarted
+// or recreated, until the connection to the docker daemon is lost.
+func (client *DockerClient) Logs(containers []*Container, options LogsOptions) error {
+	lf := &logsFollower{
+		client:     client,
+		containers: containers,
+		options:    options,
+		streaming:  map[string]bool{},
+	}
+
+	for _, container := range containers {
+		if container.Io == nil {
+			container.Io = NewContainerIo(container)
+		}
+		if container.ID == "" {
+			log.Infof("Container %s does not exist", container.Name)
+			continue
+		}
+		lf.stream(container, container.ID, options.Tail, options.Since)
+	}
+
+	if !options.Follow {
+		lf.wg.Wait()
+		return nil
+	}
+
+	return lf.listen()
+}
+
+// stream starts reading logs of a particular container instance in background
+func (lf *logsFollower) stream(container *Container, id, tail string, since time.Time) {
+	lf.mu.Lock()
+	defer lf.mu.Unlock()
+
+	if lf.streaming[id] {
+		return
+	}
+	lf.streaming[id] = true
+	lf.wg.Add(1)
+
+	opts := lf.logsOptions(container, id, tail, since)
+
+	go func() {
+		defer lf.wg.Done()
+
+		if err := lf.client.Docker.Logs(opts); err != nil {
+			log.Errorf("Failed to read logs of container %s (%.12s), error: %s", container.Name, id, err)
+		}
+
+		lf.mu.Lock()
+		delete(lf.streaming, id)
+		lf.mu.Unlock()
+	}()
+}
+
+// logsOptions returns options of reading logs of a particular container instance,
+// logs of containers with a tty are not multiplexed and come as they are
+func (lf *logsFollower) logsOptions(container *Container, id, tail string, since time.Time) docker.LogsOptions {
+	opts := docker.LogsOptions{
+		Container:    id,
+		OutputStream: container.Io.Stdout,
+		ErrorStream:  container.Io.Stderr,
+		Stdout:       true,
+		Stderr:       true,
+		Follow:       lf.options.Follow,
+		Timestamps:   lf.options.Timestamps,
+		Tail:         tail,
+		RawTerminal:  container.isTty(),
+	}
+	if !since.IsZero() {
+		opts.Since = since.Unix()
+	}
+	return opts
+}
+
+// listen waits for containers to be started and follows their logs
+func (lf *logsFollower) listen() error {
+	// The code is partially borrowed from https://github.com/jwilder/docker-gen
+	eventChan := make(chan *docker.APIEvents, 100)
+	defer close(eventChan)
+
+	if err := lf.client.Docker.AddEventListener(eventChan); err != nil {
+		return fmt.Errorf("Failed to start listening for Docker events, error: %s", err)
+	}
+
+	for {
+		if err := lf.client.Docker.Ping(); err != nil {
+			return fmt.Errorf("Unable to ping docker daemon: %s", err)
+		}
+
+		select {
+
+		case event := <-eventChan:
+			if event == nil {
+				return fmt.Errorf("Got nil event from Docker API")
+			}
+
+			// We are interested only in "start" events here
+			if event.Status != "start" {
+				break
+			}
+
+			go lf.onStart(event)
+
+		case <-time.After(10 * time.Second):
+			// check for docker liveness
+		}
+	}
+}
+
+// onStart follows logs of a started container in case it belongs to the list;
+// recreated containers are read from the beginning, restarted ones from the start time
+func (lf *logsFollower) onStart(event *docker.APIEvents) {
+	inspect, err := lf.client.Docker.InspectContainer(event.ID)
+	if err != nil {
+		log.Errorf("Failed to inspect container %.12s, error: %s", event.ID, err)
+		return
+	}
+	eventContainer, err := NewContainerFromDocker(inspect)
+	if err != nil {
+		// Ignore ErrNotRockerCompose error
+		if _, ok := err.(config.ErrNotRockerCompose); !ok {
+			log.Errorf("Failed to init container %.12s from Docker API, error: %s", event.ID, err)
+		}
+		return
+	}
+
+	var container *Container
+	for _, c := range lf.containers {
+		if c.IsSameKind(eventContainer) {
+			container = c
+			break
+		}
+	}
+	if container == nil {
+		return
+	}
+
+	since := time.Unix(event.Time, 0)
+
+	lf.mu.Lock()
+	if container.ID != eventContainer.ID {
+		log.Infof("Container %s (%.12s) is created, following its logs", container.Name, eventContainer.ID)
+		container.ID = eventContainer.ID
+		since = time.Time{}
+	}
+	lf.mu.Unlock()
+
+	lf.stream(container, eventContainer.ID, "all", since)
+}
@@ -0,0 +1,47 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compose
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogsOptions(t *testing.T) {
+	lf := &logsFollower{options: LogsOptions{Follow: true, Timestamps: true}}
+
+	container := newContainer("test", "web")
+	container.Io = NewContainerIo(container)
+
+	since := time.Unix(1500000000, 0)
+	opts := lf.logsOptions(container, "abc", "10", since)
+	assert.Equal(t, "abc", opts.Container)
+	assert.Equal(t, "10", opts.Tail)
+	assert.Equal(t, int64(1500000000), opts.Since)
+	assert.True(t, opts.Follow)
+	assert.True(t, opts.Timestamps)
+	assert.False(t, opts.RawTerminal)
+
+	// logs of containers with a tty are not multiplexed
+	tty := true
+	container.Config.Tty = &tty
+	opts = lf.logsOptions(container, "abc", "all", time.Time{})
+	assert.True(t, opts.RawTerminal)
+	assert.Equal(t, int64(0), opts.Since)
+}
@@ -0,0 +1,261 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compose
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/grammarly/rocker-compose/src/compose/config"
+)
+
+// Kinds of changes that may happen to a container during the run
+const (
+	PlanCreate   = "create"
+	PlanRecreate = "recreate"
+	PlanRemove   = "remove"
+	PlanEnsure   = "ensure"
+	PlanNone     = "none"
+)
+
+// Plan is a human readable explanation of the execution plan produced by Diff.
+// It has one item per container, sorted by container name.
+type Plan []*PlanItem
+
+// PlanItem explains what is going to happen with a single container and why
+type PlanItem struct {
+	Container *Container
+	Action    string
+	Changes   []config.FieldDiff
+
+	// Dependencies that cause the container to be recreated, in case
+	// the container itself has no changes
+	RecreatedBy []*config.ContainerName
+}
+
+// NewPlan walks through the execution plan produced by Diff and explains every
+// action by comparing 'expected' and 'actual' containers field by field.
+func NewPlan(ns string, expected []*Container, actual []*Container, actions []Action) Plan {
+	var (
+		plan    = Plan{}
+		created = map[*Container]bool{}
+		removed = map[*Container]bool{}
+		ensured = map[*Container]bool{}
+	)
+
+	WalkActions(actions, func(action Action) {
+		switch a := action.(type) {
+		case *runContainer:
+			created[a.container] = true
+		case *removeContainer:
+			removed[a.container] = true
+		case *ensureContainerState:
+			ensured[a.container] = true
+		}
+	})
+
+	recreated := map[*Container]bool{}
+
+	for _, e := range expected {
+		item := &PlanItem{Container: e, Action: PlanNone}
+
+		var actualC *Container
+		for _, a := range actual {
+			if e.IsSameKind(a) {
+				actualC = a
+				break
+			}
+		}
+
+		switch {
+		case created[e] && actualC != nil && removed[actualC]:
+			item.Action = PlanRecreate
+			item.Changes = e.Diff(actualC)
+			recreated[e] = true
+		case created[e]:
+			item.Action = PlanCreate
+		case ensured[e]:
+			item.Action = PlanEnsure
+			if actualC != nil {
+				item.Changes = e.Diff(actualC)
+			}
+		}
+
+		plan = append(plan, item)
+	}
+
+	// explain recreations caused by dependencies
+	for _, item := range plan {
+		if item.Action != PlanRecreate || len(item.Changes) > 0 {
+			continue
+		}
+		deps, err := resolveDependencies(ns, expected, actual, item.Container)
+		if err != nil {
+			continue
+		}
+		for _, dep := range deps {
+			if !dep.external && !dep.waitForIt && recreated[dep.container] {
+				item.RecreatedBy = append(item.RecreatedBy, dep.container.Name)
+			}
+		}
+	}
+
+	// containers that are removed and not going to be created again
+	for _, a := range actual {
+		if !removed[a] {
+			continue
+		}
+		if find(expected, a.Name) != nil {
+			continue
+		}
+		plan = append(plan, &PlanItem{Container: a, Action: PlanRemove})
+	}
+
+	sort.Sort(plan)
+
+	return plan
+}
+
+// Len returns the number of items in the plan
+func (p Plan) Len() int {
+	return len(p)
+}
+
+// Less compares plan items by container name
+func (p Plan) Less(i, j int) bool {
+	return p[i].Container.Name.String() < p[j].Container.Name.String()
+}
+
+// Swap swaps plan items
+func (p Plan) Swap(i, j int) {
+	p[i], p[j] = p[j], p[i]
+}
+
+// Count returns the number of plan items with the given action
+func (p Plan) Count(action string) (n int) {
+	for _, item := range p {
+		if item.Action == action {
+			n++
+		}
+	}
+	return n
+}
+
+// String returns the printable representation of the plan
+func (p Plan) String() string {
+	var buf bytes.Buffer
+
+	for _, item := range p {
+		buf.WriteString(item.String())
+	}
+
+	buf.WriteString(fmt.Sprintf("\nPlan: %d to create, %d to recreate, %d to remove, %d unchanged.\n",
+		p.Count(PlanCreate),
+		p.Count(PlanRecreate),
+		p.Count(PlanRemove),
+		p.Count(PlanNone)+p.Count(PlanEnsure)))
+
+	return buf.String()
+}
+
+// WriteTo writes the printable representation of the plan to a given io.Writer
+func (p Plan) WriteTo(w io.Writer) (int64, error) {
+	n, err := io.WriteString(w, p.String())
+	return (int64)(n), err
+}
+
+// String returns the printable representation of the plan item
+// with before/after values of every changed property
+func (item *PlanItem) String() string {
+	var buf bytes.Buffer
+
+	sign := map[string]string{
+		PlanCreate:   "+",
+		PlanRecreate: "~",
+		PlanRemove:   "-",
+		PlanEnsure:   "?",
+		PlanNone:     " ",
+	}[item.Action]
+
+	buf.WriteString(fmt.Sprintf("%s %s (%s)\n", sign, item.Container.Name, item.Action))
+
+	for _, change := range item.Changes {
+		writeFieldDiff(&buf, change)
+	}
+
+	if len(item.RecreatedBy) > 0 {
+		names := []string{}
+		for _, name := range item.RecreatedBy {
+			names = append(names, name.String())
+		}
+		buf.WriteString(fmt.Sprintf("    dependencies are recreated: %s\n", strings.Join(names, ", ")))
+	}
+
+	return buf.String()
+}
+
+// writeFieldDiff writes a single property change; single line values are printed
+// as "old => new", multi-line values (maps, lists) are printed line by line
+// with "-" and "+" marks for removed and added lines
+func writeFieldDiff(buf *bytes.Buffer, change config.FieldDiff) {
+	if !strings.Contains(change.Old, "\n") && !strings.Contains(change.New, "\n") {
+		buf.WriteString(fmt.Sprintf("    %s: %s => %s\n", change.Field, orNone(change.Old), orNone(change.New)))
+		return
+	}
+
+	buf.WriteString(fmt.Sprintf("    %s:\n", change.Field))
+
+	oldLines := splitLines(change.Old)
+	newLines := splitLines(change.New)
+
+	for _, line := range oldLines {
+		if !containsString(newLines, line) {
+			buf.WriteString(fmt.Sprintf("      - %s\n", line))
+		}
+	}
+	for _, line := range newLines {
+		if !containsString(oldLines, line) {
+			buf.WriteString(fmt.Sprintf("      + %s\n", line))
+		}
+	}
+}
+
+func orNone(value string) string {
+	if value == "" {
+		return "<none>"
+	}
+	return value
+}
+
+func splitLines(str string) []string {
+	if str == "" {
+		return []string{}
+	}
+	return strings.Split(str, "\n")
+}
+
+func containsString(list []string, str string) bool {
+	for _, s := range list {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compose
+
+import (
+	"testing"
+
+	"github.com/grammarly/rocker-compose/src/compose/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlanExplainsChanges(t *testing.T) {
+	c1 := newContainer("test", "1", config.ContainerName{Namespace: "test", Name: "2"})
+	c2 := newContainer("test", "2")
+	c2x := newContainer("test", "2")
+	c2x.Config.Labels = map[string]string{"test": "test2"}
+	c3 := newContainer("test", "3")
+	c4 := newContainer("test", "4")
+	c5 := newContainer("test", "5")
+
+	expected := []*Container{c1, c2x, c3, c4}
+	actual := []*Container{c1, c2, c3, c5}
+
+	actions, err := NewDiff("test").Diff(expected, actual)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plan := NewPlan("test", expected, actual, actions)
+
+	assert.Len(t, plan, 5)
+
+	// 1 is recreated because it depends on 2
+	assert.Equal(t, "test.1", plan[0].Container.Name.String())
+	assert.Equal(t, PlanRecreate, plan[0].Action)
+	assert.Empty(t, plan[0].Changes)
+	assert.Equal(t, []*config.ContainerName{c2x.Name}, plan[0].RecreatedBy)
+
+	assert.Equal(t, "test.2", plan[1].Container.Name.String())
+	assert.Equal(t, PlanRecreate, plan[1].Action)
+	assert.Equal(t, []config.FieldDiff{{Field: "labels", Old: "", New: "test: test2"}}, plan[1].Changes)
+
+	assert.Equal(t, PlanNone, plan[2].Action)
+	assert.Equal(t, PlanCreate, plan[3].Action)
+	assert.Equal(t, PlanRemove, plan[4].Action)
+	assert.Equal(t, c5, plan[4].Container)
+
+	assert.Equal(t, `~ test.1 (recreate)
+    dependencies are recreated: test.2
+~ test.2 (recreate)
+    labels: <none> => test: test2
+  test.3 (none)
++ test.4 (create)
+- test.5 (remove)
+
+Plan: 1 to create, 2 to recreate, 1 to remove, 1 unchanged.
+`, plan.String())
+}
+
+func TestContainerDiff(t *testing.T) {
+	c1 := newContainer("test", "1")
+	c1.ImageID = "0123456789abcdef"
+	c2 := newContainer("test", "1")
+	c2.ImageID = "fedcba9876543210"
+	c2.State.Running = false
+	c2.Config.Env = map[string]string{"FOO": "bar", "XXX": "yyy"}
+
+	assert.Equal(t, []config.FieldDiff{
+		{Field: "env", Old: "FOO: bar\nXXX: yyy", New: ""},
+		{Field: "image_id", Old: "fedcba987654", New: "0123456789ab"},
+		{Field: "state", Old: "not running", New: "running"},
+	}, c1.Diff(c2))
+}
+
+func TestContainerDiffUnmanaged(t *testing.T) {
+	c1 := newContainer("test", "1")
+	c2 := newContainer("test", "1")
+	c2.unmanaged = true
+
+	assert.Equal(t, []config.FieldDiff{
+		{Field: "managed_by", Old: "other tool", New: "rocker-compose"},
+	}, c1.Diff(c2))
+
+	c2.unmanaged = false
+	assert.Empty(t, c1.Diff(c2))
+}
@@ -17,7 +17,7 @@
 package compose
 
 import (
-	log "github.com/Sirupsen/logrus"
+	log "github.com/sirupsen/logrus"
 )
 
 // Runner interface describes a runnable facade which executes given list of actions
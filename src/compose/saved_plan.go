@@ -0,0 +1,358 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compose
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/go-yaml/yaml"
+	"github.com/grammarly/rocker-compose/src/compose/config"
+	"github.com/grammarly/rocker/src/imagename"
+)
+
+// SavedPlanVersion is the version of the saved plan format
+const SavedPlanVersion = 1
+
+// SavedPlan is a serializable form of the execution plan produced by Diff.
+// It is made by 'rocker-compose plan -out' and executed by 'rocker-compose apply'.
+// Along with the action tree it keeps the state of containers observed at the time
+// the plan was made, so the plan can be refused if the state has changed since then.
+type SavedPlan struct {
+	Version      int                           `json:"version"`
+	Namespace    string                        `json:"namespace"`
+	ManifestHash string                        `json:"manifest_hash"`
+	Global       bool                          `json:"global,omitempty"`
+	Observed     map[string]*ObservedContainer `json:"observed"`
+	Containers   []*SavedContainer             `json:"containers"`
+	Actions      []*SavedAction                `json:"actions"`
+}
+
+// ObservedContainer is the state of an existing container at the time the plan was made
+type ObservedContainer struct {
+	ID      string `json:"id"`
+	Running bool   `json:"running"`
+}
+
+// SavedContainer is a serializable form of the Container referred by plan actions
+type SavedContainer struct {
+	ID      string `json:"id,omitempty"`
+	Name    string `json:"name"`
+	Image   string `json:"image,omitempty"`
+	ImageID string `json:"image_id,omitempty"`
+	Running bool   `json:"running"`
+	Config  string `json:"config"`
+}
+
+// SavedAction is a serializable form of a single Action, steps keep their nested actions;
+// Container is an index in the SavedPlan.Containers list
+type SavedAction struct {
+	Type      string         `json:"type"`
+	Async     bool           `json:"async,omitempty"`
+	Actions   []*SavedAction `json:"actions,omitempty"`
+	Container *int           `json:"container,omitempty"`
+}
+
+// Types of saved actions
+const (
+	savedActionStep        = "step"
+	savedActionNoop        = "noop"
+	savedActionRun         = "run"
+	savedActionRemove      = "remove"
+	savedActionEnsureExist = "ensure_exist"
+	savedActionEnsureState = "ensure_state"
+	savedActionWait        = "wait"
+)
+
+// ErrPlanDrifted is an error that describes the difference between the state
+// of containers observed by the plan and the current one
+type ErrPlanDrifted struct {
+	Changes []string
+}
+
+// Error returns string representation of the error
+func (e ErrPlanDrifted) Error() string {
+	return fmt.Sprintf("State of containers has changed since the plan was made, make a new plan:\n  %s",
+		strings.Join(e.Changes, "\n  "))
+}
+
+// NewSavedPlan makes a serializable plan of given actions
+func NewSavedPlan(manifest *config.Config, actual []*Container, actions []Action) (*SavedPlan, error) {
+	hash, err := manifest.Hash()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to calculate manifest hash, error: %s", err)
+	}
+
+	plan := &SavedPlan{
+		Version:      SavedPlanVersion,
+		Namespace:    manifest.Namespace,
+		ManifestHash: hash,
+		Global:       manifest.HasExternalRefs(),
+		Containers:   []*SavedContainer{},
+		Actions:      []*SavedAction{},
+	}
+
+	index := map[*Container]int{}
+
+	for _, a := range actions {
+		saved, err := plan.encodeAction(a, index)
+		if err != nil {
+			return nil, err
+		}
+		plan.Actions = append(plan.Actions, saved)
+	}
+
+	plan.Observed = observeContainers(plan.Namespace, actual, plan.Containers)
+
+	return plan, nil
+}
+
+// ReadSavedPlan reads a plan from JSON stream
+func ReadSavedPlan(r io.Reader) (*SavedPlan, error) {
+	plan := &SavedPlan{}
+	if err := json.NewDecoder(r).Decode(plan); err != nil {
+		return nil, fmt.Errorf("Failed to parse plan, error: %s", err)
+	}
+	if plan.Version != SavedPlanVersion {
+		return nil, fmt.Errorf("Unsupported plan version %d, expected %d", plan.Version, SavedPlanVersion)
+	}
+	return plan, nil
+}
+
+// WriteTo writes json encoded plan to a given io.Writer
+func (plan *SavedPlan) WriteTo(w io.Writer) (int64, error) {
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(append(data, '\n'))
+	return (int64)(n), err
+}
+
+// GetActions restores the action tree from the saved plan
+func (plan *SavedPlan) GetActions() ([]Action, error) {
+	containers := make([]*Container, len(plan.Containers))
+
+	for i, saved := range plan.Containers {
+		cfg := &config.Container{}
+		if err := yaml.Unmarshal([]byte(saved.Config), cfg); err != nil {
+			return nil, fmt.Errorf("Failed to parse config of container %s from the plan, error: %s", saved.Name, err)
+		}
+		containers[i] = &Container{
+			ID:      saved.ID,
+			Name:    config.NewContainerNameFromString(saved.Name),
+			ImageID: saved.ImageID,
+			State:   &ContainerState{Running: saved.Running},
+			Config:  cfg,
+		}
+		if saved.Image != "" {
+			containers[i].Image = imagename.NewFromString(saved.Image)
+		}
+	}
+
+	actions := []Action{}
+	for _, saved := range plan.Actions {
+		a, err := decodeAction(saved, containers)
+		if err != nil {
+			return nil, err
+		}
+		actions = append(actions, a)
+	}
+
+	return actions, nil
+}
+
+// CheckDrift compares the state of containers observed by the plan against the current one
+// and returns ErrPlanDrifted if containers were created, removed, recreated or changed state.
+func (plan *SavedPlan) CheckDrift(actual []*Container) error {
+	current := observeContainers(plan.Namespace, actual, plan.Containers)
+	changes := []string{}
+
+	for name, was := range plan.Observed {
+		now, ok := current[name]
+		switch {
+		case !ok:
+			changes = append(changes, fmt.Sprintf("%s (id:%.12s) was removed", name, was.ID))
+		case now.ID != was.ID:
+			changes = append(changes, fmt.Sprintf("%s was recreated (id:%.12s -> id:%.12s)", name, was.ID, now.ID))
+		case now.Running != was.Running:
+			changes = append(changes, fmt.Sprintf("%s (id:%.12s) changed state: %s -> %s", name, now.ID,
+				(&ContainerState{Running: was.Running}), (&ContainerState{Running: now.Running})))
+		}
+	}
+
+	for name, now := range current {
+		if _, ok := plan.Observed[name]; !ok {
+			changes = append(changes, fmt.Sprintf("%s (id:%.12s) was created", name, now.ID))
+		}
+	}
+
+	if len(changes) > 0 {
+		sort.Strings(changes)
+		return ErrPlanDrifted{changes}
+	}
+
+	return nil
+}
+
+// CheckManifest returns ErrPlanDrifted if the given manifest is not the one the plan was made of
+func (plan *SavedPlan) CheckManifest(manifest *config.Config) error {
+	hash, err := manifest.Hash()
+	if err != nil {
+		return fmt.Errorf("Failed to calculate manifest hash, error: %s", err)
+	}
+	if hash != plan.ManifestHash {
+		return ErrPlanDrifted{[]string{fmt.Sprintf("manifest has changed (hash %.12s -> %.12s)", plan.ManifestHash, hash)}}
+	}
+	return nil
+}
+
+// observeContainers collects the state of actual containers from the given namespace
+// and of those that are referred by the plan, e.g. external dependencies
+func observeContainers(ns string, actual []*Container, referred []*SavedContainer) map[string]*ObservedContainer {
+	observed := map[string]*ObservedContainer{}
+
+	names := map[string]bool{}
+	for _, c := range referred {
+		names[c.Name] = true
+	}
+
+	for _, c := range actual {
+		name := c.Name.String()
+		if c.Name.Namespace != ns && !names[name] {
+			continue
+		}
+		observed[name] = &ObservedContainer{
+			ID:      c.ID,
+			Running: c.State.Running,
+		}
+	}
+
+	return observed
+}
+
+func (plan *SavedPlan) encodeAction(action Action, index map[*Container]int) (*SavedAction, error) {
+	var (
+		saved     = &SavedAction{}
+		container *Container
+	)
+
+	switch a := action.(type) {
+	case *stepAction:
+		saved.Type = savedActionStep
+		saved.Async = a.async
+		saved.Actions = []*SavedAction{}
+		for _, nested := range a.actions {
+			s, err := plan.encodeAction(nested, index)
+			if err != nil {
+				return nil, err
+			}
+			saved.Actions = append(saved.Actions, s)
+		}
+		return saved, nil
+	case *noAction:
+		saved.Type = savedActionNoop
+		return saved, nil
+	case *runContainer:
+		saved.Type, container = savedActionRun, a.container
+	case *removeContainer:
+		saved.Type, container = savedActionRemove, a.container
+	case *ensureContainerExist:
+		saved.Type, container = savedActionEnsureExist, a.container
+	case *ensureContainerState:
+		saved.Type, container = savedActionEnsureState, a.container
+	case *waitContainerAction:
+		saved.Type, container = savedActionWait, a.container
+	default:
+		return nil, fmt.Errorf("Cannot save action of unknown type %T: %s", action, action)
+	}
+
+	i, ok := index[container]
+	if !ok {
+		c, err := newSavedContainer(container)
+		if err != nil {
+			return nil, err
+		}
+		i = len(plan.Containers)
+		index[container] = i
+		plan.Containers = append(plan.Containers, c)
+	}
+	saved.Container = &i
+
+	return saved, nil
+}
+
+func decodeAction(saved *SavedAction, containers []*Container) (Action, error) {
+	if saved.Type == savedActionStep {
+		actions := []Action{}
+		for _, s := range saved.Actions {
+			a, err := decodeAction(s, containers)
+			if err != nil {
+				return nil, err
+			}
+			actions = append(actions, a)
+		}
+		return &stepAction{actions: actions, async: saved.Async}, nil
+	}
+
+	if saved.Type == savedActionNoop {
+		return NoAction, nil
+	}
+
+	if saved.Container == nil || *saved.Container < 0 || *saved.Container >= len(containers) {
+		return nil, fmt.Errorf("Action %s of the plan refers to unknown container", saved.Type)
+	}
+	c := containers[*saved.Container]
+
+	switch saved.Type {
+	case savedActionRun:
+		return NewRunContainerAction(c), nil
+	case savedActionRemove:
+		return NewRemoveContainerAction(c), nil
+	case savedActionEnsureExist:
+		return NewEnsureContainerExistAction(c), nil
+	case savedActionEnsureState:
+		return NewEnsureContainerStateAction(c), nil
+	case savedActionWait:
+		return NewWaitContainerAction(c), nil
+	}
+
+	return nil, fmt.Errorf("Unknown action type in the plan: %s", saved.Type)
+}
+
+func newSavedContainer(c *Container) (*SavedContainer, error) {
+	saved := &SavedContainer{
+		ID:      c.ID,
+		Name:    c.Name.String(),
+		ImageID: c.ImageID,
+		Running: c.State != nil && c.State.Running,
+	}
+	if c.Image != nil {
+		saved.Image = c.Image.String()
+	}
+	if c.Config != nil {
+		data, err := yaml.Marshal(c.Config)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to serialize config of container %s, error: %s", c.Name, err)
+		}
+		saved.Config = string(data)
+	}
+	return saved, nil
+}
@@ -0,0 +1,155 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compose
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/grammarly/rocker-compose/src/compose/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSavedPlanRoundTrip(t *testing.T) {
+	c1 := newContainerWaitFor("test", "1", config.ContainerName{Namespace: "test", Name: "2"})
+	c2 := newContainer("test", "2")
+	c2.ID = "c2"
+	c2x := newContainer("test", "2")
+	c2x.Config.Labels = map[string]string{"test": "test2"}
+	c3 := newContainer("test", "3")
+	c3.ID = "c3"
+
+	expected := []*Container{c1, c2x}
+	actual := []*Container{c2, c3}
+
+	actions, err := NewDiff("test").Diff(expected, actual)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manifest := &config.Config{Namespace: "test"}
+
+	saved, err := NewSavedPlan(manifest, actual, actions)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	if _, err := saved.WriteTo(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := ReadSavedPlan(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, saved, loaded)
+	assert.Equal(t, map[string]*ObservedContainer{
+		"test.2": &ObservedContainer{ID: "c2", Running: true},
+		"test.3": &ObservedContainer{ID: "c3", Running: true},
+	}, loaded.Observed)
+
+	restored, err := loaded.GetActions()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// restored actions should do the same calls to the client
+	calls := func(actions []Action) (result []string) {
+		WalkActions(actions, func(action Action) {
+			result = append(result, action.String())
+		})
+		return result
+	}
+	assert.Equal(t, calls(actions), calls(restored))
+
+	// same container should be restored as a single object
+	var waited, created *Container
+	WalkActions(restored, func(action Action) {
+		switch a := action.(type) {
+		case *waitContainerAction:
+			waited = a.container
+		case *runContainer:
+			if a.container.Name.Name == "2" {
+				created = a.container
+			}
+		}
+	})
+	assert.True(t, waited == created, "wait and run actions should refer to the same container")
+	assert.Equal(t, "test2", created.Config.Labels["test"])
+}
+
+func TestSavedPlanCheckDrift(t *testing.T) {
+	c1 := newContainer("test", "1")
+	c1.ID = "c1"
+	c2 := newContainer("test", "2")
+	c2.ID = "c2"
+	ext := newContainer("other", "1")
+	ext.ID = "ext"
+
+	saved, err := NewSavedPlan(&config.Config{Namespace: "test"}, []*Container{c1, c2, ext}, []Action{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// containers from other namespaces that are not referred by the plan do not matter
+	assert.Nil(t, saved.CheckDrift([]*Container{c1, c2}))
+
+	c1x := newContainer("test", "1")
+	c1x.ID = "c1x"
+	c2x := newContainer("test", "2")
+	c2x.ID = "c2"
+	c2x.State.Running = false
+	c3 := newContainer("test", "3")
+	c3.ID = "c3"
+
+	err = saved.CheckDrift([]*Container{c1x, c2x, c3})
+	assert.Equal(t, ErrPlanDrifted{[]string{
+		"test.1 was recreated (id:c1 -> id:c1x)",
+		"test.2 (id:c2) changed state: running -> not running",
+		"test.3 (id:c3) was created",
+	}}, err)
+
+	err = saved.CheckDrift([]*Container{})
+	assert.Equal(t, ErrPlanDrifted{[]string{
+		"test.1 (id:c1) was removed",
+		"test.2 (id:c2) was removed",
+	}}, err)
+}
+
+func TestSavedPlanCheckManifest(t *testing.T) {
+	image := "nginx:1.9"
+	manifest := &config.Config{
+		Namespace:  "test",
+		Containers: map[string]*config.Container{"web": {Image: &image}},
+	}
+
+	saved, err := NewSavedPlan(manifest, []*Container{}, []Action{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Nil(t, saved.CheckManifest(manifest))
+
+	changed := "nginx:1.10"
+	manifest.Containers["web"] = &config.Container{Image: &changed}
+
+	err = saved.CheckManifest(manifest)
+	assert.IsType(t, ErrPlanDrifted{}, err)
+	assert.Contains(t, err.Error(), "manifest has changed")
+}
@@ -0,0 +1,188 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compose
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/grammarly/rocker-compose/src/compose/config"
+
+	"github.com/fsouza/go-dockerclient"
+	log "github.com/sirupsen/logrus"
+)
+
+// Snapshot makes a manifest of existing containers: either of all containers of the given
+// namespace, or of the given containers. Names of containers are relative to the namespace
+// if it is given, a leading dot refers to a global container, e.g. ".db". Containers created
+// by rocker-compose get their specs from the `rocker-compose-config` label, others are
+// converted from `docker inspect` by config.NewFromAPIContainer. References between
+// containers of the result become references by short names.
+func Snapshot(client *docker.Client, namespace string, names []string) (*config.Config, error) {
+	if namespace == "" && len(names) == 0 {
+		return nil, fmt.Errorf("Either namespace or names of containers should be given")
+	}
+
+	apiContainers, err := client.ListContainers(docker.ListContainersOptions{All: true})
+	if err != nil {
+		return nil, err
+	}
+
+	byName := map[string]docker.APIContainers{}
+	for _, apiContainer := range apiContainers {
+		byName[snapshotContainerName(apiContainer).String()] = apiContainer
+	}
+
+	selected := []docker.APIContainers{}
+	if len(names) == 0 {
+		for _, apiContainer := range apiContainers {
+			if snapshotContainerName(apiContainer).Namespace == namespace {
+				selected = append(selected, apiContainer)
+			}
+		}
+	}
+	for _, name := range names {
+		if strings.HasPrefix(name, ".") {
+			name = name[1:]
+		} else if namespace != "" && !strings.Contains(name, ".") {
+			name = namespace + "." + name
+		}
+		apiContainer, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("Container %s is not found", name)
+		}
+		selected = append(selected, apiContainer)
+	}
+
+	// the namespace of the manifest is the one of the given containers, if not specified
+	if namespace == "" {
+		for _, apiContainer := range selected {
+			ns := snapshotContainerName(apiContainer).Namespace
+			if ns == "" || (namespace != "" && ns != namespace) {
+				return nil, fmt.Errorf("Containers are not of the same namespace, please specify the namespace of the manifest")
+			}
+			namespace = ns
+		}
+	}
+
+	manifest := &config.Config{
+		Namespace:  namespace,
+		Containers: map[string]*config.Container{},
+	}
+	keys := map[string]string{}
+
+	for _, apiContainer := range selected {
+		name := snapshotContainerName(apiContainer)
+		if _, ok := manifest.Containers[name.Name]; ok {
+			return nil, fmt.Errorf("Cannot put container %s to the manifest, there is another container named %s", name, name.Name)
+		}
+
+		log.Infof("Inspecting container %s", name)
+
+		container, err := snapshotContainer(client, apiContainer.ID)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to snapshot container %s, error: %s", name, err)
+		}
+		manifest.Containers[name.Name] = container
+		keys[name.String()] = name.Name
+	}
+
+	for _, container := range manifest.Containers {
+		snapshotReferences(container, namespace, keys, apiContainers)
+	}
+
+	return manifest, nil
+}
+
+// snapshotContainer returns the spec of a container
+func snapshotContainer(client *docker.Client, id string) (*config.Container, error) {
+	apiContainer, err := client.InspectContainer(id)
+	if err != nil {
+		return nil, err
+	}
+
+	container, err := config.NewFromDocker(apiContainer)
+	if err == nil {
+		return container, nil
+	}
+	if _, ok := err.(config.ErrNotRockerCompose); !ok {
+		return nil, err
+	}
+
+	image, err := client.InspectImage(apiContainer.Image)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to inspect image %.12s, error: %s", apiContainer.Image, err)
+	}
+
+	return config.NewFromAPIContainer(apiContainer, image.Config), nil
+}
+
+// snapshotReferences makes references of the container relative to the namespace of the manifest:
+// containers of the manifest are referred by keys, containers given by ids are referred by names
+func snapshotReferences(container *config.Container, namespace string, keys map[string]string, apiContainers []docker.APIContainers) {
+	rename := func(name config.ContainerName) config.ContainerName {
+		if name.Namespace == "" || name.Namespace == "." {
+			for _, apiContainer := range apiContainers {
+				if len(name.Name) >= 12 && strings.HasPrefix(apiContainer.ID, name.Name) {
+					name = *snapshotContainerName(apiContainer)
+					break
+				}
+			}
+		}
+
+		switch {
+		case keys[name.String()] != "":
+			return config.ContainerName{Name: keys[name.String()]}
+		case name.Namespace == namespace:
+			return config.ContainerName{Name: name.Name}
+		case name.Namespace == "" || name.Namespace == ".":
+			// a leading dot keeps the reference global when the manifest is read
+			return config.ContainerName{Name: "." + name.Name}
+		}
+		return name
+	}
+
+	for i, name := range container.VolumesFrom {
+		container.VolumesFrom[i] = rename(name)
+	}
+	for i, name := range container.WaitFor {
+		container.WaitFor[i] = rename(name)
+	}
+	for i, link := range container.Links {
+		container.Links[i].ContainerName = rename(link.ContainerName)
+	}
+	if container.Net != nil && container.Net.Type == "container" {
+		container.Net.Container = rename(container.Net.Container)
+	}
+	if container.Ipc != nil && container.Ipc.Type == "container" {
+		container.Ipc.Container = rename(container.Ipc.Container)
+	}
+}
+
+// snapshotContainerName returns the name of the container, docker gives
+// names of linked containers as well, e.g. "/ns.web/db" for "/ns.db"
+func snapshotContainerName(apiContainer docker.APIContainers) *config.ContainerName {
+	names := append([]string{}, apiContainer.Names...)
+	sort.Strings(names)
+	for _, name := range names {
+		if strings.Count(name, "/") == 1 {
+			return config.NewContainerNameFromString(name)
+		}
+	}
+	return config.NewContainerNameFromString(apiContainer.ID)
+}
@@ -0,0 +1,73 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compose
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/fsouza/go-dockerclient"
+	"github.com/go-yaml/yaml"
+	"github.com/grammarly/rocker-compose/src/compose/config"
+	"github.com/grammarly/rocker/src/template"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshotReferences(t *testing.T) {
+	apiContainers := []docker.APIContainers{
+		{ID: "1234567890abcdef", Names: []string{"/myapp.web/db", "/myapp.db"}},
+		{ID: "abcdef1234567890", Names: []string{"/legacy"}},
+	}
+
+	assert.Equal(t, "myapp.db", snapshotContainerName(apiContainers[0]).String())
+
+	image := "nginx:1.9"
+	container := &config.Container{
+		Image:       &image,
+		VolumesFrom: config.ContainerNames{{Namespace: ".", Name: "abcdef123456"}},
+		Links: config.Links{
+			{ContainerName: config.ContainerName{Namespace: "myapp", Name: "db"}, Alias: "db"},
+			{ContainerName: config.ContainerName{Namespace: ".", Name: "statsd"}, Alias: "statsd"},
+			{ContainerName: config.ContainerName{Namespace: "other", Name: "api"}, Alias: "api"},
+		},
+		Net: &config.Net{Type: "container", Container: config.ContainerName{Namespace: ".", Name: "legacy"}},
+	}
+
+	// the legacy container is taken to the manifest as "proxy"
+	keys := map[string]string{"myapp.db": "db", "legacy": "proxy"}
+	snapshotReferences(container, "myapp", keys, apiContainers)
+
+	data, err := yaml.Marshal(&config.Config{
+		Namespace:  "myapp",
+		Containers: map[string]*config.Container{"web": container},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manifest, err := config.ReadConfig("compose.yml", strings.NewReader(string(data)), template.Vars{}, map[string]interface{}{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	web := manifest.Containers["web"]
+	assert.Equal(t, "myapp.proxy", web.VolumesFrom[0].String())
+	assert.Equal(t, "myapp.db:db", web.Links[0].String())
+	assert.True(t, web.Links[1].IsGlobalNs())
+	assert.Equal(t, "other.api:api", web.Links[2].String())
+	assert.Equal(t, "container:myapp.proxy", web.Net.String())
+}
@@ -0,0 +1,200 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compose
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/go-yaml/yaml"
+	"github.com/grammarly/rocker-compose/src/compose/config"
+	"github.com/mitchellh/go-homedir"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// StateStore keeps specs of containers that were adopted by `run --adopt`. Such containers
+// were created by other tools and have no `rocker-compose-config` label, and labels cannot
+// be added to existing containers, so their specs are stored locally by container ids.
+// The same file is shared by all docker daemons rocker-compose talks to, so records are
+// grouped by daemon ids and the store gives access only to the records of its daemon.
+type StateStore struct {
+	Daemons map[string]map[string]*AdoptedContainer `json:"daemons"`
+
+	path   string
+	daemon string
+	mu     sync.Mutex
+}
+
+// AdoptedContainer is a record of the state store about a single adopted container,
+// Config is the YAML spec the same way as it is stored in the `rocker-compose-config` label
+type AdoptedContainer struct {
+	Name       string    `json:"name"`
+	Config     string    `json:"config"`
+	ConfigHash string    `json:"config_hash"`
+	AdoptedAt  time.Time `json:"adopted_at"`
+}
+
+// DefaultStateFile returns the path of the state store, which is ~/.rocker-compose/state.json
+func DefaultStateFile() (string, error) {
+	home, err := homedir.Dir()
+	if err != nil {
+		return "", fmt.Errorf("Failed to get HOME path, error: %s", err)
+	}
+	return filepath.Join(home, ".rocker-compose", "state.json"), nil
+}
+
+// NewStateStore reads the state store from the given file for the daemon of the given id,
+// the store is empty if the file does not exist
+func NewStateStore(path, daemon string) (*StateStore, error) {
+	state := &StateStore{
+		Daemons: map[string]map[string]*AdoptedContainer{},
+		path:    path,
+		daemon:  daemon,
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read state file %s, error: %s", path, err)
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("Failed to parse state file %s, error: %s", path, err)
+	}
+
+	for _, adopted := range state.Daemons {
+		for id, record := range adopted {
+			if _, err := record.GetConfig(); err != nil {
+				log.Warnf("Ignoring adopted container %s id:%.12s in state file %s, error: %s", record.Name, id, path, err)
+				delete(adopted, id)
+			}
+		}
+	}
+
+	return state, nil
+}
+
+// GetConfig parses the stored spec and makes sure it matches the stored hash
+func (record *AdoptedContainer) GetConfig() (*config.Container, error) {
+	container := &config.Container{}
+	if err := yaml.Unmarshal([]byte(record.Config), container); err != nil {
+		return nil, fmt.Errorf("Failed to parse YAML config, error: %s", err)
+	}
+	hash, err := container.Hash()
+	if err != nil {
+		return nil, err
+	}
+	if hash != record.ConfigHash {
+		return nil, fmt.Errorf("config hash mismatch, expected %.12s, got %.12s", record.ConfigHash, hash)
+	}
+	return container, nil
+}
+
+// Get returns the stored spec of an adopted container, or nil if the container is not adopted
+func (state *StateStore) Get(id string) *config.Container {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	record, ok := state.Daemons[state.daemon][id]
+	if !ok {
+		return nil
+	}
+	container, _ := record.GetConfig()
+	return container
+}
+
+// IDs returns ids of all containers adopted on the daemon of the store
+func (state *StateStore) IDs() []string {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	ids := []string{}
+	for id := range state.Daemons[state.daemon] {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Add records the container with the given spec as adopted
+func (state *StateStore) Add(id string, name *config.ContainerName, container *config.Container) error {
+	data, err := yaml.Marshal(container)
+	if err != nil {
+		return err
+	}
+	hash, err := container.Hash()
+	if err != nil {
+		return err
+	}
+
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	if state.Daemons[state.daemon] == nil {
+		state.Daemons[state.daemon] = map[string]*AdoptedContainer{}
+	}
+	state.Daemons[state.daemon][id] = &AdoptedContainer{
+		Name:       name.String(),
+		Config:     string(data),
+		ConfigHash: hash,
+		AdoptedAt:  time.Now(),
+	}
+	return nil
+}
+
+// Remove deletes records of the given containers, it returns true if any was found
+func (state *StateStore) Remove(ids ...string) bool {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	adopted := state.Daemons[state.daemon]
+	found := false
+	for _, id := range ids {
+		if _, ok := adopted[id]; ok {
+			delete(adopted, id)
+			found = true
+		}
+	}
+	if len(adopted) == 0 {
+		delete(state.Daemons, state.daemon)
+	}
+	return found
+}
+
+// Save writes the state store to its file
+func (state *StateStore) Save() error {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(state.path), 0755); err != nil {
+		return fmt.Errorf("Failed to create directory for state file %s, error: %s", state.path, err)
+	}
+	if err := ioutil.WriteFile(state.path, data, 0644); err != nil {
+		return fmt.Errorf("Failed to write state file %s, error: %s", state.path, err)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compose
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/grammarly/rocker-compose/src/compose/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStateStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rocker-compose-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "nested", "state.json")
+
+	state, err := NewStateStore(path, "daemon1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, state.IDs())
+
+	image := "nginx:1.9"
+	spec := &config.Container{Image: &image}
+	if err := state.Add("abc", config.NewContainerName("test", "web"), spec); err != nil {
+		t.Fatal(err)
+	}
+	if err := state.Add("def", config.NewContainerName("test", "db"), spec); err != nil {
+		t.Fatal(err)
+	}
+	if err := state.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	state, err = NewStateStore(path, "daemon1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "test.web", state.Daemons["daemon1"]["abc"].Name)
+	assert.Equal(t, "nginx:1.9", *state.Get("abc").Image)
+	assert.Nil(t, state.Get("xyz"))
+
+	assert.True(t, state.Remove("def", "xyz"))
+	assert.False(t, state.Remove("def"))
+	assert.Equal(t, []string{"abc"}, state.IDs())
+	if err := state.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// records of other daemons are neither visible nor removed
+	other, err := NewStateStore(path, "daemon2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, other.IDs())
+	assert.Nil(t, other.Get("abc"))
+	assert.False(t, other.Remove("abc"))
+	if err := other.Add("ghi", config.NewContainerName("test", "web"), spec); err != nil {
+		t.Fatal(err)
+	}
+	if err := other.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	state, err = NewStateStore(path, "daemon1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"abc"}, state.IDs())
+
+	// records that do not match their hash are ignored
+	state.Daemons["daemon1"]["abc"].Config = "image: nginx:latest\n"
+	if err := state.Save(); err != nil {
+		t.Fatal(err)
+	}
+	state, err = NewStateStore(path, "daemon1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, state.IDs())
+
+	other, err = NewStateStore(path, "daemon2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"ghi"}, other.IDs())
+}
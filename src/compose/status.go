@@ -0,0 +1,204 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compose
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/docker/go-units"
+)
+
+// Markers of how the actual container corresponds to the manifest
+const (
+	StatusInSync   = "in-sync"
+	StatusDrifted  = "drifted"
+	StatusMissing  = "missing"
+	StatusOrphaned = "orphaned"
+)
+
+// Status is the list of desired vs actual states of the manifest containers
+type Status []*ContainerStatus
+
+// ContainerStatus describes the desired and the actual state of a single container
+type ContainerStatus struct {
+	Name          string     `json:"name"`
+	ID            string     `json:"id"`
+	Image         string     `json:"image"`
+	ImageResolved string     `json:"image_resolved"`
+	ImageActual   string     `json:"image_actual"`
+	ImageID       string     `json:"image_id"`
+	State         string     `json:"state"`
+	ExitCode      int        `json:"exit_code"`
+	OOMKilled     bool       `json:"oom_killed"`
+	StartedAt     *time.Time `json:"started_at"`
+	Uptime        int64      `json:"uptime_seconds"`
+	Sync          string     `json:"sync"`
+	Changes       []string   `json:"changes,omitempty"`
+}
+
+// NewStatus compares 'expected' containers against 'actual' ones and makes
+// a status row for every container of the namespace
+func NewStatus(ns string, expected []*Container, actual []*Container) Status {
+	status := Status{}
+
+	for _, e := range expected {
+		row := &ContainerStatus{
+			Name:  e.Name.String(),
+			Sync:  StatusMissing,
+			State: "-",
+		}
+		if e.Config.Image != nil {
+			row.Image = *e.Config.Image
+		}
+		if e.Image != nil {
+			row.ImageResolved = e.Image.String()
+		}
+
+		if a := find(actual, e.Name); a != nil {
+			row.fillActual(a)
+			row.Sync = StatusInSync
+
+			for _, change := range e.Diff(a) {
+				row.Changes = append(row.Changes, change.Field)
+			}
+			if len(row.Changes) > 0 {
+				row.Sync = StatusDrifted
+			}
+		}
+
+		status = append(status, row)
+	}
+
+	for _, a := range actual {
+		if a.Name.Namespace != ns || find(expected, a.Name) != nil {
+			continue
+		}
+		row := &ContainerStatus{
+			Name: a.Name.String(),
+			Sync: StatusOrphaned,
+		}
+		row.fillActual(a)
+		status = append(status, row)
+	}
+
+	sort.Sort(status)
+
+	return status
+}
+
+// Len returns the number of rows
+func (s Status) Len() int {
+	return len(s)
+}
+
+// Less compares rows by container name
+func (s Status) Less(i, j int) bool {
+	return s[i].Name < s[j].Name
+}
+
+// Swap swaps rows
+func (s Status) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+// WriteTable writes the status as a human readable table
+func (s Status) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "NAME\tID\tIMAGE\tRESOLVED\tACTUAL\tIMAGE ID\tSTATE\tEXIT CODE\tOOM KILLED\tUPTIME\tSYNC")
+
+	for _, row := range s {
+		var (
+			exitCode = "-"
+			uptime   = "-"
+			sync     = row.Sync
+		)
+		if row.ID != "" && row.State != "running" && row.State != "created" {
+			exitCode = fmt.Sprintf("%d", row.ExitCode)
+		}
+		if row.Uptime > 0 {
+			uptime = units.HumanDuration(time.Duration(row.Uptime) * time.Second)
+		}
+		if len(row.Changes) > 0 {
+			sync = fmt.Sprintf("%s %v", sync, row.Changes)
+		}
+		fmt.Fprintf(tw, "%s\t%.12s\t%s\t%s\t%s\t%.12s\t%s\t%s\t%t\t%s\t%s\n",
+			row.Name,
+			orDash(row.ID),
+			orDash(row.Image),
+			orDash(row.ImageResolved),
+			orDash(row.ImageActual),
+			orDash(row.ImageID),
+			row.State,
+			exitCode,
+			row.OOMKilled,
+			uptime,
+			sync,
+		)
+	}
+
+	return tw.Flush()
+}
+
+// WriteJSON writes the status as a JSON array
+func (s Status) WriteJSON(w io.Writer) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+func (row *ContainerStatus) fillActual(a *Container) {
+	row.ID = a.ID
+	row.ImageID = trimImageID(a.ImageID)
+	row.State = a.State.Status()
+	row.ExitCode = a.State.ExitCode
+	row.OOMKilled = a.State.OOMKilled
+
+	if a.Image != nil {
+		row.ImageActual = a.Image.String()
+	}
+	if !a.State.StartedAt.IsZero() {
+		startedAt := a.State.StartedAt
+		row.StartedAt = &startedAt
+	}
+	if a.State.Running {
+		row.Uptime = (int64)(time.Since(a.State.StartedAt) / time.Second)
+	}
+}
+
+// trimImageID removes "sha256:" prefix which newer docker versions add to image ids
+func trimImageID(id string) string {
+	if len(id) > 7 && id[:7] == "sha256:" {
+		return id[7:]
+	}
+	return id
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
@@ -0,0 +1,91 @@
+/*-
+ * Copyright 2015 Grammarly, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package compose
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grammarly/rocker/src/imagename"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatus(t *testing.T) {
+	image := "redis:~2.8"
+
+	// in sync
+	e1 := newContainer("test", "1")
+	e1.Config.Image = &image
+	e1.Image = imagename.NewFromString("redis:2.8.19")
+	a1 := newContainer("test", "1")
+	a1.ID = "a1"
+	a1.Image = imagename.NewFromString("redis:2.8.19")
+	a1.ImageID = "sha256:0123456789abcdef"
+	a1.State.StartedAt = time.Now().Add(-time.Hour)
+
+	// drifted
+	e2 := newContainer("test", "2")
+	a2 := newContainer("test", "2")
+	a2.ID = "a2"
+	a2.State = &ContainerState{ExitCode: 137, OOMKilled: true, FinishedAt: time.Now()}
+	a2.Config.Labels = map[string]string{"foo": "bar"}
+
+	// missing
+	e3 := newContainer("test", "3")
+
+	// orphaned
+	a4 := newContainer("test", "4")
+	a4.ID = "a4"
+
+	// other namespace
+	a5 := newContainer("other", "5")
+
+	status := NewStatus("test", []*Container{e3, e2, e1}, []*Container{a1, a2, a4, a5})
+
+	assert.Len(t, status, 4)
+
+	assert.Equal(t, "test.1", status[0].Name)
+	assert.Equal(t, StatusInSync, status[0].Sync)
+	assert.Equal(t, "redis:~2.8", status[0].Image)
+	assert.Equal(t, "redis:2.8.19", status[0].ImageResolved)
+	assert.Equal(t, "0123456789abcdef", status[0].ImageID)
+	assert.Equal(t, "running", status[0].State)
+	assert.InDelta(t, 3600, status[0].Uptime, 5)
+
+	assert.Equal(t, StatusDrifted, status[1].Sync)
+	assert.Equal(t, []string{"labels", "state"}, status[1].Changes)
+	assert.Equal(t, "exited", status[1].State)
+	assert.Equal(t, 137, status[1].ExitCode)
+	assert.True(t, status[1].OOMKilled)
+
+	assert.Equal(t, StatusMissing, status[2].Sync)
+	assert.Equal(t, "", status[2].ID)
+
+	assert.Equal(t, StatusOrphaned, status[3].Sync)
+	assert.Equal(t, "a4", status[3].ID)
+
+	buf := &bytes.Buffer{}
+	if err := status.WriteTable(buf); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 5)
+	assert.Contains(t, lines[2], "drifted [labels state]")
+	assert.Contains(t, lines[3], "missing")
+}